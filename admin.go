@@ -0,0 +1,173 @@
+package goconfig
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// AdminService implements the operations of an admin API for a config, such
+// as the ConfigAdmin gRPC service defined in adminpb/admin.proto, whose
+// generated server only has to convert the results. AdminHandler serves them
+// over plain HTTP.
+type AdminService struct {
+	c    Configterface
+	opts []Option
+}
+
+// NewAdminService returns an AdminService for config, reloading it with
+// opts. It keeps the state of config, so that GetConfig reports its loads
+// from then on.
+func NewAdminService(config interface{}, opts ...Option) *AdminService {
+	return &AdminService{c: keepConfig(config), opts: opts}
+}
+
+// AdminConfig is the state of a config as returned by AdminService.GetConfig.
+type AdminConfig struct {
+	// File is the config file, if there is one.
+	File string
+	// LoadedAt is the time of the last successful load, zero if there was
+	// none.
+	LoadedAt time.Time
+	// LastError is the error of the last load, if it failed, and LastErrorAt
+	// its time.
+	LastError   error
+	LastErrorAt time.Time
+	// JSON is the effective config as JSON, with secrets masked.
+	JSON []byte
+}
+
+// AdminReload is the outcome of AdminService.Reload.
+type AdminReload struct {
+	// Err is the error the reload failed with, if it failed.
+	Err error
+	// Changed lists the Go paths of the fields the reload changed.
+	Changed []string
+}
+
+// GetConfig returns the effective config, with secrets masked, and how its
+// loads went.
+func (s *AdminService) GetConfig(ctx context.Context) (AdminConfig, error) {
+	s.c.RLock()
+	data, err := marshalConfig(configTarget(s.c), FormatJSON, true)
+	config := AdminConfig{File: s.c.GetFilename(), JSON: data}
+	s.c.RUnlock()
+	if err != nil {
+		return AdminConfig{}, err
+	}
+	state := stateOf(s.c)
+	config.LoadedAt = state.lastSuccess
+	if state.lastErr != nil {
+		config.LastError, config.LastErrorAt = state.lastErr, state.lastAttempt
+	}
+	return config, nil
+}
+
+// Reload reloads the config, as Reload does.
+func (s *AdminService) Reload(ctx context.Context) AdminReload {
+	var changed []string
+	record := OnReload(func(old, new interface{}) {
+		c := configOf(new)
+		c.RLock()
+		changed = changedFields(Diff(old, new))
+		c.RUnlock()
+	})
+	if err := Reload(s.c, append(append([]Option(nil), s.opts...), record)...); err != nil {
+		return AdminReload{Err: err}
+	}
+	return AdminReload{Changed: changed}
+}
+
+// WatchChanges calls send with the outcome of every background reload, as
+// sent to subscribers, until ctx is done or send fails, and returns the error
+// that stopped it.
+func (s *AdminService) WatchChanges(ctx context.Context, send func(ChangeEvent) error) error {
+	events := Subscribe(s.c)
+	defer Unsubscribe(s.c, events)
+	for {
+		select {
+		case event := <-events:
+			if err := send(event); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// adminStatus is the body of a GET from AdminHandler.
+type adminStatus struct {
+	File        string          `json:"file,omitempty"`
+	LoadedAt    *time.Time      `json:"loaded_at,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	LastErrorAt *time.Time      `json:"last_error_at,omitempty"`
+	Config      json.RawMessage `json:"config"`
+}
+
+// adminReload is the body of a POST to AdminHandler.
+type adminReload struct {
+	Reloaded bool     `json:"reloaded"`
+	Error    string   `json:"error,omitempty"`
+	Changed  []string `json:"changed,omitempty"`
+}
+
+// AdminHandler returns an http.Handler exposing c for an internal admin mux,
+// as in mux.Handle("/internal/config", goconfig.AdminHandler(config)). A GET
+// returns a JSON object holding the effective config, with secrets masked,
+// under "config", along with the file it came from, when it was last loaded
+// and the error of the last load if that failed. A POST reloads c with opts,
+// as Reload does, returning whether it worked and the Go paths of the fields
+// it changed, with a 500 status if it failed.
+//
+// The handler doesn't authenticate anything, so it must only be reachable by
+// operators.
+func AdminHandler(c interface{}, opts ...Option) http.Handler {
+	s := NewAdminService(c, opts...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			serveAdminStatus(w, r, s)
+		case http.MethodPost:
+			serveAdminReload(w, r, s)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func serveAdminStatus(w http.ResponseWriter, r *http.Request, s *AdminService) {
+	config, err := s.GetConfig(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	status := adminStatus{File: config.File, Config: config.JSON}
+	if !config.LoadedAt.IsZero() {
+		status.LoadedAt = &config.LoadedAt
+	}
+	if config.LastError != nil {
+		status.LastError = config.LastError.Error()
+		status.LastErrorAt = &config.LastErrorAt
+	}
+	writeJSONResponse(w, http.StatusOK, status)
+}
+
+func serveAdminReload(w http.ResponseWriter, r *http.Request, s *AdminService) {
+	result := s.Reload(r.Context())
+	if result.Err != nil {
+		writeJSONResponse(w, http.StatusInternalServerError, adminReload{Error: result.Err.Error()})
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, adminReload{Reloaded: true, Changed: result.Changed})
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	e.Encode(v)
+}
@@ -0,0 +1,212 @@
+// The ConfigAdmin service lets fleet tooling inspect and reload the config of
+// a service using goconfig. goconfig.AdminService implements its operations,
+// and adminpb.NewServer serves them with the Go code generated from this file
+// by protoc-gen-go and protoc-gen-go-grpc.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: admin.proto
+
+package adminpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ConfigAdmin_GetConfig_FullMethodName    = "/goconfig.admin.v1.ConfigAdmin/GetConfig"
+	ConfigAdmin_Reload_FullMethodName       = "/goconfig.admin.v1.ConfigAdmin/Reload"
+	ConfigAdmin_WatchChanges_FullMethodName = "/goconfig.admin.v1.ConfigAdmin/WatchChanges"
+)
+
+// ConfigAdminClient is the client API for ConfigAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ConfigAdminClient interface {
+	// GetConfig returns the effective config, with secrets masked.
+	GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*GetConfigResponse, error)
+	// Reload reloads the config.
+	Reload(ctx context.Context, in *ReloadRequest, opts ...grpc.CallOption) (*ReloadResponse, error)
+	// WatchChanges streams the outcome of every reload until the call ends.
+	WatchChanges(ctx context.Context, in *WatchChangesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error)
+}
+
+type configAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewConfigAdminClient(cc grpc.ClientConnInterface) ConfigAdminClient {
+	return &configAdminClient{cc}
+}
+
+func (c *configAdminClient) GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*GetConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetConfigResponse)
+	err := c.cc.Invoke(ctx, ConfigAdmin_GetConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configAdminClient) Reload(ctx context.Context, in *ReloadRequest, opts ...grpc.CallOption) (*ReloadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReloadResponse)
+	err := c.cc.Invoke(ctx, ConfigAdmin_Reload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *configAdminClient) WatchChanges(ctx context.Context, in *WatchChangesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ConfigAdmin_ServiceDesc.Streams[0], ConfigAdmin_WatchChanges_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchChangesRequest, ChangeEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ConfigAdmin_WatchChangesClient = grpc.ServerStreamingClient[ChangeEvent]
+
+// ConfigAdminServer is the server API for ConfigAdmin service.
+// All implementations must embed UnimplementedConfigAdminServer
+// for forward compatibility.
+type ConfigAdminServer interface {
+	// GetConfig returns the effective config, with secrets masked.
+	GetConfig(context.Context, *GetConfigRequest) (*GetConfigResponse, error)
+	// Reload reloads the config.
+	Reload(context.Context, *ReloadRequest) (*ReloadResponse, error)
+	// WatchChanges streams the outcome of every reload until the call ends.
+	WatchChanges(*WatchChangesRequest, grpc.ServerStreamingServer[ChangeEvent]) error
+	mustEmbedUnimplementedConfigAdminServer()
+}
+
+// UnimplementedConfigAdminServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedConfigAdminServer struct{}
+
+func (UnimplementedConfigAdminServer) GetConfig(context.Context, *GetConfigRequest) (*GetConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConfig not implemented")
+}
+func (UnimplementedConfigAdminServer) Reload(context.Context, *ReloadRequest) (*ReloadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reload not implemented")
+}
+func (UnimplementedConfigAdminServer) WatchChanges(*WatchChangesRequest, grpc.ServerStreamingServer[ChangeEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchChanges not implemented")
+}
+func (UnimplementedConfigAdminServer) mustEmbedUnimplementedConfigAdminServer() {}
+func (UnimplementedConfigAdminServer) testEmbeddedByValue()                     {}
+
+// UnsafeConfigAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ConfigAdminServer will
+// result in compilation errors.
+type UnsafeConfigAdminServer interface {
+	mustEmbedUnimplementedConfigAdminServer()
+}
+
+func RegisterConfigAdminServer(s grpc.ServiceRegistrar, srv ConfigAdminServer) {
+	// If the following call pancis, it indicates UnimplementedConfigAdminServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ConfigAdmin_ServiceDesc, srv)
+}
+
+func _ConfigAdmin_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigAdminServer).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConfigAdmin_GetConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigAdminServer).GetConfig(ctx, req.(*GetConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigAdmin_Reload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigAdminServer).Reload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConfigAdmin_Reload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigAdminServer).Reload(ctx, req.(*ReloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConfigAdmin_WatchChanges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchChangesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ConfigAdminServer).WatchChanges(m, &grpc.GenericServerStream[WatchChangesRequest, ChangeEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ConfigAdmin_WatchChangesServer = grpc.ServerStreamingServer[ChangeEvent]
+
+// ConfigAdmin_ServiceDesc is the grpc.ServiceDesc for ConfigAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ConfigAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "goconfig.admin.v1.ConfigAdmin",
+	HandlerType: (*ConfigAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetConfig",
+			Handler:    _ConfigAdmin_GetConfig_Handler,
+		},
+		{
+			MethodName: "Reload",
+			Handler:    _ConfigAdmin_Reload_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchChanges",
+			Handler:       _ConfigAdmin_WatchChanges_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "admin.proto",
+}
@@ -0,0 +1,106 @@
+// Package adminpb serves a goconfig.AdminService over gRPC as the ConfigAdmin
+// service defined in admin.proto, as in
+//
+//	admin := goconfig.NewAdminService(&cfg, goconfig.WithFile("config.yaml"))
+//	adminpb.RegisterConfigAdminServer(grpcServer, adminpb.NewServer(admin))
+//
+// It is a package of its own so that goconfig itself doesn't depend on gRPC.
+package adminpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative admin.proto
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/santiclause/goconfig"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements ConfigAdminServer by calling an AdminService.
+type Server struct {
+	UnimplementedConfigAdminServer
+	admin *goconfig.AdminService
+}
+
+// NewServer returns a Server for admin.
+func NewServer(admin *goconfig.AdminService) *Server {
+	return &Server{admin: admin}
+}
+
+// GetConfig returns the effective config, with secrets masked, and how its
+// loads went.
+func (s *Server) GetConfig(ctx context.Context, _ *GetConfigRequest) (*GetConfigResponse, error) {
+	config, err := s.admin.GetConfig(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &GetConfigResponse{
+		File:       config.File,
+		LoadedAt:   timestamp(config.LoadedAt),
+		ConfigJson: string(config.JSON),
+	}
+	if config.LastError != nil {
+		resp.LastError = config.LastError.Error()
+		resp.LastErrorAt = timestamp(config.LastErrorAt)
+	}
+	return resp, nil
+}
+
+// Reload reloads the config. A failed reload is reported in the response
+// rather than as an error, which is kept for failures of the call itself.
+func (s *Server) Reload(ctx context.Context, _ *ReloadRequest) (*ReloadResponse, error) {
+	result := s.admin.Reload(ctx)
+	if result.Err != nil {
+		return &ReloadResponse{Error: result.Err.Error()}, nil
+	}
+	return &ReloadResponse{Reloaded: true, Changed: result.Changed}, nil
+}
+
+// WatchChanges streams the outcome of every reload until the call ends.
+func (s *Server) WatchChanges(_ *WatchChangesRequest, stream ConfigAdmin_WatchChangesServer) error {
+	ctx := stream.Context()
+	err := s.admin.WatchChanges(ctx, func(event goconfig.ChangeEvent) error {
+		msg, err := changeEvent(event)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return stream.Send(msg)
+	})
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return err
+}
+
+// changeEvent converts event into its message, with the values of the
+// changes as JSON.
+func changeEvent(event goconfig.ChangeEvent) (*ChangeEvent, error) {
+	msg := &ChangeEvent{}
+	if event.Err != nil {
+		msg.Error = event.Err.Error()
+	}
+	for _, change := range event.Changes {
+		old, err := json.Marshal(change.Old)
+		if err != nil {
+			return nil, err
+		}
+		new, err := json.Marshal(change.New)
+		if err != nil {
+			return nil, err
+		}
+		msg.Changes = append(msg.Changes, &Change{Path: change.Path, OldValue: string(old), NewValue: string(new)})
+	}
+	return msg, nil
+}
+
+// timestamp converts t into a message, nil if t is zero.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
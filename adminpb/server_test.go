@@ -0,0 +1,149 @@
+package adminpb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/santiclause/goconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type adminConfig struct {
+	Port     int             `yaml:"port"`
+	Password goconfig.Secret `yaml:"password"`
+}
+
+// dial serves admin on an in-memory listener and returns a client for it.
+func dial(t *testing.T, admin *goconfig.AdminService) ConfigAdminClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	RegisterConfigAdminServer(srv, NewServer(admin))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewConfigAdminClient(conn)
+}
+
+func writePort(t *testing.T, filename string, port int) {
+	t.Helper()
+	if err := os.WriteFile(filename, []byte(fmt.Sprintf("port: %d\npassword: hunter2\n", port)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	writePort(t, filename, 8080)
+	var cfg adminConfig
+	admin := goconfig.NewAdminService(&cfg, goconfig.WithFile(filename))
+	t.Cleanup(func() { goconfig.Release(&cfg) })
+	if err := goconfig.Load(&cfg, goconfig.WithFile(filename)); err != nil {
+		t.Fatal(err)
+	}
+	client := dial(t, admin)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	got, err := client.GetConfig(ctx, &GetConfigRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got.ConfigJson, "8080") || strings.Contains(got.ConfigJson, "hunter2") {
+		t.Errorf("config_json = %s", got.ConfigJson)
+	}
+	if got.LoadedAt == nil || got.LastError != "" {
+		t.Errorf("loaded_at = %v, last_error = %q", got.LoadedAt, got.LastError)
+	}
+
+	writePort(t, filename, 9090)
+	reloaded, err := client.Reload(ctx, &ReloadRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.Reloaded || strings.Join(reloaded.Changed, ",") != "Port" {
+		t.Errorf("reload = %v", reloaded)
+	}
+
+	if err := os.WriteFile(filename, []byte("port: nope\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := client.Reload(ctx, &ReloadRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.Reloaded || failed.Error == "" {
+		t.Errorf("reload = %v, want a failure", failed)
+	}
+	if cfg.Port != 9090 {
+		t.Errorf("port = %d after a failed reload", cfg.Port)
+	}
+}
+
+func TestServerWatchChanges(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	writePort(t, filename, 8080)
+	var cfg adminConfig
+	admin := goconfig.NewAdminService(&cfg, goconfig.WithFile(filename))
+	t.Cleanup(func() { goconfig.Release(&cfg) })
+	if err := goconfig.Load(&cfg, goconfig.WithFile(filename)); err != nil {
+		t.Fatal(err)
+	}
+	client := dial(t, admin)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := client.WatchChanges(ctx, &WatchChangesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan *ChangeEvent)
+	go func() {
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				close(events)
+				return
+			}
+			events <- event
+		}
+	}()
+	// The server subscribes once the stream has started, so reloads are
+	// repeated, each changing the port, until one is seen.
+	for port := 8081; ; port++ {
+		writePort(t, filename, port)
+		if _, err := client.Reload(ctx, &ReloadRequest{}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("stream ended")
+			}
+			if event.Error != "" || len(event.Changes) != 1 {
+				t.Fatalf("event = %v", event)
+			}
+			change := event.Changes[0]
+			if change.Path != "Port" || change.NewValue != fmt.Sprint(port) {
+				t.Errorf("change = %v, want Port to %d", change, port)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+}
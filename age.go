@@ -0,0 +1,196 @@
+package goconfig
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// WithAgeKey adds age identities to decrypt SOPS files and ENC[...] values
+// with, each either an
+// "AGE-SECRET-KEY-1..." key or the contents of a keys file holding one per
+// line. They are tried before those found in the environment: the
+// SOPS_AGE_KEY variable, the file named by SOPS_AGE_KEY_FILE, and
+// sops/age/keys.txt in the user config directory.
+func WithAgeKey(keys ...string) Option {
+	return func(o *options) {
+		o.ageKeys = append(o.ageKeys, keys...)
+	}
+}
+
+// ageIdentities returns the age identities of o and of the environment.
+func ageIdentities(o *options) ([]age.Identity, error) {
+	sources := append([]string(nil), o.ageKeys...)
+	if key := os.Getenv("SOPS_AGE_KEY"); key != "" {
+		sources = append(sources, key)
+	}
+	var files []string
+	if file := os.Getenv("SOPS_AGE_KEY_FILE"); file != "" {
+		files = append(files, file)
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		files = append(files, filepath.Join(dir, "sops", "age", "keys.txt"))
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		sources = append(sources, string(data))
+	}
+	var identities []age.Identity
+	for _, source := range sources {
+		parsed, err := age.ParseIdentities(strings.NewReader(source))
+		if err != nil {
+			return nil, fmt.Errorf("age key: %w", err)
+		}
+		identities = append(identities, parsed...)
+	}
+	if len(identities) == 0 {
+		return nil, errors.New("no age key: set SOPS_AGE_KEY or SOPS_AGE_KEY_FILE, or pass WithAgeKey")
+	}
+	return identities, nil
+}
+
+// ageDecrypt decrypts an age file, armored or not, with identities.
+func ageDecrypt(data []byte, identities []age.Identity) ([]byte, error) {
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// ageHeader starts every binary age file.
+const ageHeader = "age-encryption.org/"
+
+// Encrypts plaintext to the given age recipients ("age1..." public keys) as
+// an ENC[...] value, which can stand in for the plaintext in a YAML, JSON or
+// TOML config file and is decrypted as the file is loaded, with the keys given
+// with WithAgeKey or found in the environment.
+func EncryptValue(plaintext string, recipients ...string) (string, error) {
+	parsed, err := age.ParseRecipients(strings.NewReader(strings.Join(recipients, "\n")))
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	w, err := age.Encrypt(&b, parsed...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(w, plaintext); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return "ENC[" + base64.StdEncoding.EncodeToString(b.Bytes()) + "]", nil
+}
+
+// ageCiphertext returns the age file held by an ENC[...] value, either base64
+// encoded or armored, and whether s is one.
+func ageCiphertext(s string) ([]byte, bool) {
+	if !strings.HasPrefix(s, "ENC[") || !strings.HasSuffix(s, "]") {
+		return nil, false
+	}
+	inner := strings.TrimSpace(s[len("ENC[") : len(s)-1])
+	if strings.HasPrefix(inner, armor.Header) {
+		return []byte(inner), true
+	}
+	data, err := base64.StdEncoding.DecodeString(inner)
+	if err != nil {
+		if data, err = base64.RawStdEncoding.DecodeString(inner); err != nil {
+			return nil, false
+		}
+	}
+	return data, bytes.HasPrefix(data, []byte(ageHeader))
+}
+
+// ageValues decrypts the ENC[...] values of a document tree. The identities
+// are only looked up once one is found.
+type ageValues struct {
+	o          *options
+	identities []age.Identity
+	// keyErr is the error looking up the identities, reported once.
+	keyErr error
+	errs   []string
+	// decrypted is set once a value has been replaced.
+	decrypted bool
+}
+
+// decryptAgeValues replaces the ENC[...] values of doc with their plaintext,
+// typed as if written in YAML directly. Errors for every value are collected.
+func decryptAgeValues(doc *document, o *options) error {
+	a := &ageValues{o: o}
+	a.tree(doc.tree, "")
+	if a.decrypted {
+		doc.merged = true
+	}
+	if a.errs != nil {
+		return errors.New(strings.Join(a.errs, ". "))
+	}
+	return nil
+}
+
+func (a *ageValues) tree(tree map[string]interface{}, path string) {
+	keys := make([]string, 0, len(tree))
+	for k := range tree {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := k
+		if path != "" {
+			name = path + "." + k
+		}
+		tree[k] = a.value(tree[k], name)
+	}
+}
+
+func (a *ageValues) value(v interface{}, path string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		a.tree(t, path)
+	case []interface{}:
+		for i, item := range t {
+			t[i] = a.value(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case string:
+		data, ok := ageCiphertext(t)
+		if !ok {
+			return v
+		}
+		if a.identities == nil && a.keyErr == nil {
+			if a.identities, a.keyErr = ageIdentities(a.o); a.keyErr != nil {
+				a.errs = append(a.errs, fmt.Sprintf("%s: %s", path, a.keyErr))
+			}
+		}
+		if a.keyErr != nil {
+			return v
+		}
+		plain, err := ageDecrypt(data, a.identities)
+		if err != nil {
+			a.errs = append(a.errs, fmt.Sprintf("%s: %s", path, err))
+			return v
+		}
+		a.decrypted = true
+		return resolveScalar(string(plain))
+	}
+	return v
+}
@@ -0,0 +1,121 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// deprecation is a deprecated key found in a document.
+type deprecation struct {
+	// key is the dotted key path of the deprecated key.
+	key string
+	// use is the key its value was moved to, for an alias, and note the
+	// text of a `deprecated` tag.
+	use  string
+	note string
+}
+
+// resolveAliases moves the values of the keys in tree given in the `alias`
+// tags of the fields of the struct type t, such as `alias:"old_name"`, to the
+// keys of their fields, and returns them along with the keys of fields tagged
+// `deprecated`. An alias is relative to the struct holding its field, and
+// several can be given separated by commas. If both a field's key and an alias
+// are set, the key wins. changed is set if tree was modified.
+func resolveAliases(tree map[string]interface{}, t reflect.Type) (found []deprecation, changed bool) {
+	r := &aliasResolver{}
+	r.value(tree, t, "")
+	return r.found, r.changed
+}
+
+type aliasResolver struct {
+	found   []deprecation
+	changed bool
+}
+
+// value resolves the aliases within v, found at path, which fills a value of
+// type t.
+func (r *aliasResolver) value(v interface{}, t reflect.Type, path string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		tree, ok := v.(map[string]interface{})
+		if !ok || parsesItself(t) {
+			return
+		}
+		r.fields(tree, t, path)
+	case reflect.Slice, reflect.Array:
+		list, _ := v.([]interface{})
+		for i, item := range list {
+			r.value(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		m, _ := v.(map[string]interface{})
+		for k, item := range m {
+			r.value(item, t.Elem(), fmt.Sprintf("%s[%s]", path, k))
+		}
+	}
+}
+
+// fields resolves the aliases of the fields of the struct type t in tree,
+// flattening inlined structs.
+func (r *aliasResolver) fields(tree map[string]interface{}, t reflect.Type, path string) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !exported(sf) || sf.Tag.Get("yaml") == "-" {
+			continue
+		}
+		key := yamlKey(sf)
+		if key == "" {
+			if ft := structType(sf.Type); ft.Kind() == reflect.Struct {
+				r.fields(tree, ft, path)
+			}
+			continue
+		}
+		for _, alias := range strings.Split(sf.Tag.Get("alias"), ",") {
+			alias = strings.TrimSpace(alias)
+			value, ok := tree[alias]
+			if alias == "" || alias == key || !ok {
+				continue
+			}
+			r.found = append(r.found, deprecation{key: joinPath(path, alias), use: joinPath(path, key)})
+			if _, set := tree[key]; !set {
+				tree[key] = value
+			}
+			delete(tree, alias)
+			r.changed = true
+		}
+		value, ok := tree[key]
+		if !ok {
+			continue
+		}
+		if note, deprecated := sf.Tag.Lookup("deprecated"); deprecated {
+			r.found = append(r.found, deprecation{key: joinPath(path, key), note: note})
+		}
+		r.value(value, sf.Type, joinPath(path, key))
+	}
+}
+
+// resolveAliases resolves the aliases in doc, read from filename, warning
+// about them and about any deprecated keys it sets.
+func (r *docReader) resolveAliases(filename string, doc *document) {
+	found, changed := resolveAliases(doc.tree, r.target)
+	if changed {
+		doc.merged = true
+	}
+	for _, d := range found {
+		args := []interface{}{"key", d.key}
+		if filename != "" {
+			args = append([]interface{}{"file", filename}, args...)
+		}
+		if d.use != "" {
+			args = append(args, "use", d.use)
+		}
+		if d.note != "" {
+			args = append(args, "note", d.note)
+		}
+		r.o.log().Warn("config key is deprecated", args...)
+	}
+}
@@ -0,0 +1,281 @@
+package goconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWS configures how goconfig talks to AWS, for fields tagged `ssm` or
+// `secretsmanager` and for ssm:// and secretsmanager:// config URLs. Requests
+// are signed with Signature Version 4 directly, so no SDK is linked in. Empty
+// fields fall back to the standard environment variables.
+type AWS struct {
+	// Region is the region to use, AWS_REGION or AWS_DEFAULT_REGION if
+	// empty.
+	Region string
+	// Credentials returns the credentials to sign requests with. If nil,
+	// they are taken from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+	// AWS_SESSION_TOKEN, or else the ECS and EKS Pod Identity container
+	// credentials endpoint.
+	Credentials func(ctx context.Context) (AWSCredentials, error)
+	// Endpoint replaces the regional endpoints of every service, e.g. for
+	// LocalStack. AWS_ENDPOINT_URL is used if empty.
+	Endpoint string
+	// Client makes the requests, a client with a 30 second timeout if nil.
+	Client *http.Client
+}
+
+// AWSCredentials are the credentials AWS requests are signed with.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// WithAWS configures how goconfig talks to AWS, rather than from the
+// environment alone.
+func WithAWS(a AWS) Option {
+	return func(o *options) {
+		o.aws = &a
+	}
+}
+
+// awsSettings returns the AWS settings of o with their empty fields filled in
+// from the environment.
+func awsSettings(o *options) AWS {
+	a := AWS{}
+	if o.aws != nil {
+		a = *o.aws
+	}
+	if a.Region == "" {
+		a.Region = os.Getenv("AWS_REGION")
+	}
+	if a.Region == "" {
+		a.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if a.Endpoint == "" {
+		a.Endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if a.Client == nil {
+		a.Client = defaultHTTPClient
+	}
+	if a.Credentials == nil {
+		client := a.Client
+		a.Credentials = func(ctx context.Context) (AWSCredentials, error) {
+			return awsEnvCredentials(ctx, client)
+		}
+	}
+	return a
+}
+
+// awsEnvCredentials reads credentials from the environment, or from the
+// container credentials endpoint it points to, with client.
+func awsEnvCredentials(ctx context.Context, client *http.Client) (AWSCredentials, error) {
+	creds := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID != "" {
+		return creds, nil
+	}
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); endpoint == "" && rel != "" {
+		endpoint = "http://169.254.170.2" + rel
+	}
+	if endpoint == "" {
+		return creds, errors.New("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or give WithAWS a Credentials func")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return creds, err
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if file := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return creds, err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return creds, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return creds, fmt.Errorf("container credentials: unexpected status %s", resp.Status)
+	}
+	var body struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return creds, err
+	}
+	return AWSCredentials{body.AccessKeyID, body.SecretAccessKey, body.Token}, nil
+}
+
+// endpoint returns the base URL of service in the configured region.
+func (a AWS) endpoint(service string) (string, error) {
+	if a.Endpoint != "" {
+		return strings.TrimSuffix(a.Endpoint, "/"), nil
+	}
+	if a.Region == "" {
+		return "", errors.New("no AWS region: set AWS_REGION, or give WithAWS a Region")
+	}
+	return "https://" + service + "." + a.Region + ".amazonaws.com", nil
+}
+
+// awsError is an error returned by an AWS service.
+type awsError struct {
+	Type    string
+	Message string
+}
+
+func (e *awsError) Error() string {
+	if e.Message == "" {
+		return e.Type
+	}
+	return e.Type + ": " + e.Message
+}
+
+// callJSON calls target, e.g. "AmazonSSM.GetParameter", of an AWS service
+// speaking the JSON protocol, and decodes the response into out.
+func (a AWS) callJSON(ctx context.Context, service, target string, body, out interface{}) error {
+	endpoint, err := a.endpoint(service)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	resp, err := a.do(req, payload, service)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &e)
+		if e.Type == "" {
+			return fmt.Errorf("%s: unexpected status %s", service, resp.Status)
+		}
+		// The type may be qualified, as in "com.amazonaws...#ParameterNotFound".
+		e.Type = e.Type[strings.LastIndex(e.Type, "#")+1:]
+		return &awsError{e.Type, e.Message}
+	}
+	return json.Unmarshal(data, out)
+}
+
+// do signs req, whose body is payload, for service and sends it.
+func (a AWS) do(req *http.Request, payload []byte, service string) (*http.Response, error) {
+	creds, err := a.Credentials(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	signAWS(req, payload, service, a.Region, creds, time.Now())
+	return a.Client.Do(req)
+}
+
+// signAWS signs req with Signature Version 4, covering its host, content type
+// and X-Amz-* headers and payload.
+func signAWS(req *http.Request, payload []byte, service, region string, creds AWSCredentials, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query as Signature Version 4 requires: sorted, with
+// spaces as %20.
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,127 @@
+package goconfig
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a number of bytes that config files, environment variables and
+// flags can give with a unit, as in "64MiB" or "10GB". Both SI units (kB, MB,
+// GB, TB, PB, EB, powers of 1000) and IEC units (KiB, MiB, GiB, TiB, PiB, EiB,
+// powers of 1024) are understood, regardless of case and with or without the
+// B, and a plain number is a number of bytes. Save and Dump write it back with
+// the largest unit that divides it exactly.
+type ByteSize uint64
+
+// Byte sizes in SI and IEC units.
+const (
+	Byte ByteSize = 1
+	KB            = 1000 * Byte
+	MB            = 1000 * KB
+	GB            = 1000 * MB
+	TB            = 1000 * GB
+	PB            = 1000 * TB
+	EB            = 1000 * PB
+	KiB           = 1024 * Byte
+	MiB           = 1024 * KiB
+	GiB           = 1024 * MiB
+	TiB           = 1024 * GiB
+	PiB           = 1024 * TiB
+	EiB           = 1024 * PiB
+)
+
+var byteSizeType = reflect.TypeOf(ByteSize(0))
+
+// byteUnits lists the units by name, largest first within each system so that
+// String picks the largest one that divides a size.
+var byteUnits = []struct {
+	name string
+	size ByteSize
+}{
+	{"EiB", EiB}, {"PiB", PiB}, {"TiB", TiB}, {"GiB", GiB}, {"MiB", MiB}, {"KiB", KiB},
+	{"EB", EB}, {"PB", PB}, {"TB", TB}, {"GB", GB}, {"MB", MB}, {"kB", KB},
+	{"B", Byte},
+}
+
+// byteUnitAliases are the short forms of the units, as used by Kubernetes
+// quantities among others.
+var byteUnitAliases = []struct {
+	name string
+	size ByteSize
+}{
+	{"Ei", EiB}, {"Pi", PiB}, {"Ti", TiB}, {"Gi", GiB}, {"Mi", MiB}, {"Ki", KiB},
+	{"E", EB}, {"P", PB}, {"T", TB}, {"G", GB}, {"M", MB}, {"k", KB},
+}
+
+// ParseByteSize parses a byte size such as "64MiB", "10GB", "1.5 GiB" or
+// "4096".
+func ParseByteSize(s string) (ByteSize, error) {
+	trimmed := strings.TrimSpace(s)
+	i := strings.IndexFunc(trimmed, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(trimmed)
+	}
+	number, unit := trimmed[:i], strings.TrimSpace(trimmed[i:])
+	size := Byte
+	if unit != "" {
+		found := false
+		for _, u := range append(byteUnits, byteUnitAliases...) {
+			if strings.EqualFold(unit, u.name) {
+				size, found = u.size, true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid byte size %q, unknown unit %q", s, unit)
+		}
+	}
+	if n, err := strconv.ParseUint(number, 10, 64); err == nil {
+		if n > math.MaxUint64/uint64(size) {
+			return 0, fmt.Errorf("byte size %q is too large", s)
+		}
+		return ByteSize(n) * size, nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil || number == "" {
+		return 0, fmt.Errorf("invalid byte size %q, expected a number with an optional unit such as 64MiB or 10GB", s)
+	}
+	bytes := math.Round(f * float64(size))
+	if bytes >= math.MaxUint64 {
+		return 0, fmt.Errorf("byte size %q is too large", s)
+	}
+	return ByteSize(bytes), nil
+}
+
+// String formats b with the largest unit that divides it exactly, preferring
+// IEC units, as in "64MiB" or "10GB".
+func (b ByteSize) String() string {
+	if b == 0 {
+		return "0B"
+	}
+	for _, u := range byteUnits {
+		if b%u.size == 0 {
+			return strconv.FormatUint(uint64(b/u.size), 10) + u.name
+		}
+	}
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+// MarshalText formats b as String does.
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText parses a byte size as ParseByteSize does.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	size, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
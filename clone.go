@@ -0,0 +1,166 @@
+package goconfig
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+var (
+	mutexType   = reflect.TypeOf(sync.Mutex{})
+	rwMutexType = reflect.TypeOf(sync.RWMutex{})
+	// configType is the type of Config, whose unexported fields hold the
+	// filename, env prefix and listening state of the config rather than
+	// its values.
+	configType = reflect.TypeOf(Config{})
+)
+
+// Snapshot returns a deep copy of config, taken with it read-locked, which can
+// be used without locking however config is reloaded in the meantime. It is
+// of the same type as config.
+func Snapshot(config interface{}) interface{} {
+	c := configOf(config)
+	c.RLock()
+	defer c.RUnlock()
+	if m, ok := c.(managedConfig); ok {
+		return m.snapshot()
+	}
+	return copyConfig(c)
+}
+
+// Clone returns a deep copy of v: the slices, maps and pointers within it are
+// copied too, rather than shared with v. Mutexes are reset in the copy, and
+// unexported fields are copied as is. Clone doesn't lock anything, see
+// Snapshot for that.
+func Clone[T any](v T) T {
+	return deepCopy(reflect.ValueOf(&v)).Elem().Interface().(T)
+}
+
+// copier deep-copies values, keeping track of the pointers already copied so
+// that shared and cyclic pointers stay that way in the copy.
+type copier struct {
+	ptrs map[copiedPtr]reflect.Value
+}
+
+// copiedPtr identifies a pointer copied by a copier. Its type is part of it,
+// as a pointer to a struct and one to its first field share an address.
+type copiedPtr struct {
+	typ  reflect.Type
+	addr uintptr
+}
+
+func deepCopy(v reflect.Value) reflect.Value {
+	return (&copier{ptrs: map[copiedPtr]reflect.Value{}}).copy(v)
+}
+
+// copy returns a deep copy of v. Exported struct fields, slices, maps, arrays,
+// interfaces and pointers are copied recursively, mutexes are reset and
+// anything else, including unexported fields, is copied as is.
+func (cp *copier) copy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		key := copiedPtr{v.Type(), v.Pointer()}
+		if copied, ok := cp.ptrs[key]; ok {
+			return copied
+		}
+		copied := reflect.New(v.Type().Elem())
+		cp.ptrs[key] = copied
+		copied.Elem().Set(cp.copy(v.Elem()))
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(cp.copy(v.Elem()))
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(cp.copy(v.Index(i)))
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(cp.copy(v.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(cp.copy(iter.Key()), cp.copy(iter.Value()))
+		}
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		if t := v.Type(); t == mutexType || t == rwMutexType {
+			return copied
+		}
+		if !v.CanAddr() {
+			addressable := reflect.New(v.Type()).Elem()
+			addressable.Set(v)
+			v = addressable
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				settable(copied.Field(i)).Set(readable(v.Field(i)))
+				continue
+			}
+			copied.Field(i).Set(cp.copy(v.Field(i)))
+		}
+		return copied
+	}
+	copied := reflect.New(v.Type()).Elem()
+	copied.Set(v)
+	return copied
+}
+
+// assignConfig sets the fields of the struct dst, which must be addressable, to
+// those of src, leaving its mutexes alone so that locks held on dst stay held,
+// and the unexported fields of an embedded Config, which may have changed
+// since src was copied from dst.
+func assignConfig(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Field(i)
+		if t := field.Type(); t == mutexType || t == rwMutexType {
+			continue
+		}
+		if dst.Type() == configType && dst.Type().Field(i).PkgPath != "" {
+			continue
+		}
+		if field.Kind() == reflect.Struct {
+			assignConfig(field, src.Field(i))
+			continue
+		}
+		settable(field).Set(readable(src.Field(i)))
+	}
+}
+
+// settable returns the addressable value v, which may be an unexported field,
+// in a form that can be assigned to.
+func settable(v reflect.Value) reflect.Value {
+	if v.CanSet() {
+		return v
+	}
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// readable returns the addressable value v, which may be an unexported field,
+// in a form whose value can be read.
+func readable(v reflect.Value) reflect.Value {
+	if v.CanInterface() {
+		return v
+	}
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
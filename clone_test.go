@@ -0,0 +1,24 @@
+package goconfig
+
+import "testing"
+
+func TestCloneSharedAddresses(t *testing.T) {
+	type inner struct {
+		Port int
+	}
+	type config struct {
+		Server *inner
+		Port   *int
+		Alias  *inner
+	}
+	server := &inner{Port: 80}
+	// Port points to the first field of *Server, at the same address.
+	c := config{Server: server, Port: &server.Port, Alias: server}
+	copied := Clone(c)
+	if copied.Server == server || copied.Server.Port != 80 || *copied.Port != 80 {
+		t.Fatalf("copy = %+v", copied)
+	}
+	if copied.Alias != copied.Server {
+		t.Error("shared pointer not shared in the copy")
+	}
+}
@@ -0,0 +1,1042 @@
+// Command goconfig-gen generates the code that applies the defaults, reads the
+// environment variables, checks the required fields and copies a config
+// struct for goconfig, so that Load doesn't need reflection to do so. Run it
+// from the package of the struct with go generate:
+//
+//	//go:generate go run github.com/santiclause/goconfig/cmd/goconfig-gen -type Config
+//
+// It writes the methods of goconfig.Generated for each type listed to
+// <type>_goconfig.go, or to the file given with -output. The fields of types
+// declared in the package, and of the basic types, are handled by the
+// generated code itself; those of other types, such as time.Time or
+// goconfig.Config, are handed to goconfig, which uses reflection for them.
+//
+// Decode hooks registered for types of the package aren't seen by the
+// generated code, and `required_if` and `required_with` tags aren't
+// supported.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const goconfigPath = "github.com/santiclause/goconfig"
+
+func main() {
+	typeNames := flag.String("type", "", "comma separated names of the config types")
+	output := flag.String("output", "", "output file (default <type>_goconfig.go)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: goconfig-gen -type T[,T...] [-output file] [directory]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	names := strings.Split(*typeNames, ",")
+	filename := *output
+	if filename == "" {
+		filename = strings.ToLower(names[0]) + "_goconfig.go"
+	}
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(dir, filename)
+	}
+	src, err := generate(dir, filepath.Base(filename), names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goconfig-gen:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filename, src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "goconfig-gen:", err)
+		os.Exit(1)
+	}
+}
+
+// generate returns the code for the types called names in the package in
+// dir, leaving out output, the file it is written to.
+func generate(dir, output string, names []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != output
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+	g := &generator{fset: fset, types: map[string]*typeDecl{}, textual: map[string]bool{}, imports: map[string]string{}}
+	for _, pkg := range pkgs {
+		g.pkg = pkg.Name
+		for _, file := range pkg.Files {
+			g.addFile(file)
+		}
+	}
+	var body bytes.Buffer
+	for _, name := range names {
+		decl, ok := g.types[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found in %s", name, dir)
+		}
+		if _, ok := decl.expr.(*ast.StructType); !ok {
+			return nil, fmt.Errorf("type %s is not a struct", name)
+		}
+		if err := g.generateType(&body, name); err != nil {
+			return nil, fmt.Errorf("type %s: %w", name, err)
+		}
+	}
+	g.imports["goconfig"] = goconfigPath
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by goconfig-gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.pkg)
+	var std, others []string
+	for name, path := range g.imports {
+		spec := strconv.Quote(path)
+		if name != filepath.Base(path) {
+			spec = name + " " + spec
+		}
+		if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+			others = append(others, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+	fmt.Fprintf(&out, "%s\n\n%s\n)\n", strings.Join(std, "\n"), strings.Join(others, "\n"))
+	out.Write(body.Bytes())
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting the generated code: %w\n%s", err, out.Bytes())
+	}
+	return formatted, nil
+}
+
+// typeDecl is a type declared in the package.
+type typeDecl struct {
+	expr ast.Expr
+	// imports are the import paths of the file declaring it, by name.
+	imports map[string]string
+}
+
+type generator struct {
+	fset  *token.FileSet
+	pkg   string
+	types map[string]*typeDecl
+	// textual holds the types of the package with an UnmarshalText method,
+	// which are parsed from strings rather than loaded field by field.
+	textual map[string]bool
+	// imports holds the paths of the packages the generated code imports, by
+	// name. The package of goconfig may be imported under both its own name
+	// and the one the config's file gives it.
+	imports map[string]string
+	// file is the imports of the file declaring the type being generated.
+	file map[string]string
+	// vars numbers the loop variables.
+	vars int
+	// visiting holds the types being generated, to reject recursive ones.
+	visiting map[string]bool
+}
+
+func (g *generator) addFile(file *ast.File) {
+	imports := map[string]string{}
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := filepath.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = path
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok && ts.TypeParams == nil {
+					g.types[ts.Name.Name] = &typeDecl{expr: ts.Type, imports: imports}
+				}
+			}
+		case *ast.FuncDecl:
+			if decl.Recv != nil && len(decl.Recv.List) == 1 && decl.Name.Name == "UnmarshalText" {
+				t := decl.Recv.List[0].Type
+				if star, ok := t.(*ast.StarExpr); ok {
+					t = star.X
+				}
+				if ident, ok := t.(*ast.Ident); ok {
+					g.textual[ident.Name] = true
+				}
+			}
+		}
+	}
+}
+
+// generateType writes the methods of goconfig.Generated for the type name.
+func (g *generator) generateType(w *bytes.Buffer, name string) error {
+	decl := g.types[name]
+	g.file = decl.imports
+	g.visiting = map[string]bool{name: true}
+	st := decl.expr.(*ast.StructType)
+	var defaults, env, missing, copies bytes.Buffer
+	if err := g.structDefaults(&defaults, "c", st, ""); err != nil {
+		return err
+	}
+	if err := g.structEnv(&env, "c", st); err != nil {
+		return err
+	}
+	if err := g.structMissing(&missing, "c", st, path{}, path{}); err != nil {
+		return err
+	}
+	if err := g.structCopy(&copies, "cp", "c", st); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nvar _ goconfig.Generated = (*%s)(nil)\n", name)
+	fmt.Fprintf(w, "\n// GoconfigDefaults applies the `default` tags of c.\nfunc (c *%s) GoconfigDefaults() error {\n%sreturn nil\n}\n", name, defaults.Bytes())
+	g.imports["errors"] = "errors"
+	g.imports["strings"] = "strings"
+	fmt.Fprintf(w, "\n// GoconfigEnv reads c from the environment variables in its `env` tags,\n// each prefixed with prefix.\nfunc (c *%s) GoconfigEnv(prefix string) error {\nvar errs []string\n%sif errs != nil {\nreturn errors.New(strings.Join(errs, \". \"))\n}\nreturn nil\n}\n", name, env.Bytes())
+	fmt.Fprintf(w, "\n// GoconfigMissing returns the required fields of c that are unset.\nfunc (c *%s) GoconfigMissing(prefix string) []goconfig.MissingField {\nvar missing []goconfig.MissingField\n%sreturn missing\n}\n", name, missing.Bytes())
+	fmt.Fprintf(w, "\n// GoconfigCopy returns a deep copy of c.\nfunc (c *%s) GoconfigCopy() interface{} {\ncp := new(%s)\n%sreturn cp\n}\n", name, name, copies.Bytes())
+	return nil
+}
+
+// field is a field of a struct being generated.
+type field struct {
+	name     string
+	typ      ast.Expr
+	tag      reflect.StructTag
+	embedded bool
+}
+
+// fields returns the fields of st.
+func fields(st *ast.StructType) ([]field, error) {
+	var list []field
+	for _, f := range st.Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s)
+		}
+		if len(f.Names) == 0 {
+			list = append(list, field{name: embeddedName(f.Type), typ: f.Type, tag: tag, embedded: true})
+			continue
+		}
+		for _, name := range f.Names {
+			if name.Name != "_" {
+				list = append(list, field{name: name.Name, typ: f.Type, tag: tag})
+			}
+		}
+	}
+	return list, nil
+}
+
+func embeddedName(t ast.Expr) string {
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// kind classifies the types of fields.
+type kind int
+
+const (
+	// basic is a predeclared string, bool or number type.
+	basicKind kind = iota
+	// local is a struct type of the package, or a struct literal type.
+	localKind
+	pointerKind
+	sliceKind
+	arrayKind
+	mapKind
+	// mutex is sync.Mutex or sync.RWMutex, which copies leave unset.
+	mutexKind
+	// value is a type copied by assignment: interfaces, functions,
+	// channels and the types of other packages known to be values.
+	valueKind
+	// other is any other type, handled by goconfig.
+	otherKind
+)
+
+var basicTypes = map[string]int{
+	"string": 0, "bool": 0,
+	"int": 64, "int8": 8, "int16": 16, "int32": 32, "int64": 64, "rune": 32,
+	"uint": 64, "uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64, "byte": 8, "uintptr": 64,
+	"float32": 32, "float64": 64,
+}
+
+// valueTypes are the types of other packages that are copied by assignment.
+var valueTypes = map[string]bool{
+	"time.Duration": true, "time.Time": true, "time.Month": true, "time.Weekday": true,
+	"net/netip.Addr": true, "net/netip.Prefix": true, "net/netip.AddrPort": true,
+}
+
+// kindOf returns the kind of t and, for a struct, its type. Named types are
+// resolved to their underlying types, except for the textual ones.
+func (g *generator) kindOf(t ast.Expr) (kind, *ast.StructType, error) {
+	switch t := t.(type) {
+	case *ast.Ident:
+		if _, ok := basicTypes[t.Name]; ok {
+			return basicKind, nil, nil
+		}
+		decl, ok := g.types[t.Name]
+		if !ok {
+			return otherKind, nil, nil
+		}
+		if g.textual[t.Name] {
+			return otherKind, nil, nil
+		}
+		if st, ok := decl.expr.(*ast.StructType); ok {
+			return localKind, st, nil
+		}
+		k, _, err := g.kindOf(decl.expr)
+		if k == basicKind || k == localKind {
+			// A named basic type may parse itself with a decode hook.
+			k = otherKind
+		}
+		return k, nil, err
+	case *ast.StructType:
+		return localKind, t, nil
+	case *ast.StarExpr:
+		return pointerKind, nil, nil
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return sliceKind, nil, nil
+		}
+		return arrayKind, nil, nil
+	case *ast.MapType:
+		return mapKind, nil, nil
+	case *ast.InterfaceType, *ast.FuncType, *ast.ChanType:
+		return valueKind, nil, nil
+	case *ast.SelectorExpr:
+		pkg, _ := t.X.(*ast.Ident)
+		if pkg != nil && g.file[pkg.Name] == "sync" && (t.Sel.Name == "Mutex" || t.Sel.Name == "RWMutex") {
+			return mutexKind, nil, nil
+		}
+		if pkg != nil && valueTypes[g.file[pkg.Name]+"."+t.Sel.Name] {
+			return valueKind, nil, nil
+		}
+		return otherKind, nil, nil
+	case *ast.ParenExpr:
+		return g.kindOf(t.X)
+	}
+	return 0, nil, fmt.Errorf("unsupported type %s", g.render(t))
+}
+
+// elem returns the element type of a pointer, slice, array or map type.
+func (g *generator) elem(t ast.Expr) ast.Expr {
+	if ident, ok := t.(*ast.Ident); ok {
+		if decl, ok := g.types[ident.Name]; ok {
+			return g.elem(decl.expr)
+		}
+	}
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		return t.X
+	case *ast.ArrayType:
+		return t.Elt
+	case *ast.MapType:
+		return t.Value
+	case *ast.ParenExpr:
+		return g.elem(t.X)
+	}
+	return nil
+}
+
+// isBasic reports whether t is a predeclared basic type.
+func isBasic(t ast.Expr) bool {
+	ident, ok := t.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, ok = basicTypes[ident.Name]
+	return ok
+}
+
+// render returns the source of t, adding the imports it needs.
+func (g *generator) render(t ast.Expr) string {
+	ast.Inspect(t, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok {
+				if path, ok := g.file[pkg.Name]; ok {
+					g.imports[pkg.Name] = path
+				}
+			}
+		}
+		return true
+	})
+	var buf bytes.Buffer
+	printer.Fprint(&buf, g.fset, t)
+	return buf.String()
+}
+
+// exported reports whether goconfig loads f, as it only loads exported
+// fields and embedded structs.
+func (g *generator) exported(f field) bool {
+	if ast.IsExported(f.name) {
+		return true
+	}
+	k, _, _ := g.kindOf(f.typ)
+	return f.embedded && k == localKind
+}
+
+// yamlKey returns the key of f in config files.
+func (g *generator) yamlKey(f field) string {
+	name, opts, _ := strings.Cut(f.tag.Get("yaml"), ",")
+	if strings.Contains(","+opts+",", ",inline,") {
+		return ""
+	}
+	if name == "" && f.embedded {
+		t := f.typ
+		if star, ok := t.(*ast.StarExpr); ok {
+			t = star.X
+		}
+		if k, _, _ := g.kindOf(t); k == localKind {
+			return ""
+		}
+	}
+	if name == "" {
+		return strings.ToLower(f.name)
+	}
+	return name
+}
+
+// fallback returns the goconfig.GeneratedField literal for f.
+func (g *generator) fallback(f field) string {
+	lit := fmt.Sprintf("goconfig.GeneratedField{Name: %q, Tag: %s", f.name, strconv.Quote(string(f.tag)))
+	if f.embedded {
+		lit += ", Embedded: true"
+	}
+	return "(" + lit + "})"
+}
+
+// enter marks the type t, if it is named, as being generated, failing if it
+// already is. leave must be called once it is done.
+func (g *generator) enter(t ast.Expr) (leave func(), err error) {
+	ident, ok := t.(*ast.Ident)
+	if !ok {
+		return func() {}, nil
+	}
+	if g.visiting[ident.Name] {
+		return nil, fmt.Errorf("recursive type %s isn't supported", ident.Name)
+	}
+	g.visiting[ident.Name] = true
+	return func() { delete(g.visiting, ident.Name) }, nil
+}
+
+// newVar returns a new variable name starting with prefix.
+func (g *generator) newVar(prefix string) string {
+	g.vars++
+	return fmt.Sprintf("%s%d", prefix, g.vars)
+}
+
+// structDefaults writes the code applying the defaults of the fields of st,
+// held by the expression v, whose Go path is parent.
+func (g *generator) structDefaults(w *bytes.Buffer, v string, st *ast.StructType, parent string) error {
+	list, err := fields(st)
+	if err != nil {
+		return err
+	}
+	for _, f := range list {
+		if !g.exported(f) {
+			continue
+		}
+		expr, name := v+"."+f.name, joinPath(parent, f.name)
+		k, inner, err := g.kindOf(f.typ)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+		if def, ok := f.tag.Lookup("default"); ok {
+			if lit, zero, ok, err := g.literal(f, def); err != nil {
+				return fmt.Errorf("invalid default for %s: %w", name, err)
+			} else if ok {
+				fmt.Fprintf(w, "if %s {\n%s = %s\n}\n", fmt.Sprintf(zero, expr), expr, lit)
+				continue
+			}
+			fmt.Fprintf(w, "if err := %s.Defaults(&%s, %q); err != nil {\nreturn err\n}\n", g.fallback(f), expr, parent)
+			continue
+		}
+		switch k {
+		case localKind:
+			leave, err := g.enter(f.typ)
+			if err != nil {
+				return err
+			}
+			err = g.structDefaults(w, expr, inner, name)
+			leave()
+			if err != nil {
+				return err
+			}
+		case pointerKind:
+			elem := g.elem(f.typ)
+			if ek, inner, _ := g.kindOf(elem); ek == localKind {
+				leave, err := g.enter(elem)
+				if err != nil {
+					return err
+				}
+				var body bytes.Buffer
+				err = g.structDefaults(&body, expr, inner, name)
+				leave()
+				if err != nil {
+					return err
+				}
+				if body.Len() > 0 {
+					fmt.Fprintf(w, "if %s != nil {\n%s}\n", expr, body.Bytes())
+				}
+			} else if ek == otherKind {
+				fmt.Fprintf(w, "if err := %s.Defaults(&%s, %q); err != nil {\nreturn err\n}\n", g.fallback(f), expr, parent)
+			}
+		case otherKind:
+			if _, ok := f.typ.(*ast.SelectorExpr); ok {
+				// It may be a struct with defaults of its own.
+				fmt.Fprintf(w, "if err := %s.Defaults(&%s, %q); err != nil {\nreturn err\n}\n", g.fallback(f), expr, parent)
+			}
+		}
+	}
+	return nil
+}
+
+// literal returns the Go literal for s as a value of the type of the field f,
+// with the format of the condition for a value of it to be zero, if it is a
+// basic type or a slice of them. ok is false for other types, and for fields
+// with a `base` tag, which are left to goconfig.
+func (g *generator) literal(f field, s string) (lit, zero string, ok bool, err error) {
+	t := f.typ
+	if _, ok := f.tag.Lookup("base"); ok {
+		return "", "", false, nil
+	}
+	if isBasic(t) {
+		lit, err := basicLiteral(t.(*ast.Ident).Name, s)
+		return lit, zeroCheck(t.(*ast.Ident).Name), true, err
+	}
+	array, isSlice := t.(*ast.ArrayType)
+	if !isSlice || array.Len != nil || !isBasic(array.Elt) {
+		return "", "", false, nil
+	}
+	var items []string
+	if s != "" {
+		for _, part := range strings.Split(s, ",") {
+			item, err := basicLiteral(array.Elt.(*ast.Ident).Name, strings.TrimSpace(part))
+			if err != nil {
+				return "", "", false, err
+			}
+			items = append(items, item)
+		}
+	}
+	return g.render(t) + "{" + strings.Join(items, ", ") + "}", "%s == nil", true, nil
+}
+
+// basicLiteral returns the Go literal for s as a value of the basic type
+// called name.
+func basicLiteral(name, s string) (string, error) {
+	bits := basicTypes[name]
+	switch name {
+	case "string":
+		return strconv.Quote(s), nil
+	case "bool":
+		b, err := strconv.ParseBool(s)
+		return strconv.FormatBool(b), err
+	case "int", "int8", "int16", "int32", "int64", "rune":
+		i, err := strconv.ParseInt(s, 10, bits)
+		return strconv.FormatInt(i, 10), err
+	case "float32", "float64":
+		f, err := strconv.ParseFloat(s, bits)
+		lit := strconv.FormatFloat(f, 'g', -1, bits)
+		if err == nil && strings.ContainsAny(lit, "IN") {
+			err = errors.New("not a finite number")
+		}
+		return lit, err
+	}
+	u, err := strconv.ParseUint(s, 10, bits)
+	return strconv.FormatUint(u, 10), err
+}
+
+// zeroCheck returns the format of the condition for a value of the basic type
+// called name to be zero.
+func zeroCheck(name string) string {
+	switch name {
+	case "string":
+		return `%s == ""`
+	case "bool":
+		return "!%s"
+	}
+	return "%s == 0"
+}
+
+// structEnv writes the code reading the fields of st, held by the expression
+// v, from the environment.
+func (g *generator) structEnv(w *bytes.Buffer, v string, st *ast.StructType) error {
+	list, err := fields(st)
+	if err != nil {
+		return err
+	}
+	for _, f := range list {
+		if !g.exported(f) {
+			continue
+		}
+		expr := v + "." + f.name
+		k, inner, err := g.kindOf(f.typ)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+		_, hasEnv := f.tag.Lookup("env")
+		_, hasFile := f.tag.Lookup("envFile")
+		_, hasDefault := f.tag.Lookup("envDefault")
+		tagged := hasEnv || hasFile || hasDefault
+		switch {
+		case k == localKind:
+			leave, err := g.enter(f.typ)
+			if err != nil {
+				return err
+			}
+			err = g.structEnv(w, expr, inner)
+			leave()
+			if err != nil {
+				return err
+			}
+		case k == pointerKind:
+			elem := g.elem(f.typ)
+			ek, inner, _ := g.kindOf(elem)
+			if ek != localKind {
+				if tagged || ek == otherKind {
+					fmt.Fprintf(w, "errs = append(errs, %s.Env(&%s, prefix)...)\n", g.fallback(f), expr)
+				}
+				continue
+			}
+			leave, err := g.enter(elem)
+			if err != nil {
+				return err
+			}
+			var body bytes.Buffer
+			err = g.structEnv(&body, expr, inner)
+			leave()
+			if err != nil {
+				return err
+			}
+			if body.Len() > 0 {
+				fmt.Fprintf(w, "if %s != nil {\n%s}\n", expr, body.Bytes())
+			}
+		case !tagged:
+			if _, ok := f.typ.(*ast.SelectorExpr); ok && k == otherKind {
+				// It may be a struct with `env` tags of its own.
+				fmt.Fprintf(w, "errs = append(errs, %s.Env(&%s, prefix)...)\n", g.fallback(f), expr)
+			}
+		default:
+			parse, ok := g.parser(f, expr)
+			if !ok {
+				fmt.Fprintf(w, "errs = append(errs, %s.Env(&%s, prefix)...)\n", g.fallback(f), expr)
+				continue
+			}
+			if err := g.fieldEnv(w, f, parse); err != nil {
+				return fmt.Errorf("field %s: %w", f.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// fieldEnv writes the code reading the field f from the environment, which
+// parse sets from value once it is read.
+func (g *generator) fieldEnv(w *bytes.Buffer, f field, parse string) error {
+	key, opts, _ := strings.Cut(f.tag.Get("env"), ",")
+	required := false
+	for _, opt := range strings.Split(opts, ",") {
+		switch opt {
+		case "":
+		case "required":
+			required = true
+		default:
+			return fmt.Errorf("env tag option %s not supported", opt)
+		}
+	}
+	name, fileName := `""`, `""`
+	if key != "" {
+		name = fmt.Sprintf("prefix + %q", key)
+		fileName = fmt.Sprintf("prefix + %q", key+"_FILE")
+	}
+	if file := f.tag.Get("envFile"); file != "" {
+		fileName = fmt.Sprintf("prefix + %q", file)
+	}
+	from := "from"
+	if !required && !strings.Contains(parse, "from") {
+		from = "_"
+	}
+	fmt.Fprintf(w, "if value, %s, err := goconfig.LookupEnv(%s, %s); err != nil {\nerrs = append(errs, err.Error())\n", from, name, fileName)
+	if required {
+		fmt.Fprintf(w, "} else if value == \"\" {\nerrs = append(errs, \"Required environment variable \"+from+\" is not set\")\n")
+	}
+	fmt.Fprintf(w, "} else {\n")
+	if def := f.tag.Get("envDefault"); def != "" {
+		fmt.Fprintf(w, "if value == \"\" {\nvalue = %q\n}\n", def)
+	}
+	fmt.Fprintf(w, "if value != \"\" {\n%s}\n}\n", parse)
+	return nil
+}
+
+// parser returns the code setting expr, the field f, from the string in
+// value, if it is of a basic type or a slice of them and has no `base` tag,
+// adding an error named by from if it fails.
+func (g *generator) parser(f field, expr string) (string, bool) {
+	if _, ok := f.tag.Lookup("base"); ok {
+		return "", false
+	}
+	if isBasic(f.typ) {
+		name := f.typ.(*ast.Ident).Name
+		if name == "string" {
+			return expr + " = value\n", true
+		}
+		return fmt.Sprintf("if v, err := %s; err != nil {\nerrs = append(errs, from+\": \"+err.Error())\n} else {\n%s = %s\n}\n", g.parseCall(name, "value"), expr, convert(name, "v")), true
+	}
+	array, ok := f.typ.(*ast.ArrayType)
+	if !ok || array.Len != nil || !isBasic(array.Elt) {
+		return "", false
+	}
+	name := array.Elt.(*ast.Ident).Name
+	separator := f.tag.Get("envSeparator")
+	if separator == "" {
+		separator = ","
+	}
+	list, i, part := g.newVar("list"), g.newVar("i"), g.newVar("part")
+	var b strings.Builder
+	fmt.Fprintf(&b, "parts := strings.Split(value, %q)\n%s := make(%s, len(parts))\nvar failed error\n", separator, list, g.render(f.typ))
+	fmt.Fprintf(&b, "for %s, %s := range parts {\n", i, part)
+	if name == "string" {
+		fmt.Fprintf(&b, "%s[%s] = strings.TrimSpace(%s)\n", list, i, part)
+	} else {
+		fmt.Fprintf(&b, "v, err := %s\nif err != nil {\nfailed = err\nbreak\n}\n%s[%s] = %s\n", g.parseCall(name, "strings.TrimSpace("+part+")"), list, i, convert(name, "v"))
+	}
+	fmt.Fprintf(&b, "}\nif failed != nil {\nerrs = append(errs, from+\": \"+failed.Error())\n} else {\n%s = %s\n}\n", expr, list)
+	return b.String(), true
+}
+
+// parseCall returns the strconv call parsing s as a value of the basic type
+// called name.
+func (g *generator) parseCall(name, s string) string {
+	g.imports["strconv"] = "strconv"
+	bits := basicTypes[name]
+	if name == "int" || name == "uint" || name == "uintptr" {
+		bits = 0
+	}
+	switch name {
+	case "bool":
+		return fmt.Sprintf("strconv.ParseBool(%s)", s)
+	case "int", "int8", "int16", "int32", "int64", "rune":
+		return fmt.Sprintf("strconv.ParseInt(%s, 10, %d)", s, bits)
+	case "float32", "float64":
+		return fmt.Sprintf("strconv.ParseFloat(%s, %d)", s, bits)
+	}
+	return fmt.Sprintf("strconv.ParseUint(%s, 10, %d)", s, bits)
+}
+
+// convert converts v, as parsed by parseCall, to the basic type called name.
+func convert(name, v string) string {
+	switch name {
+	case "bool", "int64", "uint64", "float64":
+		return v
+	}
+	return name + "(" + v + ")"
+}
+
+// path is a string built at run time, as Go expressions yielding its parts.
+type path []string
+
+// child returns the path of name below p, joined with a dot.
+func (p path) child(name string) path {
+	if name == "" {
+		return p
+	}
+	if len(p) == 0 {
+		return path{strconv.Quote(name)}
+	}
+	return append(p[:len(p):len(p)], strconv.Quote("."+name))
+}
+
+// index returns the path of the element of p with the index or key expr.
+func (p path) index(expr string) path {
+	return append(p[:len(p):len(p)], `"["`, expr, `"]"`)
+}
+
+func (p path) String() string {
+	if len(p) == 0 {
+		return `""`
+	}
+	var parts []string
+	for _, part := range p {
+		if s, err := strconv.Unquote(part); err == nil && len(parts) > 0 {
+			if last, err := strconv.Unquote(parts[len(parts)-1]); err == nil {
+				parts[len(parts)-1] = strconv.Quote(last + s)
+				continue
+			}
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " + ")
+}
+
+// structMissing writes the code adding the required fields of st, held by
+// the expression v, whose Go and key paths are goPath and keyPath, that are
+// unset to missing.
+func (g *generator) structMissing(w *bytes.Buffer, v string, st *ast.StructType, goPath, keyPath path) error {
+	list, err := fields(st)
+	if err != nil {
+		return err
+	}
+	for _, f := range list {
+		if !g.exported(f) {
+			continue
+		}
+		if _, ok := f.tag.Lookup("required_if"); ok {
+			return fmt.Errorf("field %s: required_if isn't supported", f.name)
+		}
+		if _, ok := f.tag.Lookup("required_with"); ok {
+			return fmt.Errorf("field %s: required_with isn't supported", f.name)
+		}
+		expr := v + "." + f.name
+		k, inner, err := g.kindOf(f.typ)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+		name, key := goPath.child(f.name), keyPath.child(g.yamlKey(f))
+		required := f.tag.Get("required") == "true"
+		fallback := fmt.Sprintf("missing = append(missing, %s.Missing(&%s, %s, %s, prefix)...)\n", g.fallback(f), expr, goPath, keyPath)
+		var zero string
+		switch {
+		case k == basicKind:
+			zero = fmt.Sprintf(zeroCheck(f.typ.(*ast.Ident).Name), expr)
+		case k == pointerKind || k == sliceKind || k == mapKind:
+			zero = expr + " == nil"
+			if ek, _, _ := g.kindOf(g.elem(f.typ)); k == pointerKind && ek == otherKind && !required {
+				// It may point to a struct with required fields of its own.
+				w.WriteString(fallback)
+				continue
+			}
+		case k == valueKind && !required:
+			continue
+		case k == otherKind || k == valueKind || required:
+			w.WriteString(fallback)
+			continue
+		}
+		var nested bytes.Buffer
+		if err := g.nestedMissing(&nested, expr, f.typ, k, inner, name, key); err != nil {
+			return err
+		}
+		switch {
+		case required && nested.Len() > 0:
+			fmt.Fprintf(w, "if %s {\n%s} else {\n%s}\n", zero, g.missingField(f, name, key), nested.Bytes())
+		case required:
+			fmt.Fprintf(w, "if %s {\n%s}\n", zero, g.missingField(f, name, key))
+		default:
+			w.Write(nested.Bytes())
+		}
+	}
+	return nil
+}
+
+// missingField returns the code adding the field f, at name and key, to
+// missing.
+func (g *generator) missingField(f field, name, key path) string {
+	env := `""`
+	if key, _, _ := strings.Cut(f.tag.Get("env"), ","); key != "" {
+		env = fmt.Sprintf("prefix + %q", key)
+	}
+	return fmt.Sprintf("missing = append(missing, goconfig.MissingField{Path: %s, YAML: %s, Env: %s})\n", name, key, env)
+}
+
+// nestedMissing writes the code checking the required fields of the structs
+// held by expr, of type t and kind k, whose paths are name and key.
+func (g *generator) nestedMissing(w *bytes.Buffer, expr string, t ast.Expr, k kind, inner *ast.StructType, name, key path) error {
+	switch k {
+	case localKind:
+		leave, err := g.enter(t)
+		if err != nil {
+			return err
+		}
+		defer leave()
+		return g.structMissing(w, expr, inner, name, key)
+	case pointerKind:
+		elem := g.elem(t)
+		ek, inner, err := g.kindOf(elem)
+		if err != nil || ek != localKind {
+			return err
+		}
+		var body bytes.Buffer
+		if err := g.nestedMissing(&body, expr, elem, ek, inner, name, key); err != nil {
+			return err
+		}
+		if body.Len() > 0 {
+			fmt.Fprintf(w, "if %s != nil {\n%s}\n", expr, body.Bytes())
+		}
+	case sliceKind, arrayKind:
+		elem := g.elem(t)
+		i := g.newVar("i")
+		index := "strconv.Itoa(" + i + ")"
+		var body bytes.Buffer
+		if err := g.elemMissing(&body, expr+"["+i+"]", elem, name.index(index), key.index(index)); err != nil {
+			return err
+		}
+		if body.Len() > 0 {
+			g.imports["strconv"] = "strconv"
+			fmt.Fprintf(w, "for %s := range %s {\n%s}\n", i, expr, body.Bytes())
+		}
+	case mapKind:
+		elem := g.elem(t)
+		keys, mk, mv := g.newVar("keys"), g.newVar("k"), g.newVar("v")
+		index := "fmt.Sprint(" + mk + ")"
+		var body bytes.Buffer
+		if err := g.elemMissing(&body, mv, elem, name.index(index), key.index(index)); err != nil {
+			return err
+		}
+		if body.Len() > 0 {
+			g.imports["fmt"] = "fmt"
+			g.imports["sort"] = "sort"
+			fmt.Fprintf(w, "%s := make([]%s, 0, len(%s))\nfor k := range %s {\n%s = append(%s, k)\n}\n", keys, g.render(t.(*ast.MapType).Key), expr, expr, keys, keys)
+			fmt.Fprintf(w, "sort.Slice(%s, func(i, j int) bool {\nreturn fmt.Sprint(%s[i]) < fmt.Sprint(%s[j])\n})\n", keys, keys, keys)
+			fmt.Fprintf(w, "for _, %s := range %s {\n%s := %s[%s]\n%s}\n", mk, keys, mv, expr, mk, body.Bytes())
+		}
+	}
+	return nil
+}
+
+// elemMissing writes the code checking the required fields of the structs
+// held by expr, an element of a slice, array or map of type t.
+func (g *generator) elemMissing(w *bytes.Buffer, expr string, t ast.Expr, name, key path) error {
+	k, inner, err := g.kindOf(t)
+	if err != nil {
+		return err
+	}
+	switch k {
+	case localKind, pointerKind:
+		return g.nestedMissing(w, expr, t, k, inner, name, key)
+	case otherKind:
+		fmt.Fprintf(w, "missing = append(missing, goconfig.GeneratedField{}.Missing(&%s, %s, %s, prefix)...)\n", expr, name, key)
+	}
+	return nil
+}
+
+// structCopy writes the code copying the fields of st from the struct src
+// to dst.
+func (g *generator) structCopy(w *bytes.Buffer, dst, src string, st *ast.StructType) error {
+	list, err := fields(st)
+	if err != nil {
+		return err
+	}
+	for _, f := range list {
+		if err := g.copyValue(w, dst+"."+f.name, src+"."+f.name, f.typ); err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// copyValue writes the code setting dst to a deep copy of src, of type t.
+func (g *generator) copyValue(w *bytes.Buffer, dst, src string, t ast.Expr) error {
+	k, inner, err := g.kindOf(t)
+	if err != nil {
+		return err
+	}
+	if ident, ok := t.(*ast.Ident); ok && k == otherKind {
+		// Named types of the package are copied as their underlying types.
+		if decl, ok := g.types[ident.Name]; ok {
+			if _, isStruct := decl.expr.(*ast.StructType); !isStruct {
+				if k, _, err = g.kindOf(decl.expr); err != nil {
+					return err
+				}
+				if k == otherKind {
+					k = valueKind
+				}
+			}
+		}
+	}
+	switch k {
+	case basicKind, valueKind:
+		fmt.Fprintf(w, "%s = %s\n", dst, src)
+	case mutexKind:
+	case localKind:
+		leave, err := g.enter(t)
+		if err != nil {
+			return err
+		}
+		defer leave()
+		return g.structCopy(w, dst, src, inner)
+	case pointerKind:
+		elem := g.elem(t)
+		fmt.Fprintf(w, "if %s != nil {\n%s = new(%s)\n", src, dst, g.render(elem))
+		if err := g.copyValue(w, "(*"+dst+")", "(*"+src+")", elem); err != nil {
+			return err
+		}
+		w.WriteString("}\n")
+	case sliceKind:
+		elem := g.elem(t)
+		fmt.Fprintf(w, "if %s != nil {\n%s = make(%s, len(%s))\n", src, dst, g.render(t), src)
+		if ek, _, _ := g.kindOf(elem); ek == basicKind || ek == valueKind {
+			fmt.Fprintf(w, "copy(%s, %s)\n", dst, src)
+		} else {
+			i := g.newVar("i")
+			fmt.Fprintf(w, "for %s := range %s {\n", i, src)
+			if err := g.copyValue(w, dst+"["+i+"]", src+"["+i+"]", elem); err != nil {
+				return err
+			}
+			w.WriteString("}\n")
+		}
+		w.WriteString("}\n")
+	case arrayKind:
+		elem := g.elem(t)
+		i := g.newVar("i")
+		fmt.Fprintf(w, "for %s := range %s {\n", i, src)
+		if err := g.copyValue(w, dst+"["+i+"]", src+"["+i+"]", elem); err != nil {
+			return err
+		}
+		w.WriteString("}\n")
+	case mapKind:
+		elem := g.elem(t)
+		mk, mv := g.newVar("k"), g.newVar("v")
+		fmt.Fprintf(w, "if %s != nil {\n%s = make(%s, len(%s))\nfor %s, %s := range %s {\n", src, dst, g.render(t), src, mk, mv, src)
+		if ek, _, _ := g.kindOf(elem); ek == basicKind || ek == valueKind {
+			fmt.Fprintf(w, "%s[%s] = %s\n", dst, mk, mv)
+		} else {
+			cv := g.newVar("cv")
+			fmt.Fprintf(w, "var %s %s\n", cv, g.render(elem))
+			if err := g.copyValue(w, cv, mv, elem); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s[%s] = %s\n", dst, mk, cv)
+		}
+		w.WriteString("}\n}\n")
+	default:
+		fmt.Fprintf(w, "goconfig.CopyValue(&%s, &%s)\n", dst, src)
+	}
+	return nil
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" || name == "" {
+		return parent + name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestGenerateBuilds generates the code of the fixtures in testdata and checks
+// that it compiles alongside them.
+func TestGenerateBuilds(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	for _, fixture := range []string{"collections", "required"} {
+		t.Run(fixture, func(t *testing.T) {
+			// The package is built inside the repository, so that it imports
+			// this goconfig and its dependencies.
+			dir, err := os.MkdirTemp("testdata", fixture+"-build")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.RemoveAll(dir) })
+			src, err := os.ReadFile(filepath.Join("testdata", fixture, "config.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "config.go"), src, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			generated, err := generate(dir, "config_goconfig.go", []string{"Config"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "config_goconfig.go"), generated, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command("go", "build", ".")
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("go build: %v\n%s\n%s", err, out, generated)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package collections
+
+type Inner struct {
+	Name string `yaml:"name"`
+}
+
+type Config struct {
+	Labels   map[string]string `yaml:"labels"`
+	Limits   map[string]int    `yaml:"limits"`
+	Tags     []string          `yaml:"tags"`
+	Ports    []int             `yaml:"ports"`
+	Fixed    [2]string         `yaml:"fixed"`
+	Inner    *Inner            `yaml:"inner"`
+	Inners   []Inner           `yaml:"inners"`
+	ByName   map[string]Inner  `yaml:"by_name"`
+	Pointers []*Inner          `yaml:"pointers"`
+}
@@ -0,0 +1,24 @@
+package required
+
+import "time"
+
+type Upstream struct {
+	URL     string        `yaml:"url" required:"true"`
+	Weight  int           `yaml:"weight" default:"1" env:"WEIGHT"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type TLS struct {
+	Cert string `yaml:"cert" required:"true" env:"TLS_CERT"`
+}
+
+type Config struct {
+	Name      string              `yaml:"name" required:"true" env:"NAME"`
+	Port      int                 `yaml:"port" default:"8080" env:"PORT"`
+	Mode      uint32              `yaml:"mode" default:"0755" env:"MODE" base:"0"`
+	Tags      []string            `yaml:"tags" env:"TAGS"`
+	TLS       *TLS                `yaml:"tls"`
+	Upstreams []Upstream          `yaml:"upstreams"`
+	Tenants   map[string]Upstream `yaml:"tenants"`
+	Backups   map[string]*TLS     `yaml:"backups"`
+}
@@ -0,0 +1,104 @@
+// Package configtest helps tests set up configs loaded with goconfig: loading
+// them from inline YAML, writing throwaway config files, setting environment
+// variables and overriding fields, all undone once the test ends.
+package configtest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/santiclause/goconfig"
+)
+
+// Load loads c, a pointer to a config struct, from data, YAML unless opts hold goconfig.WithFormat, as
+// goconfig.LoadFrom does, and fails the test if that fails. The environment
+// still applies unless opts hold goconfig.SkipEnv.
+func Load(t testing.TB, c interface{}, data string, opts ...goconfig.Option) {
+	t.Helper()
+	if err := goconfig.LoadFrom(strings.NewReader(data), c, opts...); err != nil {
+		t.Fatalf("loading config: %s", err)
+	}
+}
+
+// File writes content to a file called name, which may hold directories, in
+// a temporary directory removed once the test ends, and returns its path.
+func File(t testing.TB, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("creating config file: %s", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("creating config file: %s", err)
+	}
+	return path
+}
+
+// Env sets the environment variables in vars until the test ends. As with
+// t.Setenv, the test can't be parallel.
+func Env(t testing.TB, vars map[string]string) {
+	t.Helper()
+	for key, value := range vars {
+		t.Setenv(key, value)
+	}
+}
+
+// Set sets the field of c pointed to by field to value, with c locked, and
+// restores its previous value once the test ends, as in
+//
+//	configtest.Set(t, config, &config.Server.Port, 9090)
+func Set[V any](t testing.TB, c interface{}, field *V, value V) {
+	t.Helper()
+	state := goconfig.StateOf(c)
+	state.Lock()
+	old := *field
+	*field = value
+	state.Unlock()
+	t.Cleanup(func() {
+		state.Lock()
+		*field = old
+		state.Unlock()
+	})
+}
+
+// Override applies overrides of the form "server.port=9090" to c, as
+// goconfig.ApplyOverrides does, and restores the previous values of c once the
+// test ends. c must be a pointer to a struct, such as one embedding
+// goconfig.Config, rather than a goconfig.Handle.
+func Override(t testing.TB, c interface{}, overrides ...string) {
+	t.Helper()
+	old := goconfig.Snapshot(c)
+	if err := goconfig.ApplyOverrides(c, overrides); err != nil {
+		t.Fatalf("overriding config: %s", err)
+	}
+	t.Cleanup(func() {
+		state := goconfig.StateOf(c)
+		state.Lock()
+		defer state.Unlock()
+		restore(reflect.ValueOf(c).Elem(), reflect.ValueOf(old).Elem())
+	})
+}
+
+var (
+	mutexType   = reflect.TypeOf(sync.Mutex{})
+	rwMutexType = reflect.TypeOf(sync.RWMutex{})
+)
+
+// restore sets the exported fields of the struct dst to those of src, leaving
+// mutexes alone.
+func restore(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		sf := dst.Type().Field(i)
+		switch {
+		case sf.Type == mutexType || sf.Type == rwMutexType:
+		case sf.Anonymous && sf.Type.Kind() == reflect.Struct:
+			restore(dst.Field(i), src.Field(i))
+		case sf.PkgPath == "":
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
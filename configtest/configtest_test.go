@@ -0,0 +1,90 @@
+package configtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/santiclause/goconfig"
+)
+
+type server struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+}
+
+type testConfig struct {
+	goconfig.Config
+	Server server `yaml:"server"`
+	Debug  bool   `yaml:"debug" env:"CONFIGTEST_DEBUG"`
+}
+
+func TestLoad(t *testing.T) {
+	Env(t, map[string]string{"CONFIGTEST_DEBUG": "true"})
+	var c testConfig
+	Load(t, &c, "server: {host: example.com, port: 80}")
+	if c.Server.Host != "example.com" || c.Server.Port != 80 {
+		t.Errorf("server = %+v", c.Server)
+	}
+	if !c.Debug {
+		t.Error("environment not applied")
+	}
+
+	var json testConfig
+	Load(t, &json, `{"server": {"port": 81}}`, goconfig.WithFormat(goconfig.FormatJSON), goconfig.SkipEnv())
+	if json.Server.Port != 81 || json.Debug {
+		t.Errorf("config = %+v, %v", json.Server, json.Debug)
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := File(t, "conf/config.yaml", "server: {port: 80}\n")
+	if filepath.Base(filepath.Dir(path)) != "conf" {
+		t.Errorf("path = %s", path)
+	}
+	var c testConfig
+	if err := goconfig.Load(&c, goconfig.WithFile(path)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Port != 80 {
+		t.Errorf("port = %d", c.Server.Port)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Run("set", func(t *testing.T) {
+		Env(t, map[string]string{"CONFIGTEST_VAR": "1"})
+		if got := os.Getenv("CONFIGTEST_VAR"); got != "1" {
+			t.Errorf("CONFIGTEST_VAR = %q", got)
+		}
+	})
+	if _, ok := os.LookupEnv("CONFIGTEST_VAR"); ok {
+		t.Error("CONFIGTEST_VAR still set after the test")
+	}
+}
+
+func TestSet(t *testing.T) {
+	c := &testConfig{Server: server{Port: 80}}
+	t.Run("set", func(t *testing.T) {
+		Set(t, c, &c.Server.Port, 9090)
+		if c.Server.Port != 9090 {
+			t.Errorf("port = %d", c.Server.Port)
+		}
+	})
+	if c.Server.Port != 80 {
+		t.Errorf("port = %d after the test, want it restored", c.Server.Port)
+	}
+}
+
+func TestOverride(t *testing.T) {
+	c := &testConfig{Server: server{Host: "a", Port: 80}}
+	t.Run("override", func(t *testing.T) {
+		Override(t, c, "server.port=9090", "debug=true")
+		if c.Server.Port != 9090 || !c.Debug || c.Server.Host != "a" {
+			t.Errorf("config = %+v, %v", c.Server, c.Debug)
+		}
+	})
+	if c.Server.Port != 80 || c.Debug || c.Server.Host != "a" {
+		t.Errorf("config = %+v, %v after the test, want it restored", c.Server, c.Debug)
+	}
+}
@@ -0,0 +1,177 @@
+package configtest
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/santiclause/goconfig"
+)
+
+// scheme is the URL scheme of Sources.
+const scheme = "configtest"
+
+// reloadTimeout is how long PushWait waits for a reload.
+const reloadTimeout = 5 * time.Second
+
+var (
+	// sourcesMu guards sources and lastSource.
+	sourcesMu  sync.Mutex
+	sources    = map[string]*Source{}
+	lastSource int
+	registered sync.Once
+)
+
+// Source is an in-memory config source for tests, standing in for a file or a
+// remote config. Load it by passing goconfig.WithFile(s.URL()), and push new
+// versions of it, valid or not, to test how code reacts to reloads by Watch:
+//
+//	src := configtest.NewSource(t, "port: 80")
+//	goconfig.Load(config, goconfig.WithFile(src.URL()))
+//	goconfig.Watch(config, goconfig.WithFile(src.URL()), goconfig.OnReload(restart))
+//	event := src.PushWait(t, config, "port: nope")
+//	// event.Err says why port can't be "nope", and config.Port is still 80.
+type Source struct {
+	url string
+	mu  sync.Mutex
+	// data is the current version, or err the error fetching it fails with.
+	data []byte
+	err  error
+	// watchers are called when the version changes.
+	watchers map[int]func()
+	next     int
+}
+
+// NewSource returns a Source holding data, which is YAML unless loaded with
+// goconfig.WithFormat. It is removed once the test ends.
+func NewSource(t testing.TB, data string) *Source {
+	t.Helper()
+	registered.Do(func() {
+		goconfig.RegisterScheme(scheme, fetch)
+		goconfig.RegisterWatcher(scheme, watch)
+	})
+	sourcesMu.Lock()
+	lastSource++
+	id := fmt.Sprint(lastSource)
+	s := &Source{url: scheme + "://" + id + "/config.yaml", data: []byte(data), watchers: map[int]func(){}}
+	sources[id] = s
+	sourcesMu.Unlock()
+	t.Cleanup(func() {
+		sourcesMu.Lock()
+		delete(sources, id)
+		sourcesMu.Unlock()
+	})
+	return s
+}
+
+// URL returns the URL to load the source from.
+func (s *Source) URL() string {
+	return s.url
+}
+
+// Push replaces the contents of the source with data, which Watch then
+// reloads.
+func (s *Source) Push(data string) {
+	s.set([]byte(data), nil)
+}
+
+// Fail makes fetching the source fail with err until the next Push, as if a
+// remote config couldn't be reached. Watch then tries to reload it.
+func (s *Source) Fail(err error) {
+	s.set(nil, err)
+}
+
+// Remove makes the source missing until the next Push, as a deleted file is.
+func (s *Source) Remove() {
+	s.set(nil, fs.ErrNotExist)
+}
+
+// PushWait pushes data, as Push does, and waits for c, which must be watched,
+// to be reloaded from it, returning the outcome of the reload. It fails the
+// test if no reload happens within a few seconds.
+func (s *Source) PushWait(t testing.TB, c interface{}, data string) goconfig.ChangeEvent {
+	t.Helper()
+	deadline := time.Now().Add(reloadTimeout)
+	// Watch starts watching in the background, so it may not have yet.
+	for !s.watched() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s not watched within %s", s.url, reloadTimeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	events := goconfig.Subscribe(c)
+	defer goconfig.Unsubscribe(c, events)
+	s.Push(data)
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Until(deadline)):
+		t.Fatalf("config not reloaded from %s within %s", s.url, reloadTimeout)
+		return goconfig.ChangeEvent{}
+	}
+}
+
+// watched reports whether the source is being watched.
+func (s *Source) watched() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.watchers) > 0
+}
+
+func (s *Source) set(data []byte, err error) {
+	s.mu.Lock()
+	s.data, s.err = data, err
+	watchers := make([]func(), 0, len(s.watchers))
+	for _, changed := range s.watchers {
+		watchers = append(watchers, changed)
+	}
+	s.mu.Unlock()
+	for _, changed := range watchers {
+		changed()
+	}
+}
+
+// sourceFor returns the Source u refers to.
+func sourceFor(u *url.URL) (*Source, error) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	s, ok := sources[u.Host]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", u, fs.ErrNotExist)
+	}
+	return s, nil
+}
+
+func fetch(ctx context.Context, u *url.URL) ([]byte, error) {
+	s, err := sourceFor(u)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	return append([]byte(nil), s.data...), nil
+}
+
+func watch(ctx context.Context, u *url.URL, changed func()) error {
+	s, err := sourceFor(u)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	id := s.next
+	s.next++
+	s.watchers[id] = changed
+	s.mu.Unlock()
+	<-ctx.Done()
+	s.mu.Lock()
+	delete(s.watchers, id)
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,88 @@
+package configtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/santiclause/goconfig"
+)
+
+// watchSource loads a testConfig from src and watches it until the test ends.
+func watchSource(t *testing.T, src *Source) *testConfig {
+	t.Helper()
+	c := &testConfig{}
+	if err := goconfig.Load(c, goconfig.WithFile(src.URL())); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	t.Cleanup(func() { goconfig.Release(c) })
+	if err := goconfig.WatchContext(ctx, c, goconfig.WithFile(src.URL())); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func (c *testConfig) port() int {
+	c.RLock()
+	defer c.RUnlock()
+	return c.Server.Port
+}
+
+func TestSourcePushWait(t *testing.T) {
+	src := NewSource(t, "server: {port: 80}")
+	c := watchSource(t, src)
+	if c.port() != 80 {
+		t.Fatalf("port = %d", c.port())
+	}
+	event := src.PushWait(t, c, "server: {port: 81}")
+	if event.Err != nil {
+		t.Fatal(event.Err)
+	}
+	if len(event.Changes) != 1 || event.Changes[0].Path != "Server.Port" {
+		t.Errorf("changes = %v", event.Changes)
+	}
+	if c.port() != 81 {
+		t.Errorf("port = %d", c.port())
+	}
+
+	if event := src.PushWait(t, c, "server: {port: nope}"); event.Err == nil {
+		t.Error("expected a reload error")
+	}
+	if c.port() != 81 {
+		t.Errorf("port = %d after a failed reload", c.port())
+	}
+}
+
+func TestSourceFail(t *testing.T) {
+	src := NewSource(t, "server: {port: 80}")
+	var c testConfig
+	unreachable := errors.New("unreachable")
+	src.Fail(unreachable)
+	if err := goconfig.Load(&c, goconfig.WithFile(src.URL())); !errors.Is(err, unreachable) {
+		t.Errorf("err = %v, want %v", err, unreachable)
+	}
+	src.Remove()
+	if err := goconfig.Load(&c, goconfig.WithFile(src.URL())); err == nil {
+		t.Error("loaded a removed source")
+	}
+	src.Push("server: {port: 82}")
+	if err := goconfig.Load(&c, goconfig.WithFile(src.URL())); err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Port != 82 {
+		t.Errorf("port = %d", c.Server.Port)
+	}
+}
+
+func TestSourceRemovedAfterTest(t *testing.T) {
+	var url string
+	t.Run("source", func(t *testing.T) {
+		url = NewSource(t, "server: {port: 80}").URL()
+	})
+	var c testConfig
+	if err := goconfig.Load(&c, goconfig.WithFile(url)); err == nil {
+		t.Error("loaded a source after its test ended")
+	}
+}
@@ -0,0 +1,157 @@
+package goconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Consul config URLs look like consul://host:8500/myapp/config.yaml, or
+// consul+https://... for TLS, and name a KV key holding the document. A path
+// ending in a slash names a key prefix instead, whose keys are mapped to
+// fields by their path below it, e.g. myapp/database/host to database.host.
+// Query parameters such as dc are passed on to Consul, and the ACL token is
+// taken from a token parameter or else CONSUL_HTTP_TOKEN.
+
+// consulWait is how long a blocking query waits for a change before Consul
+// answers anyway.
+const consulWait = 5 * time.Minute
+
+var (
+	// consulIndexesMu guards consulIndexes.
+	consulIndexesMu sync.Mutex
+	// consulIndexes holds the index each Consul URL was last fetched at, for
+	// blocking queries to wait on.
+	consulIndexes = map[string]uint64{}
+)
+
+// fetchConsul fetches the Consul key or key prefix u refers to.
+func fetchConsul(ctx context.Context, u *url.URL, o *options) ([]byte, error) {
+	resp, index, err := consulGet(ctx, o.httpClient, u, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	consulIndexesMu.Lock()
+	consulIndexes[u.String()] = index
+	consulIndexesMu.Unlock()
+	if !strings.HasSuffix(u.Path, "/") {
+		return io.ReadAll(resp.Body)
+	}
+	var pairs []struct {
+		Key   string
+		Value []byte
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimPrefix(u.Path, "/")
+	tree := map[string]interface{}{}
+	for _, pair := range pairs {
+		rel := strings.TrimPrefix(pair.Key, prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			// Folders have no value of their own.
+			continue
+		}
+		setTreePath(tree, strings.Split(rel, "/"), resolveScalar(string(pair.Value)))
+	}
+	return yaml.Marshal(tree)
+}
+
+// watchConsul calls changed whenever the Consul key or key prefix u refers to
+// changes, using blocking queries, until ctx is done or a query fails.
+func watchConsul(ctx context.Context, u *url.URL, o *options, changed func()) error {
+	client := streamingClient(o.httpClient)
+	for {
+		consulIndexesMu.Lock()
+		last := consulIndexes[u.String()]
+		consulIndexesMu.Unlock()
+		resp, index, err := consulGet(ctx, client, u, last)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if index == 0 {
+			return errors.New("consul: no index to block on")
+		}
+		if index < last {
+			// The index went backwards, e.g. after a snapshot restore,
+			// so the next query must start afresh.
+			index = 0
+		}
+		consulIndexesMu.Lock()
+		consulIndexes[u.String()] = index
+		consulIndexesMu.Unlock()
+		if index != last {
+			changed()
+		}
+	}
+}
+
+// consulGet reads the Consul key or key prefix u refers to, blocking until its
+// index passes index if that isn't zero. It returns the response and the new
+// index. A missing key gives an error wrapping fs.ErrNotExist.
+func consulGet(ctx context.Context, client *http.Client, u *url.URL, index uint64) (*http.Response, uint64, error) {
+	query := u.Query()
+	token := query.Get("token")
+	query.Del("token")
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	if strings.HasSuffix(u.Path, "/") {
+		query.Set("recurse", "true")
+	} else {
+		query.Set("raw", "true")
+	}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", consulWait.String())
+	}
+	scheme := "http"
+	if strings.HasSuffix(u.Scheme, "+https") {
+		scheme = "https"
+	}
+	endpoint := &url.URL{Scheme: scheme, Host: u.Host, Path: "/v1/kv" + u.Path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, newIndex, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, newIndex, fs.ErrNotExist
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, 0, fmt.Errorf("consul: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
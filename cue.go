@@ -0,0 +1,37 @@
+//go:build cue
+
+package goconfig
+
+import (
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+)
+
+// FormatCUE is the format of CUE config files, which Load understands when
+// built with the cue tag.
+const FormatCUE = "cue"
+
+func init() {
+	RegisterDecoder(".cue", decodeCUE)
+}
+
+// decodeCUE evaluates a CUE document and unmarshals it into v, using yaml
+// struct tags. Its constraints are checked as it is evaluated, and every
+// field it exports must end up with a concrete value, so that a file can
+// carry its own schema, as in
+//
+//	#Port: int & >0 & <65536
+//	port:  #Port & 8080
+//
+// Definitions and hidden fields aren't part of the config.
+func decodeCUE(data []byte, v interface{}) error {
+	value := cuecontext.New().CompileBytes(data)
+	if err := value.Validate(cue.Concrete(true)); err != nil {
+		return err
+	}
+	doc := map[string]interface{}{}
+	if err := value.Decode(&doc); err != nil {
+		return err
+	}
+	return decodeTree(normalize(doc), v)
+}
@@ -0,0 +1,215 @@
+package goconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats understood by Load.
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+	FormatINI  = "ini"
+	// FormatProperties is the format of Java properties files.
+	FormatProperties = "properties"
+)
+
+// DecodeFunc decodes a config document into v, in the manner of yaml.Unmarshal.
+// v is either the config struct or, when several documents are merged, a
+// *map[string]interface{}.
+type DecodeFunc func(data []byte, v interface{}) error
+
+var (
+	// decodersMu guards decoders and extensions.
+	decodersMu sync.RWMutex
+	decoders   = map[string]DecodeFunc{
+		FormatYAML:       yaml.Unmarshal,
+		FormatJSON:       decodeJSON,
+		FormatTOML:       decodeTOML,
+		FormatINI:        decodeINI,
+		FormatProperties: decodeProperties,
+	}
+	// extensions maps filename extensions to formats.
+	extensions = map[string]string{
+		".yaml":       FormatYAML,
+		".yml":        FormatYAML,
+		".json":       FormatJSON,
+		".toml":       FormatTOML,
+		".ini":        FormatINI,
+		".properties": FormatProperties,
+	}
+)
+
+// RegisterDecoder makes Load parse files with the given extension (e.g. ".hcl")
+// using fn. The extension without its leading dot also becomes a format name
+// that can be passed to WithFormat. Registering a known extension replaces its
+// decoder.
+func RegisterDecoder(ext string, fn DecodeFunc) {
+	format := strings.ToLower(strings.TrimPrefix(ext, "."))
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[format] = fn
+	extensions["."+format] = format
+}
+
+// detectFormat returns the format implied by the filename's extension, or that
+// of the path of a URL, falling back to YAML for unknown or missing extensions.
+func detectFormat(filename string) string {
+	if u, _, ok := remoteURL(filename); ok {
+		filename = u.Path
+	}
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	if format, ok := extensions[strings.ToLower(filepath.Ext(filename))]; ok {
+		return format
+	}
+	return FormatYAML
+}
+
+// configExtensions returns the known config extensions, the built-in ones
+// first.
+func configExtensions() []string {
+	exts := []string{".yaml", ".yml", ".json", ".toml", ".ini", ".properties"}
+	builtin := len(exts)
+	decodersMu.RLock()
+	for ext := range extensions {
+		if !containsString(exts[:builtin], ext) {
+			exts = append(exts, ext)
+		}
+	}
+	decodersMu.RUnlock()
+	sort.Strings(exts[builtin:])
+	return exts
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isConfigFile reports whether filename has a known config extension.
+func isConfigFile(filename string) bool {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	_, ok := extensions[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// decoderFor returns the decoder for format, or the one detected from filename
+// if format is empty, along with the name of the format.
+func decoderFor(filename, format string) (string, DecodeFunc, error) {
+	if format == "" {
+		format = detectFormat(filename)
+	}
+	format = strings.ToLower(format)
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	decode, ok := decoders[format]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported config format %q", format)
+	}
+	return format, decode, nil
+}
+
+// decodeJSON unmarshals a JSON document into v. The document is routed through
+// yaml so that the same `yaml` struct tags apply regardless of file format.
+func decodeJSON(data []byte, v interface{}) error {
+	var doc interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return err
+	}
+	return decodeTree(normalize(doc), v)
+}
+
+// decodeTOML unmarshals a TOML document into v, using yaml struct tags.
+func decodeTOML(data []byte, v interface{}) error {
+	doc := map[string]interface{}{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return decodeTree(normalize(doc), v)
+}
+
+// decodeYAML unmarshals a YAML document into v. In strict mode, keys that don't
+// map to a field are an error.
+func decodeYAML(data []byte, v interface{}, strict bool) error {
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	d.KnownFields(strict)
+	if err := d.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// decodeTree unmarshals a generic document tree into v using yaml struct tags.
+func decodeTree(tree interface{}, v interface{}) error {
+	return decodeTreeWith(tree, v, false)
+}
+
+// decodeTreeWith is decodeTree, optionally in strict mode.
+func decodeTreeWith(tree interface{}, v interface{}, strict bool) error {
+	data, err := marshalTree(tree, v)
+	if err != nil {
+		return err
+	}
+	return decodeYAML(data, v, strict)
+}
+
+// marshalTree marshals a generic document tree to the yaml decodeTree decodes
+// into v.
+func marshalTree(tree interface{}, v interface{}) ([]byte, error) {
+	if m, ok := tree.(map[string]interface{}); ok {
+		tree, _ = nestEmbedded(m, reflect.TypeOf(v))
+	}
+	return yaml.Marshal(tree)
+}
+
+// normalize converts the output of the various decoders into a tree of
+// map[string]interface{}, []interface{} and scalar values.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			t[k] = normalize(val)
+		}
+		return t
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range t {
+			t[i] = normalize(val)
+		}
+		return t
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return t.String()
+	}
+	return v
+}
@@ -0,0 +1,115 @@
+package goconfig
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// applyDefaults sets every zero-valued field of the struct pointed to by val
+// that has a `default` tag to the value of the tag, recursing into nested
+// structs and pointers to structs that are set.
+func applyDefaults(val interface{}) error {
+	if g, ok := val.(Generated); ok {
+		return g.GoconfigDefaults()
+	}
+	value := reflect.ValueOf(val)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return errors.New("Not a struct pointer!")
+	}
+	return applyStructDefaults(value.Elem(), fieldPath{})
+}
+
+func applyStructDefaults(value reflect.Value, path fieldPath) error {
+	fields := fieldsOf(value.Type())
+	for i := range fields {
+		f := &fields[i]
+		if err := applyFieldDefaults(value.Field(f.Index[0]), f, path.childField(f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyFieldDefaults applies the `default` tag of field, whose path is name,
+// or the defaults of the struct it holds.
+func applyFieldDefaults(field reflect.Value, f *fieldInfo, name fieldPath) error {
+	if f.hasDefault {
+		if isZero(field) {
+			if err := setFromStringWith(field, f.def, stringFormat{layout: f.layout, base: f.base, hasBase: f.hasBase}); err != nil {
+				return fmt.Errorf("invalid default for %s: %s", name.field, err)
+			}
+		}
+		return nil
+	}
+	if inner, ok := structValue(field); ok {
+		return applyStructDefaults(inner, name)
+	}
+	return nil
+}
+
+// structValue returns the struct held by field, itself or pointed to by it,
+// if it holds one.
+func structValue(field reflect.Value) (reflect.Value, bool) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return reflect.Value{}, false
+		}
+		field = field.Elem()
+	}
+	if field.Kind() != reflect.Struct || field.Type() == timeType {
+		return reflect.Value{}, false
+	}
+	return field, true
+}
+
+// allocateSections sets the nil pointers to structs in value, a struct that
+// tree is about to be decoded into, for which tree holds a map, to structs
+// holding their defaults, which applyDefaults couldn't reach, so that keys
+// missing from the map keep them.
+func allocateSections(tree map[string]interface{}, value reflect.Value, path fieldPath) error {
+	fields := fieldsOf(value.Type())
+	for i := range fields {
+		f := &fields[i]
+		if f.skipped {
+			continue
+		}
+		field := value.Field(f.Index[0])
+		name := path.childField(f)
+		sub := tree
+		if f.key != "" {
+			sub, _ = tree[f.key].(map[string]interface{})
+		} else if !hasAnyKey(tree, structKeys(f.Type, true)) {
+			sub = nil
+		}
+		if sub == nil {
+			continue
+		}
+		if isPointerSection(field.Type()) && field.IsNil() {
+			section, err := newSection(field.Type(), name)
+			if err != nil {
+				return err
+			}
+			field.Set(section)
+		}
+		if inner, ok := structValue(field); ok {
+			if err := allocateSections(sub, inner, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isPointerSection reports whether t is a pointer to a struct that the
+// sources set the fields of, which a nil pointer of type t is allocated for.
+func isPointerSection(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct && t.Elem() != timeType && !parsesItself(t.Elem())
+}
+
+// newSection returns a pointer of type t, as isPointerSection reports of it,
+// to a new struct holding its defaults. path is its path, for errors.
+func newSection(t reflect.Type, path fieldPath) (reflect.Value, error) {
+	section := reflect.New(t.Elem())
+	return section, applyStructDefaults(section.Elem(), path)
+}
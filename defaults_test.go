@@ -0,0 +1,96 @@
+package goconfig
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type defaultsServer struct {
+	Host    string        `yaml:"host" default:"localhost"`
+	Port    int           `yaml:"port" default:"8080" env:"DEFAULTSTEST_PORT"`
+	Timeout time.Duration `yaml:"timeout" default:"5s"`
+}
+
+type defaultsLimits struct {
+	Burst int `yaml:"burst" default:"10"`
+}
+
+type defaultsConfig struct {
+	Server  defaultsServer  `yaml:"server"`
+	Limits  *defaultsLimits `yaml:"limits"`
+	Ports   []int           `yaml:"ports" default:"80, 443"`
+	Tags    []string        `yaml:"tags" default:"a,b"`
+	Level   *string         `yaml:"level" default:"info"`
+	Retries int             `yaml:"retries" default:"3"`
+}
+
+func TestDefaults(t *testing.T) {
+	c := defaultsConfig{Retries: 5}
+	if err := applyDefaults(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Host != "localhost" || c.Server.Port != 8080 || c.Server.Timeout != 5*time.Second {
+		t.Errorf("server = %+v", c.Server)
+	}
+	if c.Limits != nil {
+		t.Errorf("limits = %+v, want nil", c.Limits)
+	}
+	if len(c.Ports) != 2 || c.Ports[0] != 80 || c.Ports[1] != 443 {
+		t.Errorf("ports = %v", c.Ports)
+	}
+	if strings.Join(c.Tags, " ") != "a b" {
+		t.Errorf("tags = %v", c.Tags)
+	}
+	if c.Level == nil || *c.Level != "info" {
+		t.Errorf("level = %v", c.Level)
+	}
+	if c.Retries != 5 {
+		t.Errorf("retries = %d, want the value already set", c.Retries)
+	}
+
+	// The defaults of a section are applied once it is set.
+	c.Limits = &defaultsLimits{}
+	if err := applyDefaults(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Limits.Burst != 10 {
+		t.Errorf("burst = %d", c.Limits.Burst)
+	}
+}
+
+func TestDefaultsOverridden(t *testing.T) {
+	t.Setenv("DEFAULTSTEST_PORT", "9090")
+	filename := writeConfigFile(t, "config.yaml", "server: {host: example.com}\nlimits: {}\nports: [8443]\nlevel: debug\n")
+	var c defaultsConfig
+	if err := Load(&c, WithFile(filename)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Host != "example.com" || c.Server.Port != 9090 || c.Server.Timeout != 5*time.Second {
+		t.Errorf("server = %+v", c.Server)
+	}
+	if c.Limits == nil || c.Limits.Burst != 10 {
+		t.Errorf("limits = %+v, want the section's defaults", c.Limits)
+	}
+	if len(c.Ports) != 1 || c.Ports[0] != 8443 {
+		t.Errorf("ports = %v", c.Ports)
+	}
+	if c.Level == nil || *c.Level != "debug" {
+		t.Errorf("level = %v", c.Level)
+	}
+	if c.Retries != 3 {
+		t.Errorf("retries = %d", c.Retries)
+	}
+}
+
+func TestDefaultsInvalid(t *testing.T) {
+	var c struct {
+		Server struct {
+			Port int `default:"eighty"`
+		}
+	}
+	err := applyDefaults(&c)
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid default for Server.Port: ") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Change describes a field whose value differs between two configs. The values
+// of secret fields are masked.
+type Change struct {
+	// Path is the Go field path, e.g. "Server.Timeout".
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s changed from %s to %s", c.Path, formatValue(c.Old), formatValue(c.New))
+}
+
+// formatValue formats v for a Change, quoting strings so that empty ones show.
+func formatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(v)
+}
+
+// Diff returns the fields whose values differ between old and new, which must
+// be configs of the same type, such as those passed to an OnReload hook.
+// Nested structs are compared field by field, anything else as a whole. Fields
+// tagged `secret:"true"` are reported with their values masked.
+func Diff(old, new interface{}) []Change {
+	return diff(configTarget(old), configTarget(new))
+}
+
+// diff is Diff for pointers to structs of the same type.
+func diff(old, new interface{}) []Change {
+	return diffStruct(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), fieldPath{}, false)
+}
+
+func diffStruct(old, new reflect.Value, path fieldPath, secret bool) []Change {
+	var changes []Change
+	for i := 0; i < new.NumField(); i++ {
+		structField := new.Type().Field(i)
+		if !exported(structField) || structField.Type == mutexType || structField.Type == rwMutexType {
+			continue
+		}
+		name := path.child(structField)
+		fieldSecret := secret || structField.Tag.Get("secret") == "true" || structField.Type == secretType
+		o, n := old.Field(i), new.Field(i)
+		if n.Kind() == reflect.Struct && n.Type() != timeType {
+			changes = append(changes, diffStruct(o, n, name, fieldSecret)...)
+			continue
+		}
+		if reflect.DeepEqual(o.Interface(), n.Interface()) {
+			continue
+		}
+		change := Change{Path: name.field, Old: o.Interface(), New: n.Interface()}
+		if fieldSecret {
+			change.Old, change.New = mask(o), mask(n)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// mask hides the value of a secret field, unless it is unset.
+func mask(v reflect.Value) interface{} {
+	if isZero(v) {
+		return v.Interface()
+	}
+	return redacted
+}
+
+// changedFields returns the paths of the changes between old and new.
+func changedFields(changes []Change) []string {
+	paths := make([]string, len(changes))
+	for i, change := range changes {
+		paths[i] = change.Path
+	}
+	return paths
+}
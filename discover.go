@@ -0,0 +1,51 @@
+package goconfig
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// SearchPaths returns the directories searched for the config of app by
+// Discover, highest priority first: the working directory,
+// $XDG_CONFIG_HOME/<app> (~/.config/<app> if unset) and /etc/<app>.
+func SearchPaths(app string) []string {
+	dirs := []string{"."}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		dirs = append(dirs, filepath.Join(xdg, app))
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config", app))
+	}
+	return append(dirs, filepath.Join("/etc", app))
+}
+
+// discoverFile returns the first existing file called name, with any known
+// config extension, in dirs. It returns "" if there is none.
+func discoverFile(dirs, names []string) string {
+	exts := configExtensions()
+	for _, dir := range dirs {
+		for _, name := range names {
+			for _, ext := range exts {
+				filename := filepath.Join(dir, name+ext)
+				if info, err := os.Stat(filename); err == nil && !info.IsDir() {
+					return filename
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Discover searches for the config file when the config has no filename set
+// and none is given with WithFile or WithFiles. It looks in SearchPaths(app)
+// for a file called "config", or any of names if given, with any known config
+// extension. The file found is recorded as the config's filename, so that
+// later reloads use it too.
+func Discover(app string, names ...string) Option {
+	if len(names) == 0 {
+		names = []string{"config"}
+	}
+	return func(o *options) {
+		o.discoverDirs = SearchPaths(app)
+		o.discoverNames = names
+	}
+}
@@ -0,0 +1,133 @@
+package goconfig
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Markdown writes a Markdown table documenting every option of c, which may
+// be a config, a struct or a pointer to one: its yaml key, environment
+// variable, type, default, whether it is required and its description from the
+// `desc` tag, noting `deprecated` ones. Nested sections are flattened into
+// dotted keys, with `[]` for the elements of lists and `.*` for those of maps.
+func Markdown(c interface{}, w io.Writer) error {
+	prefix := ""
+	if cfg, ok := c.(Configterface); ok {
+		prefix = envPrefix(cfg)
+		c = configTarget(cfg)
+	}
+	t := reflect.TypeOf(c)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ErrNotPointer
+	}
+	var b strings.Builder
+	b.WriteString("| Key | Environment variable | Type | Default | Required | Description |\n")
+	b.WriteString("|-----|----------------------|------|---------|----------|-------------|\n")
+	writeDocRows(&b, t, "", prefix, map[reflect.Type]bool{})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeDocRows(b *strings.Builder, t reflect.Type, path, envPrefix string, seen map[reflect.Type]bool) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	defer delete(seen, t)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !exported(sf) || sf.Type == mutexType || sf.Type == rwMutexType || sf.Tag.Get("yaml") == "-" {
+			continue
+		}
+		key := joinPath(path, yamlKey(sf))
+		ft, suffix := sf.Type, ""
+		for {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			} else if (ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array || ft.Kind() == reflect.Map) && isSection(ft.Elem()) {
+				if ft.Kind() == reflect.Map {
+					suffix += ".*"
+				} else {
+					suffix += "[]"
+				}
+				ft = ft.Elem()
+			} else {
+				break
+			}
+		}
+		if isSection(ft) {
+			writeDocRows(b, ft, key+suffix, envPrefix, seen)
+			continue
+		}
+		env, _, _ := strings.Cut(sf.Tag.Get("env"), ",")
+		if env != "" {
+			env = "`" + envPrefix + env + "`"
+		}
+		def := sf.Tag.Get("default")
+		if def == "" {
+			def = sf.Tag.Get("envDefault")
+		}
+		if def != "" {
+			def = "`" + def + "`"
+		}
+		desc := sf.Tag.Get("desc")
+		if note, ok := sf.Tag.Lookup("deprecated"); ok {
+			desc = strings.TrimSpace(desc + " Deprecated: " + note)
+		}
+		fmt.Fprintf(b, "| `%s` | %s | %s | %s | %s | %s |\n", key, env, docType(sf.Type), escapeCell(def), docRequired(sf), escapeCell(desc))
+	}
+}
+
+// docType names the type t for the docs.
+func docType(t reflect.Type) string {
+	switch t {
+	case durationType:
+		return "duration"
+	case timeType:
+		return "time"
+	case secretType:
+		return "secret"
+	case byteSizeType:
+		return "byte size"
+	case levelType:
+		return "log level"
+	case urlType:
+		return "URL"
+	case ipType, addrType:
+		return "IP address"
+	case ipNetType, prefixType:
+		return "CIDR"
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return docType(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return "list of " + docType(t.Elem())
+	case reflect.Map:
+		return "map of " + docType(t.Elem())
+	}
+	return t.String()
+}
+
+func docRequired(sf reflect.StructField) string {
+	if sf.Tag.Get("required") == "true" || strings.HasSuffix(sf.Tag.Get("env"), ",required") {
+		return "yes"
+	}
+	if cond := sf.Tag.Get("required_if"); cond != "" {
+		return "if `" + cond + "`"
+	}
+	if with := sf.Tag.Get("required_with"); with != "" {
+		return "with `" + with + "`"
+	}
+	return ""
+}
+
+// escapeCell escapes the pipes in s, which would end a table cell.
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,701 @@
+package goconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// includeKey is the top-level key listing files to merge a document over.
+	includeKey = "include"
+	// extendsKey is the top-level key naming the parent a document inherits
+	// from, which may extend another in turn.
+	extendsKey = "extends"
+	// defaultProfile is the profile that other profiles are merged over.
+	defaultProfile = "default"
+)
+
+// document is a decoded config source. The raw data is kept so that a document
+// that was not merged with anything can be decoded straight into the struct,
+// keeping the decoder's own error messages.
+type document struct {
+	filename  string
+	data      []byte
+	format    string
+	unmarshal DecodeFunc
+	tree      map[string]interface{}
+	// merged is set once tree no longer reflects data alone.
+	merged bool
+	// sources holds the documents the values of a merged tree came from, by
+	// key, so that decode errors are reported in the files that set the
+	// values. It is nil until the document is overlaid.
+	sources map[string]*origin
+}
+
+// origin is a document the values of a merged tree came from.
+type origin struct {
+	filename string
+	format   string
+	data     []byte
+}
+
+func newDocument(data []byte, format string, unmarshal DecodeFunc) (*document, error) {
+	tree, err := decodeToTree(data, unmarshal)
+	if err != nil {
+		return nil, err
+	}
+	return &document{data: data, format: format, unmarshal: unmarshal, tree: tree}, nil
+}
+
+// decode decodes the document into v. A nil document decodes to nothing. In
+// strict mode, keys that don't map to a field of v are an error.
+func (d *document) decode(v interface{}, strict bool) error {
+	if d == nil {
+		return nil
+	}
+	prepared, err := prepareTree(d.tree, reflect.TypeOf(v))
+	if err != nil {
+		return fileError(d.filename, err)
+	}
+	if err := allocateSections(d.tree, reflect.ValueOf(v).Elem(), fieldPath{}); err != nil {
+		return err
+	}
+	if prepared.changed {
+		d.merged = true
+	}
+	// The raw data of a document can't be decoded into structs embedding
+	// structs yaml doesn't flatten.
+	if !d.merged && !embedsUntagged(reflect.TypeOf(v), map[reflect.Type]bool{}) {
+		if len(d.data) == 0 {
+			return nil
+		}
+		if d.format == FormatYAML {
+			return positionError(d.filename, d.format, d.data, decodeYAML(d.data, v, strict))
+		}
+		if !strict {
+			return d.unmarshal(d.data, v)
+		}
+	}
+	data, err := marshalTree(d.tree, v)
+	if err == nil {
+		err = decodeYAML(data, v, strict)
+	}
+	if err != nil {
+		if errs := treePositionErrors(data, err, d.originOf); errs != nil {
+			return errs
+		}
+		if d.sources != nil {
+			return err
+		}
+		return fileError(d.filename, err)
+	}
+	prepared.apply(v)
+	return nil
+}
+
+// origins returns the sources of d, recording d itself as the origin of every
+// value of its tree unless it was overlaid by others.
+func (d *document) origins() map[string]*origin {
+	if d.sources == nil {
+		d.sources = map[string]*origin{}
+		o := &origin{filename: d.filename, format: d.format, data: d.data}
+		treeKeys(d.tree, "", func(key string) {
+			d.sources[key] = o
+		})
+	}
+	return d.sources
+}
+
+// originOf returns the document that set the value at key, or the value
+// holding it, or nil if that isn't known.
+func (d *document) originOf(key string) *origin {
+	if d.sources == nil {
+		if d.merged {
+			return &origin{filename: d.filename}
+		}
+		return &origin{filename: d.filename, format: d.format, data: d.data}
+	}
+	for {
+		if o, ok := d.sources[key]; ok {
+			return o
+		}
+		i := strings.LastIndexByte(key, '.')
+		if i < 0 {
+			return nil
+		}
+		key = key[:i]
+	}
+}
+
+// treeKeys calls fn with key and the dotted key paths of the values in the
+// document tree v, the value at key.
+func treeKeys(v interface{}, key string, fn func(key string)) {
+	if key != "" {
+		fn(key)
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			treeKeys(item, joinPath(key, k), fn)
+		}
+	case []interface{}:
+		for i, item := range t {
+			treeKeys(item, joinPath(key, strconv.Itoa(i)), fn)
+		}
+	}
+}
+
+// overlay deep-merges top over base, with the strategies of rules, and returns
+// the result. Either may be nil.
+func overlay(base, top *document, rules *mergeRules) *document {
+	if base == nil {
+		return top
+	}
+	if top == nil {
+		return base
+	}
+	sources := base.origins()
+	for key, o := range top.origins() {
+		sources[key] = o
+	}
+	base.tree = mergeTreesWith(base.tree, top.tree, rules)
+	base.merged = true
+	return base
+}
+
+// selectProfile replaces the tree of doc with its named profile section merged
+// over the default one, with the strategies of rules.
+func selectProfile(doc *document, profile string, rules *mergeRules) (*document, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	base, err := profileSection(doc.tree, defaultProfile)
+	if err != nil {
+		return nil, err
+	}
+	if profile != defaultProfile {
+		if _, ok := doc.tree[profile]; !ok {
+			return nil, fmt.Errorf("profile %q not found", profile)
+		}
+		top, err := profileSection(doc.tree, profile)
+		if err != nil {
+			return nil, err
+		}
+		base = mergeTreesWith(base, top, rules)
+	}
+	doc.tree = base
+	doc.merged = true
+	return doc, nil
+}
+
+func profileSection(tree map[string]interface{}, profile string) (map[string]interface{}, error) {
+	switch section := tree[profile].(type) {
+	case nil:
+		return map[string]interface{}{}, nil
+	case map[string]interface{}:
+		return section, nil
+	}
+	return nil, fmt.Errorf("profile %q is not a map", profile)
+}
+
+// decodeToTree decodes data into a generic document tree using unmarshal.
+func decodeToTree(data []byte, unmarshal DecodeFunc) (map[string]interface{}, error) {
+	tree := map[string]interface{}{}
+	if len(data) == 0 {
+		return tree, nil
+	}
+	if err := unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	return normalize(tree).(map[string]interface{}), nil
+}
+
+// fileSystem abstracts over the OS filesystem and fs.FS so that relative
+// includes resolve the same way for both.
+type fileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Stat(name string) (fs.FileInfo, error)
+	Join(dir, name string) string
+	// Rel resolves name relative to the directory of the file parent.
+	Rel(parent, name string) string
+	// Glob returns the names matching pattern, in lexical order.
+	Glob(pattern string) ([]string, error)
+}
+
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) Join(dir, name string) string {
+	return filepath.Join(dir, name)
+}
+
+func (osFS) Rel(parent, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(parent), name)
+}
+
+func (osFS) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+type ioFS struct {
+	fs.FS
+}
+
+func (f ioFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(f.FS, name)
+}
+
+func (f ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(f.FS, name)
+}
+
+func (f ioFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.FS, name)
+}
+
+func (ioFS) Join(dir, name string) string {
+	return path.Join(dir, name)
+}
+
+func (ioFS) Rel(parent, name string) string {
+	return path.Join(path.Dir(parent), name)
+}
+
+func (f ioFS) Glob(pattern string) ([]string, error) {
+	return fs.Glob(f.FS, pattern)
+}
+
+// docReader reads config documents from a fileSystem, resolving includes.
+type docReader struct {
+	fsys fileSystem
+	o    *options
+	// stack holds the files whose includes are being resolved, to detect
+	// cycles.
+	stack []string
+	// permissions, if set, checks the permissions of the files read.
+	permissions *FilePermissions
+	// rules are the merge strategies used to merge the files read.
+	rules *mergeRules
+	// migrations, if set, upgrade each file read to the current version.
+	migrations *versionMigrations
+	// target, if set, is the config struct type the files are read for, whose
+	// `alias` and `deprecated` tags apply to them.
+	target reflect.Type
+	// ctx is the context of the load, carrying its trace.
+	ctx context.Context
+	// span is the span of the file being read.
+	span Span
+	// digest is the checksum of the files read so far.
+	digest hash.Hash
+}
+
+func newDocReader(fsys fileSystem, o *options) *docReader {
+	return &docReader{fsys: fsys, o: o, ctx: context.Background(), span: noopSpan{}, digest: sha256.New()}
+}
+
+// sum adds the data read from filename to the checksum of the files read.
+func (r *docReader) sum(filename string, data []byte) {
+	fmt.Fprintf(r.digest, "%s\x00%d\x00", filename, len(data))
+	r.digest.Write(data)
+}
+
+// sumEnvFiles adds the files the environment names for the fields of c, and of
+// the sections loaded with it, to the checksum of the files read, so that a
+// reload after a mounted secret was rotated isn't skipped as unchanged.
+func (r *docReader) sumEnvFiles(c Configterface) {
+	c.RLock()
+	prefix := r.o.envPrefixFor(c)
+	c.RUnlock()
+	types := []reflect.Type{reflect.TypeOf(configTarget(c))}
+	if r.o.sections {
+		sectionsMu.RLock()
+		for _, section := range sections {
+			types = append(types, reflect.TypeOf(section))
+		}
+		sectionsMu.RUnlock()
+	}
+	files := map[string]string{}
+	for _, t := range types {
+		if t := structType(t); t != nil && t.Kind() == reflect.Struct {
+			envFiles(t, prefix, nil, files, map[reflect.Type]bool{})
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := ioutil.ReadFile(files[name])
+		if err != nil {
+			data = []byte(err.Error())
+		}
+		r.sum(name+"="+files[name], data)
+	}
+}
+
+// readerFor returns a docReader for the files of c, loaded with ctx, merged
+// with the strategies of its `merge` tags, upgraded with its migrations and
+// with the keys of its `alias` tags renamed.
+func readerFor(ctx context.Context, fsys fileSystem, o *options, c Configterface) (*docReader, error) {
+	t := reflect.TypeOf(configTarget(c))
+	rules, err := mergeRulesFor(t)
+	if err != nil {
+		return nil, err
+	}
+	r := newDocReader(fsys, o)
+	r.rules = rules
+	r.migrations = migrationsFor(t)
+	r.target = t
+	r.ctx = ctx
+	return r, nil
+}
+
+// read reads and decodes filename, which may also be a directory or a URL. A
+// missing file yields a nil document. Nothing is read once the context of the
+// load is done.
+func (r *docReader) read(filename string) (doc *document, err error) {
+	if err := r.ctx.Err(); err != nil {
+		return nil, err
+	}
+	source := filename
+	u, fetch, remote := remoteURL(filename)
+	if remote {
+		source = u.Redacted()
+	}
+	ctx, span := r.o.startSpan(r.ctx, "goconfig.read", "source", source)
+	parentCtx, parentSpan := r.ctx, r.span
+	r.ctx, r.span = ctx, span
+	defer func() {
+		r.ctx, r.span = parentCtx, parentSpan
+		span.End(err)
+	}()
+	if remote {
+		return r.readRemote(filename, u, fetch)
+	}
+	var info fs.FileInfo
+	err = r.retry(source, func() (err error) {
+		info, err = r.fsys.Stat(filename)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			if isGlob(filename) {
+				return r.readGlob(filename)
+			}
+			return nil, nil
+		}
+		return nil, err
+	}
+	if info.IsDir() {
+		return r.readDir(filename)
+	}
+	if r.permissions != nil {
+		if err := r.permissions.check(filename, info, r.o.log()); err != nil {
+			return nil, err
+		}
+	}
+	var data []byte
+	err = r.retry(source, func() (err error) {
+		data, err = r.fsys.ReadFile(filename)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	r.span.SetAttributes("size", len(data))
+	r.sum(filename, data)
+	return r.parse(filename, data)
+}
+
+// readDir merges every config file directly inside dir in lexical order, as
+// with a conf.d directory. Hidden files, subdirectories and files without a
+// known config extension are skipped.
+func (r *docReader) readDir(dir string) (*document, error) {
+	entries, err := r.fsys.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var doc *document
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !isConfigFile(name) {
+			continue
+		}
+		d, err := r.read(r.fsys.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		doc = overlay(doc, d, r.rules)
+	}
+	return doc, nil
+}
+
+// isGlob reports whether filename is a pattern such as conf/*.yaml.
+func isGlob(filename string) bool {
+	return strings.ContainsAny(filename, "*?[")
+}
+
+// readGlob merges the files matching pattern in lexical order, as readDir
+// merges the files of a directory. No match yields a nil document, as a
+// missing file does.
+func (r *docReader) readGlob(pattern string) (*document, error) {
+	matches, err := r.fsys.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pattern, err)
+	}
+	var doc *document
+	for _, name := range matches {
+		d, err := r.read(name)
+		if err != nil {
+			return nil, err
+		}
+		doc = overlay(doc, d, r.rules)
+	}
+	return doc, nil
+}
+
+// parse decodes data, read from filename, and resolves its includes.
+func (r *docReader) parse(filename string, data []byte) (*document, error) {
+	format, unmarshal, err := decoderFor(filename, r.o.format)
+	if err != nil {
+		return nil, err
+	}
+	if r.o.template != nil {
+		if data, err = r.o.template.execute(filename, data); err != nil {
+			return nil, fileError(filename, err)
+		}
+	}
+	if format == FormatYAML && (r.o.document > 0 || r.o.mergeDocuments) {
+		return r.parseMulti(filename, data, unmarshal)
+	}
+	doc, err := newDocument(data, format, unmarshal)
+	if err != nil {
+		return nil, positionError(filename, format, data, err)
+	}
+	doc.filename = filename
+	if err := r.decrypt(filename, doc); err != nil {
+		return nil, err
+	}
+	if r.migrations != nil {
+		if err := r.migrations.migrate(filename, doc, r.o); err != nil {
+			return nil, err
+		}
+	}
+	if r.target != nil {
+		r.resolveAliases(filename, doc)
+	}
+	return r.resolveIncludes(filename, doc)
+}
+
+// parseMulti decodes a multi-document YAML stream, returning either the
+// selected document or all of them merged in order.
+func (r *docReader) parseMulti(filename string, data []byte, unmarshal DecodeFunc) (*document, error) {
+	parts := splitYAMLDocuments(data)
+	if !r.o.mergeDocuments {
+		if r.o.document >= len(parts) {
+			return nil, fileError(filename, fmt.Errorf("document %d not found, there are only %d", r.o.document, len(parts)))
+		}
+		parts = parts[r.o.document : r.o.document+1]
+	}
+	var merged *document
+	for _, part := range parts {
+		doc, err := newDocument(part, FormatYAML, unmarshal)
+		if err != nil {
+			return nil, positionError(filename, FormatYAML, part, err)
+		}
+		doc.filename = filename
+		if err := r.decrypt(filename, doc); err != nil {
+			return nil, err
+		}
+		if r.migrations != nil {
+			if err := r.migrations.migrate(filename, doc, r.o); err != nil {
+				return nil, err
+			}
+		}
+		if r.target != nil {
+			r.resolveAliases(filename, doc)
+		}
+		if doc, err = r.resolveIncludes(filename, doc); err != nil {
+			return nil, err
+		}
+		merged = overlay(merged, doc, r.rules)
+	}
+	return merged, nil
+}
+
+// decrypt decrypts doc if it is a SOPS-encrypted file, and any ENC[...]
+// values it holds.
+func (r *docReader) decrypt(filename string, doc *document) error {
+	if (doc.format == FormatYAML || doc.format == FormatJSON) && isSOPS(doc.tree) {
+		if err := decryptSOPS(r.ctx, doc, r.o); err != nil {
+			return fileError(filename, fmt.Errorf("sops: %w", err))
+		}
+	}
+	if err := decryptAgeValues(doc, r.o); err != nil {
+		return fileError(filename, err)
+	}
+	return nil
+}
+
+// splitYAMLDocuments splits a YAML stream on its `---` document markers. Each
+// document is padded with the newlines preceding it in the stream, so that the
+// line numbers of errors stay correct.
+func splitYAMLDocuments(data []byte) [][]byte {
+	lines := strings.SplitAfter(string(data), "\n")
+	var docs [][]byte
+	start := 0
+	// hasContent is set once the current document holds more than comments
+	// and directives.
+	hasContent := false
+	flush := func(end int) {
+		doc := strings.Repeat("\n", start) + strings.Join(lines[start:end], "")
+		docs = append(docs, []byte(doc))
+	}
+	for i, line := range lines {
+		if line == "---" || strings.HasPrefix(line, "---\n") || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "---\r") {
+			if hasContent {
+				flush(i)
+				start = i
+			}
+			hasContent = true
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "%") {
+			hasContent = true
+		}
+	}
+	if hasContent || len(docs) == 0 {
+		flush(len(lines))
+	}
+	return docs
+}
+
+// resolveIncludes merges doc over the parent named under its extends key,
+// with the parents that one extends, then over the files listed under its
+// include key, in order. The paths are relative to filename, which may be a
+// URL, unless they are URLs themselves.
+func (r *docReader) resolveIncludes(filename string, doc *document) (*document, error) {
+	includes, err := stringList(doc.tree[includeKey])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", filename, includeKey, err)
+	}
+	var parent string
+	if extends, ok := doc.tree[extendsKey]; ok {
+		if parent, ok = extends.(string); !ok || parent == "" {
+			return nil, fmt.Errorf("%s: %s: expected a path or URL, got %v", filename, extendsKey, extends)
+		}
+	}
+	if len(includes) == 0 && parent == "" {
+		return doc, nil
+	}
+	for _, f := range r.stack {
+		if f == filename {
+			if parent != "" {
+				return nil, fmt.Errorf("%s: %s cycle", filename, extendsKey)
+			}
+			return nil, fmt.Errorf("%s: include cycle", filename)
+		}
+	}
+	r.stack = append(r.stack, filename)
+	defer func() {
+		r.stack = r.stack[:len(r.stack)-1]
+	}()
+	delete(doc.tree, includeKey)
+	delete(doc.tree, extendsKey)
+	doc.merged = true
+	var base *document
+	if parent != "" {
+		name := r.resolve(filename, parent)
+		if base, err = r.read(name); err != nil {
+			return nil, err
+		}
+		if base == nil {
+			return nil, fmt.Errorf("%s: extended file %s does not exist", filename, name)
+		}
+	}
+	for _, include := range includes {
+		name := r.resolve(filename, include)
+		included, err := r.read(name)
+		if err != nil {
+			return nil, err
+		}
+		if included == nil {
+			return nil, fmt.Errorf("%s: included file %s does not exist", filename, name)
+		}
+		base = overlay(base, included, r.rules)
+	}
+	return overlay(base, doc, r.rules), nil
+}
+
+// resolve returns the file or URL that name, referred to by filename, stands
+// for.
+func (r *docReader) resolve(filename, name string) string {
+	if u, _, ok := remoteURL(filename); ok {
+		return resolveRemote(u, name)
+	}
+	if _, _, ok := remoteURL(name); ok {
+		return name
+	}
+	return r.fsys.Rel(filename, name)
+}
+
+// fileError prefixes err with filename, if there is one.
+func fileError(filename string, err error) error {
+	if filename == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", filename, err)
+}
+
+// stringList accepts either a single string or a list of strings.
+func stringList(v interface{}) ([]string, error) {
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{t}, nil
+	case []interface{}:
+		list := make([]string, 0, len(t))
+		for _, item := range t {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, got %v", item)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("expected a string or list of strings, got %v", v)
+}
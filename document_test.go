@@ -0,0 +1,180 @@
+package goconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergedDecodeErrors(t *testing.T) {
+	type config struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+		TLS  struct {
+			Ports []int `yaml:"ports"`
+		} `yaml:"tls"`
+	}
+	tests := []struct {
+		name   string
+		files  map[string]string
+		strict bool
+		want   []string
+	}{
+		{
+			name:  "type error in an overlay",
+			files: map[string]string{"a.yaml": "host: a\nport: 1\n", "b.yaml": "# b\n\nport: abc\n"},
+			want:  []string{"b.yaml:3:7: cannot unmarshal !!str `abc` into int"},
+		},
+		{
+			name:  "type error in a nested list",
+			files: map[string]string{"a.yaml": "tls: {ports: [1]}\n", "b.yaml": "tls:\n  ports:\n    - 443\n    - x\n"},
+			want:  []string{"b.yaml:4:7: cannot unmarshal !!str `x` into int"},
+		},
+		{
+			name:   "unknown key in an overlay",
+			files:  map[string]string{"a.yaml": "host: a\n", "b.yaml": "port: 2\nbogus: 1\n"},
+			strict: true,
+			want:   []string{"b.yaml:2:1: field bogus not found"},
+		},
+		{
+			name:   "unknown key in strict JSON",
+			files:  map[string]string{"a.json": "{\"port\": 1,\n \"bogus\": 2}\n"},
+			strict: true,
+			want:   []string{"a.json: bogus: field bogus not found"},
+		},
+		{
+			name:  "type errors in several files",
+			files: map[string]string{"a.json": `{"port": "abc"}`, "b.yaml": "host: [x]\n"},
+			want:  []string{"a.json: port: cannot unmarshal", "b.yaml:1:7: cannot unmarshal !!seq"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			for _, name := range []string{"a.yaml", "a.json", "b.yaml"} {
+				if content, ok := tt.files[name]; ok {
+					opts = append(opts, WithFile(writeConfigFile(t, name, content)))
+				}
+			}
+			if tt.strict {
+				opts = append(opts, Strict())
+			}
+			var c config
+			err := Load(&c, opts...)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't contain %q", err, want)
+				}
+			}
+			if strings.Contains(err.Error(), "line ") {
+				t.Errorf("error %q refers to the lines of the merged tree", err)
+			}
+		})
+	}
+}
+
+func TestSplitYAMLDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{name: "empty", data: "", want: []string{""}},
+		{name: "single", data: "a: 1\n", want: []string{"a: 1\n"}},
+		{name: "two", data: "a: 1\n---\nb: 2\n", want: []string{"a: 1\n", "\n---\nb: 2\n"}},
+		{name: "leading marker", data: "---\na: 1\n---\nb: 2\n", want: []string{"---\na: 1\n", "\n\n---\nb: 2\n"}},
+		{name: "content on the marker", data: "--- a: 1\n--- b: 2\n", want: []string{"--- a: 1\n", "\n--- b: 2\n"}},
+		{name: "comments and directives first", data: "# c\n%YAML 1.2\n---\na: 1\n", want: []string{"# c\n%YAML 1.2\n---\na: 1\n"}},
+		{name: "crlf", data: "a: 1\r\n---\r\nb: 2\r\n", want: []string{"a: 1\r\n", "\n---\r\nb: 2\r\n"}},
+		{name: "not markers", data: "a: ---\n----\nb: 2\n", want: []string{"a: ---\n----\nb: 2\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, doc := range splitYAMLDocuments([]byte(tt.data)) {
+				got = append(got, string(doc))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitYAMLDocuments(%q) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadYAMLDocuments(t *testing.T) {
+	var c struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	}
+	filename := writeConfigFile(t, "config.yaml", "# defaults\nhost: a\nport: 1\n---\nport: 2\n---\nport: x\n")
+	if err := Load(&c, WithFile(filename), WithDocument(1)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "" || c.Port != 2 {
+		t.Errorf("document 1 = %q, %d", c.Host, c.Port)
+	}
+	err := Load(&c, WithFile(filename), WithDocument(2))
+	if err == nil || !strings.Contains(err.Error(), "config.yaml:7:") {
+		t.Errorf("err = %v, want it at line 7", err)
+	}
+	if err := Load(&c, WithFile(filename), WithDocument(3)); err == nil {
+		t.Error("expected an error for a missing document")
+	}
+}
+
+type overlayConfig struct {
+	Server struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	} `yaml:"server"`
+	Debug bool     `yaml:"debug"`
+	Tags  []string `yaml:"tags"`
+}
+
+func TestLoadFilesOverlay(t *testing.T) {
+	dir := filepath.Dir(writeConfigFile(t, "base.yaml", "server: {host: a, port: 1}\ntags: [x, y]\n"))
+	base := filepath.Join(dir, "base.yaml")
+	local := filepath.Join(dir, "local.yaml")
+	if err := os.WriteFile(local, []byte("server: {port: 2}\ndebug: true\ntags: [z]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var c overlayConfig
+	if err := Load(&c, WithFiles(base, filepath.Join(dir, "missing.yaml"), local)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Host != "a" || c.Server.Port != 2 || !c.Debug {
+		t.Errorf("config = %+v, %v", c.Server, c.Debug)
+	}
+	if strings.Join(c.Tags, " ") != "z" {
+		t.Errorf("tags = %v, want the later file's", c.Tags)
+	}
+
+	// The files are merged in the order given.
+	var reversed overlayConfig
+	if err := Load(&reversed, WithFiles(local, base)); err != nil {
+		t.Fatal(err)
+	}
+	if reversed.Server.Port != 1 || !reversed.Debug || strings.Join(reversed.Tags, " ") != "x y" {
+		t.Errorf("config = %+v, %v, %v", reversed.Server, reversed.Debug, reversed.Tags)
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	filename := writeConfigFile(t, "config.yaml", "server: {host: a, prot: 2}\n")
+	var c overlayConfig
+	if err := Load(&c, WithFile(filename)); err != nil {
+		t.Fatalf("unknown keys rejected without Strict: %v", err)
+	}
+	err := Load(&c, WithFile(filename), Strict())
+	if err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Errorf("err = %v, want it to name the unknown key", err)
+	}
+	if err := Load(&c, WithFile(writeConfigFile(t, "config.yaml", "server: {host: a, port: 2}\n")), Strict()); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,110 @@
+package goconfig
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"strings"
+)
+
+// defaultDotEnv is the file WithDotEnv loads when given no paths.
+const defaultDotEnv = ".env"
+
+// readDotEnv returns the variables defined in the given dotenv files, those of
+// earlier files taking precedence. Missing files are skipped.
+func readDotEnv(filenames []string) (environment, error) {
+	env := environment{}
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		vars, err := parseDotEnv(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		for _, kv := range vars {
+			if _, ok := env[kv[0]]; !ok {
+				env[kv[0]] = kv[1]
+			}
+		}
+	}
+	return env, nil
+}
+
+// parseDotEnv parses KEY=value lines, in order. Lines may start with
+// `export`, blank lines and lines starting with # are ignored, and values may
+// be single quoted (taken literally), double quoted (with \n, \t, \" and \\
+// escapes) or bare, in which case a # preceded by a space starts a comment.
+func parseDotEnv(data []byte) ([][2]string, error) {
+	var vars [][2]string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+		value, err := parseDotEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		vars = append(vars, [2]string{key, value})
+	}
+	return vars, scanner.Err()
+}
+
+func parseDotEnvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	switch quote := value[0]; quote {
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", errors.New("unterminated single quote")
+		}
+		return value[1 : end+1], nil
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(value); i++ {
+			switch c := value[i]; c {
+			case '"':
+				return b.String(), nil
+			case '\\':
+				if i+1 == len(value) {
+					break
+				}
+				i++
+				switch value[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case 'r':
+					b.WriteByte('\r')
+				default:
+					b.WriteByte(value[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", errors.New("unterminated double quote")
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
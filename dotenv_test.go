@@ -0,0 +1,53 @@
+package goconfig
+
+import (
+	"os"
+	"testing"
+)
+
+type dotEnvConfig struct {
+	Host     string `yaml:"host" env:"DOTENVTEST_HOST"`
+	Port     int    `yaml:"port" env:"DOTENVTEST_PORT"`
+	Password string `yaml:"password" env:"DOTENVTEST_PASSWORD"`
+	Region   string `yaml:"region"`
+	Features Flags  `yaml:"features" env:"DOTENVTEST_FEATURE_"`
+}
+
+func TestDotEnvLeavesEnvironmentAlone(t *testing.T) {
+	secret := writeConfigFile(t, "password", "hunter2\n")
+	dotEnv := writeConfigFile(t, ".env", "DOTENVTEST_HOST=example.com\nDOTENVTEST_PORT=80\n"+
+		"DOTENVTEST_PASSWORD_FILE="+secret+"\nDOTENVTEST_REGION=eu\nDOTENVTEST_FEATURE_NEW=true\n")
+	override := writeConfigFile(t, "override.env", "DOTENVTEST_PORT=81\n")
+	t.Setenv("DOTENVTEST_HOST", "localhost")
+	config := writeConfigFile(t, "config.yaml", "region: ${DOTENVTEST_REGION}\n")
+
+	var c dotEnvConfig
+	if err := Validate(&c, WithFile(config), WithDotEnv(override, dotEnv), ExpandEnv()); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(&c, WithFile(config), WithDotEnv(override, dotEnv), ExpandEnv()); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"DOTENVTEST_PORT", "DOTENVTEST_PASSWORD_FILE", "DOTENVTEST_REGION", "DOTENVTEST_FEATURE_NEW"} {
+		if value, ok := os.LookupEnv(name); ok {
+			t.Errorf("%s set to %q in the process", name, value)
+		}
+	}
+	if c.Host != "localhost" {
+		t.Errorf("host = %q, want the environment over the dotenv file", c.Host)
+	}
+	if c.Port != 81 {
+		t.Errorf("port = %d, want that of the first dotenv file", c.Port)
+	}
+	if c.Password != "hunter2" || c.Region != "eu" || c.Features["new"] != "true" {
+		t.Errorf("config = %q, %q, %v", c.Password, c.Region, c.Features)
+	}
+
+	var unset dotEnvConfig
+	if err := Load(&unset, WithFile(config), ExpandEnv()); err != nil {
+		t.Fatal(err)
+	}
+	if unset.Port != 0 || unset.Region != "" {
+		t.Errorf("dotenv variables seen without WithDotEnv: %d, %q", unset.Port, unset.Region)
+	}
+}
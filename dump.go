@@ -0,0 +1,74 @@
+package goconfig
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// Dump writes the effective config, after files, environment variables and
+// defaults have been applied, to w, with the values of fields tagged
+// `secret:"true"` masked. It is written as YAML unless another format ("json"
+// or "toml") is given with WithFormat.
+func Dump(config interface{}, w io.Writer, opts ...Option) error {
+	c := configOf(config)
+	if err := checkPointer(c); err != nil {
+		return err
+	}
+	format := strings.ToLower(newOptions(opts).format)
+	if format == "" {
+		format = FormatYAML
+	}
+	c.RLock()
+	data, err := marshalConfig(configTarget(c), format, true)
+	c.RUnlock()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// DumpOnSignal writes the current config to w as YAML, with secret fields
+// masked, whenever the process receives one of signals (SIGUSR2 by default),
+// until ctx is done. With a nil w, the config is written to the standard
+// logger instead. This tells what config a running process is actually using.
+func DumpOnSignal(ctx context.Context, config interface{}, w io.Writer, signals ...os.Signal) error {
+	c := keepConfig(config)
+	if err := checkPointer(c); err != nil {
+		return err
+	}
+	if len(signals) == 0 {
+		signals = dumpSignals
+	}
+	if len(signals) == 0 {
+		return errors.New("no signal to dump the config on")
+	}
+	s := make(chan os.Signal, 1)
+	signal.Notify(s, signals...)
+	go func() {
+		for {
+			select {
+			case <-s:
+				c.RLock()
+				data, err := marshalConfig(configTarget(c), FormatYAML, true)
+				c.RUnlock()
+				if err != nil {
+					log.Printf("config dump error: %s", err)
+				} else if w == nil {
+					log.Printf("config:\n%s", data)
+				} else if _, err := w.Write(data); err != nil {
+					log.Printf("config dump error: %s", err)
+				}
+			case <-ctx.Done():
+				signal.Stop(s)
+				return
+			}
+		}
+	}()
+	return nil
+}
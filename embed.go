@@ -0,0 +1,199 @@
+package goconfig
+
+import (
+	"reflect"
+	"strings"
+)
+
+// embedded reports whether sf is an embedded struct, or a pointer to one,
+// whose fields are promoted to the struct holding it, as encoding/json
+// promotes them: their keys, `env` tags, defaults and rules are read as if
+// they were declared in the parent, unless the field names a key of its own
+// in its yaml tag.
+func embedded(sf reflect.StructField) bool {
+	if !sf.Anonymous {
+		return false
+	}
+	t := sf.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType && !parsesItself(t)
+}
+
+// exported reports whether the fields of sf are loaded: it is exported, or is
+// an embedded struct, exported or not, whose exported fields are promoted.
+func exported(sf reflect.StructField) bool {
+	return sf.PkgPath == "" || embedded(sf) && sf.Type.Kind() == reflect.Struct
+}
+
+// inlined reports whether sf is tagged `yaml:",inline"`.
+func inlined(sf reflect.StructField) bool {
+	_, opts, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+	return strings.Contains(","+opts+",", ",inline,")
+}
+
+// unflattened reports whether sf is an embedded struct that yaml doesn't
+// flatten, though goconfig promotes its fields: one without a yaml tag naming
+// it or inlining it.
+func unflattened(sf reflect.StructField) bool {
+	name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+	return embedded(sf) && name == "" && !inlined(sf)
+}
+
+// nestEmbedded returns tree, whose promoted keys are at the level of the
+// struct type t embedding them, rearranged the way yaml decodes it into t:
+// yaml only flattens structs tagged `yaml:",inline"`, and decodes other
+// embedded structs from their lowercased type name. Nested structs are
+// rearranged too, and tree itself is left as it is. changed is set unless
+// tree is returned as it was.
+func nestEmbedded(tree map[string]interface{}, t reflect.Type) (nested map[string]interface{}, changed bool) {
+	t = structType(t)
+	if t.Kind() != reflect.Struct || parsesItself(t) {
+		return tree, false
+	}
+	nested = make(map[string]interface{}, len(tree))
+	for k, v := range tree {
+		nested[k] = v
+	}
+	own := map[string]bool{}
+	for _, key := range structKeys(t, false) {
+		own[key] = true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !exported(sf) || sf.Tag.Get("yaml") == "-" {
+			continue
+		}
+		key := yamlKey(sf)
+		if key != "" {
+			if v, ok := nested[key]; ok {
+				if v, ok := nestValue(v, sf.Type); ok {
+					nested[key] = v
+					changed = true
+				}
+			}
+			continue
+		}
+		if sf.PkgPath != "" && unflattened(sf) {
+			// prepareTree reports its keys, which yaml can't decode.
+			continue
+		}
+		if !unflattened(sf) {
+			// yaml flattens inlined structs, but not the structs they embed.
+			var c bool
+			if nested, c = nestEmbedded(nested, sf.Type); c {
+				changed = true
+			}
+			continue
+		}
+		sub := map[string]interface{}{}
+		for _, k := range structKeys(sf.Type, true) {
+			if v, ok := nested[k]; ok && !own[k] {
+				sub[k] = v
+				delete(nested, k)
+			}
+		}
+		if len(sub) > 0 {
+			nested[strings.ToLower(sf.Name)], _ = nestEmbedded(sub, sf.Type)
+			changed = true
+		}
+	}
+	if !changed {
+		return tree, false
+	}
+	return nested, true
+}
+
+// nestValue applies nestEmbedded to the structs in v, a value for a field of
+// type t, reporting whether it changed.
+func nestValue(v interface{}, t reflect.Type) (interface{}, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if tree, ok := v.(map[string]interface{}); ok {
+			return nestEmbedded(tree, t)
+		}
+	case reflect.Slice, reflect.Array:
+		if list, ok := v.([]interface{}); ok {
+			var changed bool
+			copied := make([]interface{}, len(list))
+			for i, item := range list {
+				var c bool
+				copied[i], c = nestValue(item, t.Elem())
+				changed = changed || c
+			}
+			return copied, changed
+		}
+	case reflect.Map:
+		if m, ok := v.(map[string]interface{}); ok {
+			var changed bool
+			copied := make(map[string]interface{}, len(m))
+			for k, item := range m {
+				var c bool
+				copied[k], c = nestValue(item, t.Elem())
+				changed = changed || c
+			}
+			return copied, changed
+		}
+	}
+	return v, false
+}
+
+// structKeys returns the keys of the fields of the struct type t, with those
+// of the structs it inlines. The keys of embedded structs without a yaml
+// name, which yaml doesn't flatten, are only included if promoted is set.
+func structKeys(t reflect.Type, promoted bool) []string {
+	t = structType(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !exported(sf) || sf.Tag.Get("yaml") == "-" {
+			continue
+		}
+		if key := yamlKey(sf); key != "" {
+			keys = append(keys, key)
+		} else if promoted || !unflattened(sf) {
+			keys = append(keys, structKeys(sf.Type, promoted)...)
+		}
+	}
+	return keys
+}
+
+// embedsUntagged reports whether the struct type t, or a struct within it,
+// embeds a struct that yaml doesn't flatten, so that decoding into t needs
+// nestEmbedded.
+func embedsUntagged(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || parsesItself(t) || seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !exported(sf) || sf.Tag.Get("yaml") == "-" {
+			continue
+		}
+		if unflattened(sf) || embedsUntagged(sf.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnyKey reports whether tree holds any of keys.
+func hasAnyKey(tree map[string]interface{}, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := tree[key]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,370 @@
+package goconfig
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the values of secret fields in dumps and diffs.
+const redacted = "******"
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	yamlMarshalerType = reflect.TypeOf((*yaml.Marshaler)(nil)).Elem()
+)
+
+// marshalConfig encodes the config c in the given format, using the keys of
+// its yaml tags in field order. If redact is set, the values of fields tagged
+// `secret:"true"` are masked.
+func marshalConfig(c interface{}, format string, redact bool) ([]byte, error) {
+	node, err := nodeEncoder{redact: redact}.node(reflect.ValueOf(c), false)
+	if err != nil {
+		return nil, err
+	}
+	return marshalNode(node, format)
+}
+
+// marshalNode encodes node in the given format.
+func marshalNode(node *yaml.Node, format string) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		return encodeYAML(node)
+	case FormatJSON:
+		return encodeJSON(node)
+	case FormatTOML:
+		return encodeTOML(node)
+	}
+	return nil, fmt.Errorf("unsupported config format %q for encoding", format)
+}
+
+func encodeYAML(node *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	e := yaml.NewEncoder(&buf)
+	e.SetIndent(2)
+	if err := e.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// nodeEncoder converts config values into yaml nodes, keeping the order of
+// struct fields.
+type nodeEncoder struct {
+	// redact masks the values of secret fields.
+	redact bool
+	// example comments each field and fills in unset pointers to structs
+	// and slices of structs with a sample element.
+	example bool
+	// saving writes only what may have come from the config file, original,
+	// as Save does: the fields that are secret, tagged with a resolver's tag
+	// or read from the environment with envPrefix are written with the value
+	// original has at their key, or left out.
+	saving    bool
+	original  map[string]interface{}
+	envPrefix *string
+	// env holds the dotenv variables read along with the environment.
+	env environment
+	// key is the dotted yaml key path of the value being converted, kept
+	// when saving.
+	key string
+}
+
+// node converts v into a yaml node. If secret is set, v is masked, as are the
+// secret fields of any structs within it.
+func (e nodeEncoder) node(v reflect.Value, secret bool) (*yaml.Node, error) {
+	if v.IsValid() && v.Type() == secretType {
+		secret = true
+	}
+	if e.redact && secret && v.IsValid() && !isZero(v) {
+		v = reflect.ValueOf(redacted)
+	}
+	if e.example {
+		v = exampleValue(v)
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+		}
+		if v.Kind() == reflect.Ptr && marshalsItself(v.Type()) {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || marshalsItself(v.Type()) || v.Type() == timeType {
+		return encodeNode(v)
+	}
+	if codec, ok := textCodecs[v.Type()]; ok {
+		return encodeNode(reflect.ValueOf(codec.format(v.Interface())))
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		if err := e.addStructFields(node, v); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return encodeNode(v)
+		}
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			elem, err := e.child(strconv.Itoa(i)).node(v.Index(i), false)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, elem)
+		}
+		return node, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return encodeNode(v)
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range keys {
+			k, err := encodeNode(key)
+			if err != nil {
+				return nil, err
+			}
+			val, err := e.child(fmt.Sprint(key.Interface())).node(v.MapIndex(key), false)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, k, val)
+		}
+		return node, nil
+	}
+	return encodeNode(v)
+}
+
+// addStructFields appends the keys and values of the exported fields of the
+// struct v to the mapping node, flattening inlined structs into it.
+func (e nodeEncoder) addStructFields(node *yaml.Node, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if !exported(sf) || sf.Type == mutexType || sf.Type == rwMutexType || sf.Tag.Get("yaml") == "-" {
+			continue
+		}
+		field := v.Field(i)
+		key := yamlKey(sf)
+		if key == "" && reflect.Indirect(field).Kind() == reflect.Struct {
+			if err := e.addStructFields(node, reflect.Indirect(field)); err != nil {
+				return err
+			}
+			continue
+		}
+		if layout := sf.Tag.Get("layout"); layout != "" {
+			field = formatLayout(field, layout)
+		}
+		fe := e.child(key)
+		var val *yaml.Node
+		var err error
+		if e.saving && fe.withheld(sf) {
+			original, ok := treeAt(e.original, fe.key)
+			if !ok {
+				continue
+			}
+			val, err = encodeNode(reflect.ValueOf(original))
+		} else {
+			val, err = fe.node(field, sf.Tag.Get("secret") == "true")
+		}
+		if err != nil {
+			return err
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+		if e.example {
+			keyNode.HeadComment = fieldComment(sf)
+		}
+		node.Content = append(node.Content, keyNode, val)
+	}
+	return nil
+}
+
+// child returns e for the value at key below the one e converts.
+func (e nodeEncoder) child(key string) nodeEncoder {
+	if e.saving {
+		e.key = joinPath(e.key, key)
+	}
+	return e
+}
+
+// withheld reports whether the value of sf, a field e converts, may not have
+// come from the config file, so that Save doesn't write it.
+func (e nodeEncoder) withheld(sf reflect.StructField) bool {
+	if sf.Type == secretType || sf.Tag.Get("secret") == "true" || isResolved(sf) {
+		return true
+	}
+	if e.envPrefix == nil {
+		return false
+	}
+	info := newFieldInfo(sf)
+	return !info.nested && envSets(&info, *e.envPrefix, e.env)
+}
+
+// treeAt returns the value of the document tree at key, a dotted key path.
+func treeAt(tree map[string]interface{}, key string) (interface{}, bool) {
+	var v interface{} = tree
+	for _, part := range strings.Split(key, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			list, isList := v.([]interface{})
+			i, err := strconv.Atoi(part)
+			if !isList || err != nil || i < 0 || i >= len(list) {
+				return nil, false
+			}
+			v = list[i]
+			continue
+		}
+		if v, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// formatLayout formats the times held by v, a field with a `layout` tag, with
+// that layout, so that they are written back as they are read.
+func formatLayout(v reflect.Value, layout string) reflect.Value {
+	switch {
+	case v.Type() == timeType:
+		return reflect.ValueOf(v.Interface().(time.Time).Format(layout))
+	case v.Kind() == reflect.Ptr && !v.IsNil():
+		return formatLayout(v.Elem(), layout)
+	case v.Kind() == reflect.Slice && v.Type().Elem() == timeType && !v.IsNil():
+		formatted := make([]string, v.Len())
+		for i := range formatted {
+			formatted[i] = v.Index(i).Interface().(time.Time).Format(layout)
+		}
+		return reflect.ValueOf(formatted)
+	}
+	return v
+}
+
+// marshalsItself reports whether values of type t provide their own
+// marshaling, which a dump should respect.
+func marshalsItself(t reflect.Type) bool {
+	return t.Implements(textMarshalerType) || t.Implements(yamlMarshalerType)
+}
+
+func encodeNode(v reflect.Value) (*yaml.Node, error) {
+	node := &yaml.Node{}
+	var i interface{}
+	if v.IsValid() {
+		i = v.Interface()
+	}
+	if err := node.Encode(i); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// encodeJSON encodes node as indented JSON, keeping the order of its keys.
+func encodeJSON(node *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, node); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, elem := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case yaml.DocumentNode:
+		return writeJSON(buf, node.Content[0])
+	}
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+// encodeTOML encodes node as TOML. TOML has no null, so unset values are left
+// out, and its keys come out sorted.
+func encodeTOML(node *yaml.Node) ([]byte, error) {
+	tree := map[string]interface{}{}
+	if err := node.Decode(&tree); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(dropNulls(tree)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// dropNulls removes the nil values from the maps of a document tree.
+func dropNulls(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if val == nil {
+				delete(t, k)
+				continue
+			}
+			t[k] = dropNulls(val)
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = dropNulls(val)
+		}
+	}
+	return v
+}
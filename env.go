@@ -0,0 +1,275 @@
+package goconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// parseEnv loads the fields of the struct pointed to by val that have `env`
+// tags from environment variables, each name prefixed with prefix. Nested
+// structs, and pointers to structs that are set, are parsed too and errors for
+// every field are collected.
+//
+// A field whose variable is unset or empty is read from the file named by a
+// companion variable instead: the one in its `envFile` tag, or by default its
+// own variable with a _FILE suffix, as Docker and Kubernetes secrets are
+// usually exposed. Failing that, the value of its `envDefault` tag is used.
+// The `required` option, as in `env:"KEY,required"`, makes an unset variable
+// an error. Slices are split on the `envSeparator` tag, a comma by default, as
+// are maps, whose entries are then split into keys and values on the
+// `envKeyValSeparator` tag, a colon by default, as in KEY=a:1,b:2.
+//
+// The variables are looked up in env. Generated configs read the process
+// environment themselves, so they are read by reflection when env holds
+// dotenv variables.
+func parseEnv(val interface{}, prefix string, env environment) error {
+	if g, ok := val.(Generated); ok && env == nil {
+		return g.GoconfigEnv(prefix)
+	}
+	value := reflect.ValueOf(val)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return errors.New("Expected a pointer to a Struct")
+	}
+	var errs []string
+	parseStructEnv(value.Elem(), prefix, env, &errs)
+	if errs != nil {
+		return errors.New(strings.Join(errs, ". "))
+	}
+	return nil
+}
+
+func parseStructEnv(value reflect.Value, prefix string, env environment, errs *[]string) {
+	fields := fieldsOf(value.Type())
+	for i := range fields {
+		parseEnvField(value.Field(fields[i].Index[0]), &fields[i], prefix, env, errs)
+	}
+}
+
+// parseEnvField loads field, and the fields of the structs it holds, from
+// the environment.
+func parseEnvField(field reflect.Value, f *fieldInfo, prefix string, env environment, errs *[]string) {
+	if f.nested {
+		// Optional sections are only read from the environment if set.
+		if inner, ok := structValue(field); ok {
+			parseStructEnv(inner, prefix, env, errs)
+		}
+		return
+	}
+	if field.Type() == flagsType {
+		parseFlagsEnv(field, f, prefix, env)
+		return
+	}
+	if err := parseFieldEnv(field, f, prefix, env); err != nil {
+		*errs = append(*errs, err.Error())
+	}
+}
+
+func parseFieldEnv(field reflect.Value, f *fieldInfo, prefix string, env environment) error {
+	key, fileKey := envKeys(f, prefix)
+	value, key, err := env.lookupEnv(key, fileKey)
+	if err != nil {
+		return err
+	}
+	for _, opt := range f.envOptions {
+		switch opt {
+		case "":
+		case "required":
+			if value == "" {
+				return errors.New("Required environment variable " + key + " is not set")
+			}
+		default:
+			return errors.New("Env tag option " + opt + " not supported.")
+		}
+	}
+	if value == "" {
+		value = f.envDefault
+	}
+	if value == "" {
+		return nil
+	}
+	if err := setFromStringWith(field, value, f.envFormat); err != nil {
+		return fmt.Errorf("%s: %s", key, err)
+	}
+	return nil
+}
+
+// envKeys returns the names of the variable the field f is read from and of
+// the one naming the file it is read from otherwise, each prefixed with
+// prefix. Either may be empty.
+func envKeys(f *fieldInfo, prefix string) (key, fileKey string) {
+	key = f.env
+	if key != "" {
+		key = prefix + key
+	}
+	fileKey = f.envFile
+	if fileKey != "" {
+		fileKey = prefix + fileKey
+	} else if key != "" {
+		fileKey = key + "_FILE"
+	}
+	return key, fileKey
+}
+
+// envFiles adds the files that the fields of t, a struct type, are read from
+// with env, each name prefixed with prefix, to files, by the variable naming
+// them: those named by the companion variables of fields whose own variable
+// is unset or empty.
+func envFiles(t reflect.Type, prefix string, env environment, files map[string]string, seen map[reflect.Type]bool) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	defer delete(seen, t)
+	fields := fieldsOf(t)
+	for i := range fields {
+		f := &fields[i]
+		if f.nested {
+			envFiles(structType(f.Type), prefix, env, files, seen)
+			continue
+		}
+		key, fileKey := envKeys(f, prefix)
+		if fileKey == "" || key != "" && env.getenv(key) != "" {
+			continue
+		}
+		if filename := env.getenv(fileKey); filename != "" {
+			files[fileKey] = filename
+		}
+	}
+}
+
+// envSets reports whether env gives the field f a value, as parseFieldEnv
+// reads it, each name prefixed with prefix: its variable or file is set, or it
+// has an `envDefault` tag.
+func envSets(f *fieldInfo, prefix string, env environment) bool {
+	if f.env == "" && f.envFile == "" {
+		return false
+	}
+	if f.envDefault != "" {
+		return true
+	}
+	key, fileKey := envKeys(f, prefix)
+	value, _, err := env.lookupEnv(key, fileKey)
+	return err != nil || value != ""
+}
+
+// LookupEnv returns the value of the environment variable name or, if it is
+// unset or empty, the contents of the file named by the variable fileName,
+// without a trailing newline, along with the variable it came from. Either
+// name may be empty. It is how `env` and `envFile` tags are read, and is
+// called by the code goconfig-gen generates.
+func LookupEnv(name, fileName string) (value, from string, err error) {
+	return environment(nil).lookupEnv(name, fileName)
+}
+
+// environment holds the variables of the dotenv files a Load reads, which the
+// variables of the process take precedence over. They are kept here rather
+// than set in the process, so that loading a config, or validating one, leaves
+// the environment of the process alone. A nil environment is that of the
+// process alone.
+type environment map[string]string
+
+// lookup returns the value of the variable name and whether it is set.
+func (e environment) lookup(name string) (string, bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	value, ok := e[name]
+	return value, ok
+}
+
+// getenv returns the value of the variable name, empty if it isn't set.
+func (e environment) getenv(name string) string {
+	value, _ := e.lookup(name)
+	return value
+}
+
+// environ returns the variables of e as "key=value" strings, as os.Environ
+// does.
+func (e environment) environ() []string {
+	vars := os.Environ()
+	for key, value := range e {
+		if _, ok := os.LookupEnv(key); !ok {
+			vars = append(vars, key+"="+value)
+		}
+	}
+	return vars
+}
+
+// lookupEnv is LookupEnv with the variables of e.
+func (e environment) lookupEnv(name, fileName string) (value, from string, err error) {
+	if name != "" {
+		value = e.getenv(name)
+	}
+	if value == "" && fileName != "" {
+		if filename := e.getenv(fileName); filename != "" {
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return "", fileName, fmt.Errorf("%s: %s", fileName, err)
+			}
+			return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), fileName, nil
+		}
+	}
+	return value, name, nil
+}
+
+// unknownEnv returns the environment variables starting with prefix that no
+// field of the structs pointed to by vals reads, sorted, so that typos such as
+// MYAPP_TIMEOUTT don't go unnoticed.
+func unknownEnv(prefix string, env environment, vals ...interface{}) []string {
+	known := map[string]bool{}
+	var prefixes []string
+	for _, val := range vals {
+		envNames(reflect.TypeOf(val).Elem(), prefix, known, &prefixes)
+	}
+	var unknown []string
+	for _, kv := range env.environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, prefix) || known[name] || hasAnyPrefix(name, prefixes) {
+			continue
+		}
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// envNames adds the environment variables read by the fields of t, a struct
+// type, to known, and the prefixes of its Flags fields to prefixes, as
+// parseStructEnv reads them.
+func envNames(t reflect.Type, prefix string, known map[string]bool, prefixes *[]string) {
+	fields := fieldsOf(t)
+	for i := range fields {
+		f := &fields[i]
+		if f.nested {
+			envNames(structType(f.Type), prefix, known, prefixes)
+			continue
+		}
+		if f.Type == flagsType {
+			if f.env != "" {
+				*prefixes = append(*prefixes, prefix+f.env)
+			}
+			continue
+		}
+		if f.env != "" {
+			known[prefix+f.env] = true
+			known[prefix+f.env+"_FILE"] = true
+		}
+		if f.envFile != "" {
+			known[prefix+f.envFile] = true
+		}
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
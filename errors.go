@@ -0,0 +1,70 @@
+package goconfig
+
+import "errors"
+
+// The categories of load errors, for errors.Is. A failed Load reports every
+// problem it found at once, joined with errors.Join, so an error may be in
+// several categories.
+var (
+	// ErrFile matches errors decoding a config file into the config, such as
+	// values of the wrong type or unknown keys in strict mode.
+	ErrFile = errors.New("config file error")
+	// ErrEnv matches errors parsing environment variables.
+	ErrEnv = errors.New("environment variable error")
+	// ErrFlags matches errors parsing command-line flags.
+	ErrFlags = errors.New("flag error")
+	// ErrOverrides matches errors applying overrides.
+	ErrOverrides = errors.New("override error")
+	// ErrSecrets matches errors resolving secrets from secret stores.
+	ErrSecrets = errors.New("secret error")
+	// ErrMissingFields matches MissingRequiredStructFields.
+	ErrMissingFields = errors.New("missing required fields")
+	// ErrInvalidFields matches InvalidStructFields.
+	ErrInvalidFields = errors.New("invalid fields")
+)
+
+// sourceErrors holds the category of the errors of each source.
+var sourceErrors = map[Source]error{
+	SourceFile:      ErrFile,
+	SourceEnv:       ErrEnv,
+	SourceFlags:     ErrFlags,
+	SourceOverrides: ErrOverrides,
+	SourceSecrets:   ErrSecrets,
+}
+
+// SourceError is an error applying the values of a source to the config. It
+// reads as the underlying error, and matches the category of its source with
+// errors.Is, e.g. ErrEnv for SourceEnv.
+type SourceError struct {
+	Source Source
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SourceError) Is(target error) bool {
+	return target == sourceErrors[e.Source]
+}
+
+func (e MissingRequiredStructFields) Is(target error) bool {
+	return target == ErrMissingFields
+}
+
+func (e InvalidStructFields) Is(target error) bool {
+	return target == ErrInvalidFields
+}
+
+// joinErrors returns the only error of errs, or all of them joined, or nil if
+// there are none.
+func joinErrors(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,189 @@
+package goconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// etcd is reached through the JSON gateway of its v3 API, which every etcd
+// server since 3.4 serves alongside gRPC, so no client library is needed.
+// Config URLs look like etcd://host:2379/myapp/config.yaml, or
+// etcd+https://host:2379/... for TLS, and name the key holding the document.
+
+var (
+	// etcdRevisionsMu guards etcdRevisions.
+	etcdRevisionsMu sync.Mutex
+	// etcdRevisions holds the revision each etcd URL was last fetched at,
+	// for watches to start from.
+	etcdRevisions = map[string]int64{}
+)
+
+// etcdEndpoint returns the base URL of the etcd server u refers to.
+func etcdEndpoint(u *url.URL) string {
+	scheme := "http"
+	if strings.HasSuffix(u.Scheme, "+https") {
+		scheme = "https"
+	}
+	return scheme + "://" + u.Host
+}
+
+// etcdKey returns the etcd key u refers to, base64 encoded for the gateway.
+func etcdKey(u *url.URL) string {
+	return base64.StdEncoding.EncodeToString([]byte(u.Path))
+}
+
+// etcdHeader is the response header of every etcd call.
+type etcdHeader struct {
+	Revision string `json:"revision"`
+}
+
+func (h etcdHeader) revision() int64 {
+	rev, _ := strconv.ParseInt(h.Revision, 10, 64)
+	return rev
+}
+
+// fetchEtcd fetches the value of the etcd key u refers to.
+func fetchEtcd(ctx context.Context, u *url.URL, o *options) ([]byte, error) {
+	var resp struct {
+		Header etcdHeader `json:"header"`
+		Kvs    []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	body := map[string]interface{}{"key": etcdKey(u)}
+	if err := etcdCall(ctx, o.httpClient, u, "/v3/kv/range", body, &resp); err != nil {
+		return nil, err
+	}
+	etcdRevisionsMu.Lock()
+	etcdRevisions[u.String()] = resp.Header.revision()
+	etcdRevisionsMu.Unlock()
+	if len(resp.Kvs) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	return base64.StdEncoding.DecodeString(resp.Kvs[0].Value)
+}
+
+// watchEtcd calls changed whenever the etcd key u refers to is put or deleted
+// after the revision it was last fetched at, until ctx is done or the watch
+// stream breaks.
+func watchEtcd(ctx context.Context, u *url.URL, o *options, changed func()) error {
+	create := map[string]interface{}{"key": etcdKey(u)}
+	etcdRevisionsMu.Lock()
+	if rev, ok := etcdRevisions[u.String()]; ok {
+		create["start_revision"] = strconv.FormatInt(rev+1, 10)
+	}
+	etcdRevisionsMu.Unlock()
+	resp, err := etcdPost(ctx, streamingClient(o.httpClient), u, "/v3/watch", map[string]interface{}{"create_request": create})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	d := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Result struct {
+				Canceled bool              `json:"canceled"`
+				Reason   string            `json:"cancel_reason"`
+				Events   []json.RawMessage `json:"events"`
+			} `json:"result"`
+			Error *etcdError `json:"error"`
+		}
+		if err := d.Decode(&msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if msg.Result.Canceled {
+			// The revision may have been compacted away, so start
+			// over from a fresh load.
+			etcdRevisionsMu.Lock()
+			delete(etcdRevisions, u.String())
+			etcdRevisionsMu.Unlock()
+			changed()
+			return fmt.Errorf("etcd watch canceled: %s", msg.Result.Reason)
+		}
+		if len(msg.Result.Events) > 0 {
+			changed()
+		}
+	}
+}
+
+// etcdError is an error returned by the etcd gateway.
+type etcdError struct {
+	Message string `json:"message"`
+}
+
+func (e *etcdError) Error() string {
+	return "etcd: " + e.Message
+}
+
+// etcdCall posts body as JSON to the etcd API path and decodes the response
+// into out.
+func etcdCall(ctx context.Context, client *http.Client, u *url.URL, path string, body, out interface{}) error {
+	resp, err := etcdPost(ctx, client, u, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// etcdPost posts body as JSON to the etcd API path, first authenticating with
+// the user and password of u, if it has any.
+func etcdPost(ctx context.Context, client *http.Client, u *url.URL, path string, body interface{}) (*http.Response, error) {
+	token := ""
+	if u.User != nil {
+		password, _ := u.User.Password()
+		var auth struct {
+			Token string `json:"token"`
+		}
+		creds := map[string]string{"name": u.User.Username(), "password": password}
+		if err := etcdCall(ctx, client, &url.URL{Scheme: u.Scheme, Host: u.Host}, "/v3/auth/authenticate", creds, &auth); err != nil {
+			return nil, err
+		}
+		token = auth.Token
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, etcdEndpoint(u)+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var e etcdError
+		if data, _ := io.ReadAll(resp.Body); json.Unmarshal(data, &e) == nil && e.Message != "" {
+			return nil, &e
+		}
+		return nil, errors.New("etcd: unexpected status " + resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,103 @@
+package goconfig
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Example writes a sample YAML config for c to w, for keeping files such as
+// config.example.yaml in sync with the struct. c may be a config or any
+// pointer to a struct. Its current values are used, with `default` tags
+// applied, and each key is commented with the description from the field's
+// `desc` tag, whether it is required, its environment variable and default.
+// Unset sections held in pointers and slices get a sample element, and
+// secrets are masked.
+func Example(c interface{}, w io.Writer) error {
+	if cfg, ok := c.(Configterface); ok {
+		if err := checkPointer(cfg); err != nil {
+			return err
+		}
+		cfg.RLock()
+		defer cfg.RUnlock()
+		c = configTarget(cfg)
+	}
+	value := reflect.ValueOf(c)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return ErrNotPointer
+	}
+	sample := deepCopy(value).Interface()
+	if err := applyDefaults(sample); err != nil {
+		return err
+	}
+	node, err := nodeEncoder{redact: true, example: true}.node(reflect.ValueOf(sample), false)
+	if err != nil {
+		return err
+	}
+	data, err := encodeYAML(node)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, bytes.NewReader(data))
+	return err
+}
+
+// exampleValue replaces v, if it is a nil pointer to a struct or an empty
+// slice of structs, with a sample holding a struct with its defaults applied.
+func exampleValue(v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.Ptr && v.IsNil() && isSection(t.Elem()):
+		sample := reflect.New(t.Elem())
+		applyStructDefaults(sample.Elem(), fieldPath{})
+		return sample
+	case t.Kind() == reflect.Slice && v.Len() == 0 && isSection(reflect.Indirect(reflect.New(t.Elem())).Type()):
+		elem := reflect.New(t.Elem()).Elem()
+		if t.Elem().Kind() == reflect.Ptr {
+			elem = exampleValue(elem)
+		} else {
+			applyStructDefaults(elem, fieldPath{})
+		}
+		return reflect.Append(reflect.MakeSlice(t, 0, 1), elem)
+	}
+	return v
+}
+
+// isSection reports whether t is a struct type decoded field by field.
+func isSection(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	_, codec := textCodecs[t]
+	return t.Kind() == reflect.Struct && t != timeType && !codec && !marshalsItself(reflect.PtrTo(t))
+}
+
+// fieldComment describes the struct field sf for a sample config.
+func fieldComment(sf reflect.StructField) string {
+	var notes []string
+	if sf.Tag.Get("required") == "true" {
+		notes = append(notes, "required")
+	} else if cond := sf.Tag.Get("required_if"); cond != "" {
+		notes = append(notes, "required if "+cond)
+	} else if with := sf.Tag.Get("required_with"); with != "" {
+		notes = append(notes, "required with "+with)
+	}
+	if env, _, _ := strings.Cut(sf.Tag.Get("env"), ","); env != "" {
+		notes = append(notes, "env: "+env)
+	}
+	if def, ok := sf.Tag.Lookup("default"); ok {
+		notes = append(notes, "default: "+def)
+	}
+	comment := sf.Tag.Get("desc")
+	if len(notes) > 0 {
+		if comment != "" {
+			comment += " "
+		}
+		comment += "(" + strings.Join(notes, ", ") + ")"
+	}
+	return comment
+}
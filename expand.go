@@ -0,0 +1,109 @@
+package goconfig
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// expandEnvTree expands references to the variables of env in every string
+// value of tree, in place. References keep says to keep are left as they are,
+// as is $$, for interpolate to resolve.
+func expandEnvTree(tree map[string]interface{}, env environment, keep func(ref string) bool) {
+	for k, v := range tree {
+		tree[k] = expandEnvValue(v, env, keep)
+	}
+}
+
+func expandEnvValue(v interface{}, env environment, keep func(ref string) bool) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		expandEnvTree(t, env, keep)
+	case []interface{}:
+		for i, item := range t {
+			t[i] = expandEnvValue(item, env, keep)
+		}
+	case string:
+		expanded := expandEnv(t, env.lookup, keep)
+		if expanded != t {
+			return resolveScalar(expanded)
+		}
+	}
+	return v
+}
+
+// expandEnv replaces ${VAR} references in s with the value of VAR. Defaults
+// can be given as ${VAR:-default}, used if VAR is unset or empty, or as
+// ${VAR-default}, used only if VAR is unset. $$ stands for a literal $. If keep
+// is set, the references it reports true for are left as they are, as is $$.
+func expandEnv(s string, lookup func(string) (string, bool), keep func(ref string) bool) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			if keep != nil {
+				b.WriteByte('$')
+			}
+			b.WriteByte('$')
+			i++
+			continue
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				break
+			}
+			if ref := s[i+2 : i+end]; keep != nil && keep(ref) {
+				b.WriteString(s[i : i+end+1])
+			} else {
+				b.WriteString(expandRef(ref, lookup))
+			}
+			i += end
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// expandRef resolves the contents of a single ${...} reference.
+func expandRef(ref string, lookup func(string) (string, bool)) string {
+	if i := strings.Index(ref, ":-"); i >= 0 {
+		if value, ok := lookup(ref[:i]); ok && value != "" {
+			return value
+		}
+		return ref[i+2:]
+	}
+	if i := strings.IndexByte(ref, '-'); i >= 0 {
+		if value, ok := lookup(ref[:i]); ok {
+			return value
+		}
+		return ref[i+1:]
+	}
+	value, _ := lookup(ref)
+	return value
+}
+
+// resolveScalar gives an expanded string the type it would have had if it had
+// been written in the YAML directly, so that e.g. "${PORT}" can fill an int.
+// Anything but a plain number or boolean stays a string.
+func resolveScalar(s string) interface{} {
+	if s == "" || strings.ContainsAny(s, "\n:#[]{},&*!|>'\"%@`") {
+		return s
+	}
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	switch v.(type) {
+	case int, int64, uint64, float64, bool:
+		return v
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package goconfig
+
+import "testing"
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{"HOST": "example.com", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"${HOST}", "example.com"},
+		{"https://${HOST}:443", "https://example.com:443"},
+		{"${UNSET}", ""},
+		{"${HOST:-localhost}", "example.com"},
+		{"${UNSET:-localhost}", "localhost"},
+		{"${EMPTY:-localhost}", "localhost"},
+		{"${EMPTY-localhost}", ""},
+		{"${UNSET-localhost}", "localhost"},
+		{"${UNSET:-}", ""},
+		{"$$5", "$5"},
+		{"$HOST", "$HOST"},
+		{"${HOST", "${HOST"},
+		{"cost: $", "cost: $"},
+	}
+	for _, test := range tests {
+		if got := expandEnv(test.in, lookup, nil); got != test.want {
+			t.Errorf("expandEnv(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestLoadExpandEnv(t *testing.T) {
+	t.Setenv("EXPANDTEST_HOST", "example.com")
+	t.Setenv("EXPANDTEST_PORT", "")
+	var c struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+		URL  string `yaml:"url"`
+	}
+	yaml := "host: ${EXPANDTEST_HOST}\nport: ${EXPANDTEST_PORT:-8080}\nurl: http://${EXPANDTEST_UNSET:-localhost}\n"
+	filename := writeConfigFile(t, "config.yaml", yaml)
+	if err := Load(&c, WithFile(filename), ExpandEnv()); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "example.com" || c.Port != 8080 || c.URL != "http://localhost" {
+		t.Errorf("config = %q, %d, %q", c.Host, c.Port, c.URL)
+	}
+
+	// Without ExpandEnv, references are kept as they are.
+	filename = writeConfigFile(t, "config.yaml", "host: ${EXPANDTEST_HOST}\n")
+	if err := Load(&c, WithFile(filename)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "${EXPANDTEST_HOST}" {
+		t.Errorf("host = %q", c.Host)
+	}
+}
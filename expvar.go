@@ -0,0 +1,97 @@
+package goconfig
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WithExpvar publishes metadata about the config under name with the expvar
+// package, so that it shows up on /debug/vars: the files it was loaded from,
+// when it last loaded, a checksum, how many times it has been reloaded and its
+// keys, without their values. Pass it to every load of the config.
+func WithExpvar(name string) Option {
+	return func(o *options) {
+		o.expvarName = name
+	}
+}
+
+// expvarInfo is the metadata published by WithExpvar.
+type expvarInfo struct {
+	Files    []string  `json:"files"`
+	LoadedAt time.Time `json:"loaded_at"`
+	Checksum string    `json:"checksum"`
+	Reloads  int       `json:"reloads"`
+	Keys     []string  `json:"keys"`
+}
+
+var (
+	// expvarsMu guards expvars.
+	expvarsMu sync.Mutex
+	// expvars holds the metadata published with WithExpvar, by name.
+	expvars = map[string]*expvarInfo{}
+)
+
+// publishExpvar records a successful load of c, with options o, under name.
+func publishExpvar(name string, c Configterface, o *options) {
+	files := o.files
+	if len(files) == 0 && c.GetFilename() != "" {
+		files = []string{c.GetFilename()}
+	}
+	target := configTarget(c)
+	info := &expvarInfo{
+		Files:    files,
+		LoadedAt: time.Now(),
+		Checksum: configHash(target),
+		Keys:     configKeys(target),
+	}
+	expvarsMu.Lock()
+	defer expvarsMu.Unlock()
+	previous, ok := expvars[name]
+	if !ok {
+		if expvar.Get(name) != nil {
+			o.log().Warn("config not published with expvar, the name is taken", "name", name)
+			return
+		}
+		expvar.Publish(name, expvar.Func(func() interface{} {
+			expvarsMu.Lock()
+			defer expvarsMu.Unlock()
+			return *expvars[name]
+		}))
+	} else {
+		info.Reloads = previous.Reloads
+		if o.reloading {
+			info.Reloads++
+		}
+	}
+	expvars[name] = info
+}
+
+// configKeys returns the dotted yaml keys of the values set in c, sorted.
+func configKeys(c interface{}) []string {
+	data, err := marshalConfig(c, FormatYAML, true)
+	if err != nil {
+		return nil
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil
+	}
+	var keys []string
+	var walk func(tree map[string]interface{}, path string)
+	walk = func(tree map[string]interface{}, path string) {
+		for k, v := range tree {
+			if child, ok := v.(map[string]interface{}); ok && len(child) > 0 {
+				walk(child, joinPath(path, k))
+				continue
+			}
+			keys = append(keys, joinPath(path, k))
+		}
+	}
+	walk(tree, "")
+	sort.Strings(keys)
+	return keys
+}
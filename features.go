@@ -0,0 +1,200 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flags holds feature flags by name, each either a boolean or a variant such
+// as "control" or "v2", as in
+//
+//	type Config struct {
+//		goconfig.Config `yaml:",inline"`
+//		Features        goconfig.Flags `yaml:"features" env:"FEATURE_"`
+//	}
+//
+// read from
+//
+//	features:
+//	  new_checkout: true
+//	  search: v2
+//
+// The `env` tag of a Flags field is a prefix: FEATURE_NEW_CHECKOUT=false
+// overrides the new_checkout flag, or adds it. A `default` tag lists the flags
+// used when no config file sets any, as in `default:"new_checkout,search:v1"`,
+// where a flag without a value is true. Flags must not be modified once
+// loaded.
+type Flags map[string]string
+
+var flagsType = reflect.TypeOf(Flags(nil))
+
+// Enabled reports whether the flag called name is set to true, or to a
+// variant other than "off".
+func (f Flags) Enabled(name string) bool {
+	value, ok := f[name]
+	if !ok {
+		return false
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	return value != "" && value != "off"
+}
+
+// Bool returns the flag called name as a boolean, or def if it is unset or
+// not a boolean.
+func (f Flags) Bool(name string, def bool) bool {
+	if b, err := strconv.ParseBool(f[name]); err == nil {
+		return b
+	}
+	return def
+}
+
+// Variant returns the value of the flag called name, or def if it is unset.
+func (f Flags) Variant(name, def string) string {
+	if value, ok := f[name]; ok {
+		return value
+	}
+	return def
+}
+
+// Names returns the names of the flags, sorted, to list or audit them.
+func (f Flags) Names() []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeFlags is the decode hook of Flags. Config files give a map of
+// booleans and strings, and `default` tags and environment variables a
+// comma separated list of flags with optional values.
+func decodeFlags(value interface{}) (interface{}, error) {
+	flags := Flags{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, raw := range v {
+			switch raw := raw.(type) {
+			case bool:
+				flags[name] = strconv.FormatBool(raw)
+			case string:
+				flags[name] = raw
+			case int, int64, uint64, float64:
+				flags[name] = fmt.Sprint(raw)
+			default:
+				return nil, fmt.Errorf("feature flag %s: expected a bool or a string, got %v", name, raw)
+			}
+		}
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			name, variant, ok := strings.Cut(strings.TrimSpace(item), ":")
+			if name == "" {
+				continue
+			}
+			if !ok {
+				variant = "true"
+			}
+			flags[name] = variant
+		}
+	default:
+		return nil, fmt.Errorf("expected a map of feature flags, got %v", value)
+	}
+	return flags, nil
+}
+
+// parseFlagsEnv overrides the flags in field, a Flags field, with the
+// variables of env starting with the prefix in its `env` tag.
+func parseFlagsEnv(field reflect.Value, f *fieldInfo, prefix string, env environment) {
+	if f.env == "" {
+		return
+	}
+	key := prefix + f.env
+	flags := field.Interface().(Flags)
+	for _, kv := range env.environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, key) || name == key {
+			continue
+		}
+		if flags == nil {
+			flags = Flags{}
+		}
+		flags[strings.ToLower(strings.TrimPrefix(name, key))] = value
+	}
+	field.Set(reflect.ValueOf(flags))
+}
+
+// FlagChange is a change to a feature flag made by a reload.
+type FlagChange struct {
+	// Name is the dotted key of the flag, e.g. "features.new_checkout".
+	Name string
+	// Old and New are its values before and after the reload, empty if it
+	// wasn't set.
+	Old, New string
+}
+
+// OnFlagChange registers fn to be called after each successful reload by
+// ListenForSignals, Watch or Reload with every change to the Flags of the
+// config, sorted by name.
+func OnFlagChange(fn func([]FlagChange)) Option {
+	return OnReload(func(old, new interface{}) {
+		c := configOf(new)
+		c.RLock()
+		changes := flagChanges(reflect.ValueOf(configTarget(old)), reflect.ValueOf(configTarget(new)), "")
+		c.RUnlock()
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].Name < changes[j].Name
+		})
+		if len(changes) > 0 {
+			fn(changes)
+		}
+	})
+}
+
+// flagChanges returns the changes between the Flags in old and new, which
+// hold values of the same type, found at the key path.
+func flagChanges(old, new reflect.Value, path string) []FlagChange {
+	old, _ = indirectValue(old)
+	new, _ = indirectValue(new)
+	if !old.IsValid() || !new.IsValid() {
+		return nil
+	}
+	if new.Type() == flagsType {
+		before, after := old.Interface().(Flags), new.Interface().(Flags)
+		var changes []FlagChange
+		for _, name := range mergeNames(before, after) {
+			if before[name] != after[name] {
+				changes = append(changes, FlagChange{Name: joinPath(path, name), Old: before[name], New: after[name]})
+			}
+		}
+		return changes
+	}
+	if new.Kind() != reflect.Struct || parsesItself(new.Type()) {
+		return nil
+	}
+	var changes []FlagChange
+	for i := 0; i < new.NumField(); i++ {
+		sf := new.Type().Field(i)
+		if !exported(sf) {
+			continue
+		}
+		changes = append(changes, flagChanges(old.Field(i), new.Field(i), joinPath(path, yamlKey(sf)))...)
+	}
+	return changes
+}
+
+// mergeNames returns the names of the flags in a or b, sorted.
+func mergeNames(a, b Flags) []string {
+	merged := Flags{}
+	for name := range a {
+		merged[name] = ""
+	}
+	for name := range b {
+		merged[name] = ""
+	}
+	return merged.Names()
+}
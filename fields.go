@@ -0,0 +1,112 @@
+package goconfig
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldInfo is an exported struct field along with its parsed tags, as loads
+// read them.
+type fieldInfo struct {
+	reflect.StructField
+	// key is the yaml key of the field, as yamlKey returns it, and skipped is
+	// set if its yaml tag is "-".
+	key     string
+	skipped bool
+	// nested is set if the field holds a struct, or a pointer to one, whose
+	// fields are loaded one by one rather than as a single value.
+	nested bool
+
+	// env and envFile are the variables in the `env` and `envFile` tags,
+	// without a prefix, and envOptions the options after the name in the
+	// `env` tag.
+	env        string
+	envOptions []string
+	envFile    string
+	envDefault string
+	// envFormat is how values from the environment are parsed.
+	envFormat stringFormat
+
+	def        string
+	hasDefault bool
+	layout     string
+	// base and hasBase are those of the `base` tag, see stringFormat.
+	base    int
+	hasBase bool
+
+	required bool
+	// requiredIf holds the conditions of the `required_if` tag, if it has
+	// one, and requiredWith the siblings of the `required_with` tag.
+	requiredIf    []string
+	hasRequiredIf bool
+	requiredWith  []string
+	// rules are those of the `validate` tag.
+	rules []rule
+}
+
+// typeFields holds the fields of the struct types seen so far, by type.
+var typeFields sync.Map
+
+// fieldsOf returns the exported fields of the struct type t, with their tags
+// parsed once, the first time t is seen, rather than on every load.
+func fieldsOf(t reflect.Type) []fieldInfo {
+	if fields, ok := typeFields.Load(t); ok {
+		return fields.([]fieldInfo)
+	}
+	fields := make([]fieldInfo, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); exported(sf) {
+			fields = append(fields, newFieldInfo(sf))
+		}
+	}
+	cached, _ := typeFields.LoadOrStore(t, fields)
+	return cached.([]fieldInfo)
+}
+
+// forgetTypes drops what was worked out about the types seen so far, which
+// depends on the decode hooks registered, once another one is.
+func forgetTypes() {
+	for _, cache := range []*sync.Map{&typeFields, &typeMergeRules} {
+		cache.Range(func(t, _ interface{}) bool {
+			cache.Delete(t)
+			return true
+		})
+	}
+}
+
+// newFieldInfo parses the tags of sf.
+func newFieldInfo(sf reflect.StructField) fieldInfo {
+	f := fieldInfo{
+		StructField: sf,
+		key:         yamlKey(sf),
+		skipped:     sf.Tag.Get("yaml") == "-",
+		envFile:     sf.Tag.Get("envFile"),
+		envDefault:  sf.Tag.Get("envDefault"),
+		layout:      sf.Tag.Get("layout"),
+		required:    sf.Tag.Get("required") == "true",
+	}
+	if t := structType(sf.Type); t.Kind() == reflect.Struct && !parsesItself(t) {
+		f.nested = true
+	}
+	env, opts, _ := strings.Cut(sf.Tag.Get("env"), ",")
+	f.env = env
+	if opts != "" {
+		f.envOptions = strings.Split(opts, ",")
+	}
+	f.base, f.hasBase = tagBase(sf.Tag)
+	f.envFormat = stringFormat{
+		separator:         sf.Tag.Get("envSeparator"),
+		keyValueSeparator: sf.Tag.Get("envKeyValSeparator"),
+		layout:            f.layout,
+		base:              f.base,
+		hasBase:           f.hasBase,
+	}
+	f.def, f.hasDefault = sf.Tag.Lookup("default")
+	var conds string
+	conds, f.hasRequiredIf = sf.Tag.Lookup("required_if")
+	f.requiredIf = strings.Fields(conds)
+	f.requiredWith = strings.Fields(sf.Tag.Get("required_with"))
+	f.rules = parseRules(sf.Tag.Get("validate"))
+	return f
+}
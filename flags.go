@@ -0,0 +1,206 @@
+package goconfig
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// flagSource looks up the values of the command-line flags that were set.
+type flagSource interface {
+	// flagName returns the name of the flag for the field sf, whose path is
+	// path, or "" if it has none.
+	flagName(sf reflect.StructField, path fieldPath) string
+	// lookup returns the value of the named flag, and whether it was set.
+	lookup(name string) (string, bool)
+}
+
+// stdFlags is a flagSource for a flag.FlagSet.
+type stdFlags struct {
+	fs *flag.FlagSet
+}
+
+func (stdFlags) flagName(sf reflect.StructField, path fieldPath) string {
+	return sf.Tag.Get("flag")
+}
+
+func (f stdFlags) lookup(name string) (value string, set bool) {
+	f.fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			value, set = fl.Value.String(), true
+		}
+	})
+	return value, set
+}
+
+// flagValue is the flag.Value RegisterFlags defines. It only holds the string
+// given on the command line, which Load parses into the field.
+type flagValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.value
+}
+
+func (v *flagValue) Set(s string) error {
+	v.value = s
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// RegisterFlags defines a flag on fs for every field of c, nested or not,
+// tagged `flag:"name"`, with the field's `desc` tag as its usage and its
+// `default` tag as its default. Pass WithFlags(fs) to Load once fs has been
+// parsed to apply the flags.
+func RegisterFlags(fs *flag.FlagSet, c interface{}) error {
+	return registerFlags(c, func(name, usage, def string, isBool bool) {
+		fs.Var(&flagValue{value: def, isBool: isBool}, name, usage)
+	})
+}
+
+// registerFlags calls define for every field of c tagged with a flag name.
+func registerFlags(c interface{}, define func(name, usage, def string, isBool bool)) error {
+	if cfg, ok := c.(Configterface); ok {
+		c = configTarget(cfg)
+	}
+	t := reflect.TypeOf(c)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ErrNotPointer
+	}
+	registerStructFlags(t, define, map[reflect.Type]bool{})
+	return nil
+}
+
+// registerStructFlags calls define for the fields of the struct type t and
+// of the structs it holds or points to, skipping the types in seen, which
+// have been registered already, so that recursive types end.
+func registerStructFlags(t reflect.Type, define func(name, usage, def string, isBool bool), seen map[reflect.Type]bool) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !exported(sf) {
+			continue
+		}
+		if name := sf.Tag.Get("flag"); name != "" {
+			define(name, sf.Tag.Get("desc"), sf.Tag.Get("default"), sf.Type.Kind() == reflect.Bool)
+			continue
+		}
+		if sf.Type.Kind() == reflect.Struct && !parsesItself(sf.Type) {
+			registerStructFlags(sf.Type, define, seen)
+		} else if isPointerSection(sf.Type) {
+			registerStructFlags(sf.Type.Elem(), define, seen)
+		}
+	}
+}
+
+// WithFlags applies the flags set on fs, which must have been parsed, to the
+// fields tagged with their names, e.g. `flag:"port"`. Flags take precedence
+// over environment variables unless told otherwise with WithPrecedence, and
+// flags that weren't set on the command line are ignored.
+func WithFlags(fs *flag.FlagSet) Option {
+	return func(o *options) {
+		o.flags = append(o.flags, stdFlags{fs})
+	}
+}
+
+// parseFlags sets the fields of the struct pointed to by val that have `flag`
+// tags from the flags set in sources. Errors for every field are collected.
+func parseFlags(val interface{}, sources []flagSource) error {
+	value := reflect.ValueOf(val)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return errors.New("Expected a pointer to a Struct")
+	}
+	p := &flagParser{sources: sources, parsing: map[reflect.Type]bool{}}
+	p.parseStruct(value.Elem(), fieldPath{})
+	if p.errs != nil {
+		return errors.New(strings.Join(p.errs, ". "))
+	}
+	return nil
+}
+
+type flagParser struct {
+	sources []flagSource
+	// parsing holds the struct types being parsed, so that nil pointers to
+	// them aren't allocated over and over in recursive types.
+	parsing map[reflect.Type]bool
+	errs    []string
+}
+
+// parseStruct sets the fields of the struct value from the flags, and
+// reports whether any were set.
+func (p *flagParser) parseStruct(value reflect.Value, path fieldPath) bool {
+	if t := value.Type(); !p.parsing[t] {
+		p.parsing[t] = true
+		defer delete(p.parsing, t)
+	}
+	set := false
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		field := value.Field(i)
+		if !exported(structField) {
+			continue
+		}
+		name := path.child(structField)
+		if structField.Tag.Get("flag") == "" {
+			if inner, ok := structValue(field); ok && !parsesItself(inner.Type()) {
+				set = p.parseStruct(inner, name) || set
+				continue
+			}
+			if isPointerSection(field.Type()) {
+				set = p.parseSection(field, name) || set
+				continue
+			}
+		}
+		for _, source := range p.sources {
+			flagName := source.flagName(structField, name)
+			if flagName == "" {
+				continue
+			}
+			s, ok := source.lookup(flagName)
+			if !ok {
+				continue
+			}
+			format := stringFormat{layout: structField.Tag.Get("layout")}
+			format.base, format.hasBase = tagBase(structField.Tag)
+			if err := setFromStringWith(field, s, format); err != nil {
+				p.errs = append(p.errs, fmt.Sprintf("flag -%s: %s", flagName, err))
+			}
+			set = true
+		}
+	}
+	return set
+}
+
+// parseSection sets field, a nil pointer to a struct, to a new struct holding
+// its defaults if the flags set any of its fields, as a config file setting
+// one of its keys would.
+func (p *flagParser) parseSection(field reflect.Value, path fieldPath) bool {
+	if p.parsing[field.Type().Elem()] {
+		return false
+	}
+	section, err := newSection(field.Type(), path)
+	if !p.parseStruct(section.Elem(), path) {
+		return false
+	}
+	if err != nil {
+		p.errs = append(p.errs, err.Error())
+	}
+	field.Set(section)
+	return true
+}
@@ -0,0 +1,48 @@
+package goconfig
+
+import (
+	"flag"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFlagsPointerSections(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := RegisterFlags(fs, &pointerSectionConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	sort.Strings(names)
+	if got := strings.Join(names, " "); got != "cache-size db-password" {
+		t.Fatalf("flags = %s", got)
+	}
+	if err := fs.Parse([]string{"-cache-size", "64"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := pointerSectionConfig{DB: &dbSection{}}
+	if err := parseFlags(&c, []flagSource{stdFlags{fs}}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Cache == nil || c.Cache.Size != 64 {
+		t.Fatalf("cache = %+v", c.Cache)
+	}
+	if c.DB.Password != "" {
+		t.Errorf("db.password = %q", c.DB.Password)
+	}
+	if c.Next != nil {
+		t.Error("section without flags set allocated")
+	}
+
+	if err := fs.Parse([]string{"-db-password", "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := parseFlags(&c, []flagSource{stdFlags{fs}}); err != nil {
+		t.Fatal(err)
+	}
+	if c.DB.Password != "hunter2" {
+		t.Errorf("db.password = %q", c.DB.Password)
+	}
+}
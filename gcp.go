@@ -0,0 +1,331 @@
+package goconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GCP configures how goconfig talks to Google Cloud, for fields tagged
+// `gcpsecret`. Requests are authenticated with Application Default
+// Credentials unless told otherwise, without linking in the Google client
+// libraries.
+type GCP struct {
+	// Token returns an OAuth2 access token to authenticate requests with.
+	// If nil, Application Default Credentials are used: the credentials
+	// file named by GOOGLE_APPLICATION_CREDENTIALS, or else the one written
+	// by `gcloud auth application-default login`, or else the metadata
+	// server of GCE, GKE, Cloud Run and the like.
+	Token func(ctx context.Context) (string, error)
+	// Client makes the requests, a client with a 30 second timeout if nil.
+	Client *http.Client
+}
+
+// WithGCP configures how goconfig talks to Google Cloud, rather than with
+// Application Default Credentials.
+func WithGCP(g GCP) Option {
+	return func(o *options) {
+		o.gcp = &g
+	}
+}
+
+// gcpScope is the OAuth2 scope requested for Application Default Credentials.
+const gcpScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// gcpSettings returns the GCP settings of o with their empty fields filled in.
+func gcpSettings(o *options) GCP {
+	g := GCP{}
+	if o.gcp != nil {
+		g = *o.gcp
+	}
+	if g.Token == nil {
+		g.Token = defaultGCPToken
+	}
+	if g.Client == nil {
+		g.Client = defaultHTTPClient
+	}
+	return g
+}
+
+// get makes an authenticated GET request to a Google API. A 404 gives an
+// error matching fs.ErrNotExist.
+func (g GCP) get(ctx context.Context, endpoint string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	return g.do(req)
+}
+
+// post is get for a POST request with a JSON body.
+func (g GCP) post(ctx context.Context, endpoint string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return g.do(req)
+}
+
+func (g GCP) do(req *http.Request) (*http.Response, error) {
+	token, err := g.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var e struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	msg := resp.Status
+	if data, _ := io.ReadAll(resp.Body); json.Unmarshal(data, &e) == nil && e.Error.Message != "" {
+		msg = e.Error.Message
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, missingError("gcp: " + msg)
+	}
+	return nil, fmt.Errorf("gcp: %s", msg)
+}
+
+// resolveGCPSecret returns the Secret Manager secret version ref refers to, as
+// "projects/p/secrets/s/versions/v", optionally followed by "#key" to take one
+// key of a JSON secret. Without a version, the latest one is read.
+func resolveGCPSecret(ctx context.Context, ref string, o *options, fetched map[string]interface{}) (string, error) {
+	name, key, hasKey := strings.Cut(ref, "#")
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	cacheKey := "gcpsecret\x00" + name
+	secret, ok := fetched[cacheKey].(string)
+	if !ok {
+		resp, err := gcpSettings(o).get(ctx, "https://secretmanager.googleapis.com/v1/"+name+":access")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		var body struct {
+			Payload struct {
+				Data string `json:"data"`
+			} `json:"payload"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", err
+		}
+		data, err := base64.StdEncoding.DecodeString(body.Payload.Data)
+		if err != nil {
+			return "", err
+		}
+		secret = string(data)
+		fetched[cacheKey] = secret
+	}
+	if !hasKey {
+		return secret, nil
+	}
+	return secretKey(secret, key)
+}
+
+var (
+	// gcpTokensMu guards gcpTokens.
+	gcpTokensMu sync.Mutex
+	// gcpTokens caches the access tokens of Application Default Credentials
+	// until shortly before they expire, by credentials file, or "" for the
+	// metadata server.
+	gcpTokens = map[string]cachedToken{}
+)
+
+// defaultGCPToken returns an access token from Application Default
+// Credentials.
+func defaultGCPToken(ctx context.Context) (string, error) {
+	file := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if file == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			wellKnown := filepath.Join(dir, "gcloud", "application_default_credentials.json")
+			if os.Getenv("CLOUDSDK_CONFIG") != "" {
+				wellKnown = filepath.Join(os.Getenv("CLOUDSDK_CONFIG"), "application_default_credentials.json")
+			}
+			if _, err := os.Stat(wellKnown); err == nil {
+				file = wellKnown
+			}
+		}
+	}
+	gcpTokensMu.Lock()
+	cached, ok := gcpTokens[file]
+	gcpTokensMu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.token, nil
+	}
+	var token string
+	var ttl time.Duration
+	var err error
+	if file != "" {
+		token, ttl, err = gcpFileToken(ctx, file)
+	} else {
+		token, ttl, err = gcpMetadataToken(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+	gcpTokensMu.Lock()
+	gcpTokens[file] = cachedToken{token, time.Now().Add(ttl * 9 / 10)}
+	gcpTokensMu.Unlock()
+	return token, nil
+}
+
+// gcpTokenResponse is the response of an OAuth2 token endpoint.
+type gcpTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func (t gcpTokenResponse) ttl() time.Duration {
+	return time.Duration(t.ExpiresIn) * time.Second
+}
+
+// gcpMetadataToken gets an access token for the default service account from
+// the metadata server.
+func gcpMetadataToken(ctx context.Context) (string, time.Duration, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var t gcpTokenResponse
+	if err := doToken(req, &t); err != nil {
+		return "", 0, fmt.Errorf("no GCP credentials: metadata server: %w", err)
+	}
+	return t.AccessToken, t.ttl(), nil
+}
+
+// gcpFileToken gets an access token for the service account or user
+// credentials in file.
+func gcpFileToken(ctx context.Context, file string) (string, time.Duration, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", 0, err
+	}
+	var creds struct {
+		Type         string `json:"type"`
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		TokenURI     string `json:"token_uri"`
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return "", 0, fmt.Errorf("%s: %s", file, err)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	form := url.Values{}
+	switch creds.Type {
+	case "service_account":
+		assertion, err := gcpAssertion(creds.ClientEmail, creds.PrivateKey, creds.TokenURI)
+		if err != nil {
+			return "", 0, fmt.Errorf("%s: %s", file, err)
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case "authorized_user":
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", creds.ClientID)
+		form.Set("client_secret", creds.ClientSecret)
+		form.Set("refresh_token", creds.RefreshToken)
+	default:
+		return "", 0, fmt.Errorf("%s: unsupported credentials type %q", file, creds.Type)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, creds.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var t gcpTokenResponse
+	if err := doToken(req, &t); err != nil {
+		return "", 0, err
+	}
+	return t.AccessToken, t.ttl(), nil
+}
+
+// gcpAssertion returns a JWT signed with the service account's private key,
+// to exchange for an access token.
+func gcpAssertion(email, privateKey, audience string) (string, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return "", errors.New("invalid private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", err
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("private key is not an RSA key")
+	}
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   email,
+		"scope": gcpScope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// doToken sends a request to an OAuth2 token endpoint and decodes the token.
+func doToken(req *http.Request, t *gcpTokenResponse) error {
+	resp, err := defaultHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("token endpoint: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(t)
+}
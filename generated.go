@@ -0,0 +1,82 @@
+package goconfig
+
+import "reflect"
+
+// Generated is implemented by configs with code generated by goconfig-gen,
+// as in
+//
+//	//go:generate go run github.com/santiclause/goconfig/cmd/goconfig-gen -type Config
+//
+// which Load then uses instead of reflection to apply defaults, read
+// environment variables, check required fields and copy the config. Decoding
+// config files and checking `validate` tags still use reflection.
+type Generated interface {
+	// GoconfigDefaults applies the `default` tags of the config.
+	GoconfigDefaults() error
+	// GoconfigEnv reads the config from the environment as its `env` tags
+	// say, each name prefixed with prefix.
+	GoconfigEnv(prefix string) error
+	// GoconfigMissing returns the required fields that are unset.
+	GoconfigMissing(prefix string) []MissingField
+	// GoconfigCopy returns a deep copy of the config, as a pointer.
+	GoconfigCopy() interface{}
+}
+
+// GeneratedField describes a field of a type the generated code doesn't
+// handle itself, such as a type from another package, for which it calls
+// the methods of GeneratedField instead, which use reflection.
+type GeneratedField struct {
+	// Name is the name of the field, and Tag its tag.
+	Name string
+	Tag  reflect.StructTag
+	// Embedded is set if the field is embedded.
+	Embedded bool
+}
+
+// info returns f, with its tags parsed, as the field of the type ptr points
+// to.
+func (f GeneratedField) info(ptr interface{}) *fieldInfo {
+	info := newFieldInfo(reflect.StructField{Name: f.Name, Tag: f.Tag, Anonymous: f.Embedded, Type: reflect.TypeOf(ptr).Elem()})
+	return &info
+}
+
+// Defaults applies the `default` tag of the field pointed to by ptr, within
+// the struct whose Go path is parent, or the defaults of the struct it holds.
+func (f GeneratedField) Defaults(ptr interface{}, parent string) error {
+	info := f.info(ptr)
+	return applyFieldDefaults(reflect.ValueOf(ptr).Elem(), info, fieldPath{field: parent}.childField(info))
+}
+
+// Env reads the field pointed to by ptr, or the fields of the struct it
+// holds, from the environment, each name prefixed with prefix.
+func (f GeneratedField) Env(ptr interface{}, prefix string) []string {
+	var errs []string
+	parseEnvField(reflect.ValueOf(ptr).Elem(), f.info(ptr), prefix, nil, &errs)
+	return errs
+}
+
+// Missing returns the field pointed to by ptr, within the struct whose Go
+// and yaml key paths are parentField and parentKey, if it is required and
+// unset, or else the required fields of the structs it holds that are. The
+// field mustn't have `required_if` or `required_with` tags.
+func (f GeneratedField) Missing(ptr interface{}, parentField, parentKey, prefix string) []MissingField {
+	info := f.info(ptr)
+	name := fieldPath{parentField, parentKey}.childField(info)
+	return findMissingInField(reflect.Value{}, reflect.ValueOf(ptr).Elem(), info, name, prefix)
+}
+
+// CopyValue sets the value pointed to by dst to a deep copy of the one
+// pointed to by src, as Clone copies it, for the generated code of fields of
+// types it doesn't copy itself.
+func CopyValue(dst, src interface{}) {
+	reflect.ValueOf(dst).Elem().Set(deepCopy(reflect.ValueOf(src).Elem()))
+}
+
+// copyConfig returns a deep copy of the config pointed to by target, with its
+// generated code if it has some.
+func copyConfig(target interface{}) interface{} {
+	if g, ok := target.(Generated); ok {
+		return g.GoconfigCopy()
+	}
+	return deepCopy(reflect.ValueOf(target)).Interface()
+}
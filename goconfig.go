@@ -1,18 +1,17 @@
 package goconfig
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"os/signal"
 	"reflect"
 	"strings"
 	"sync"
-	"syscall"
-
-	"github.com/santiclause/env"
-	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -35,7 +34,9 @@ var (
 type Config struct {
 	Debug string `yaml:"debug" env:"DEBUG"`
 	// filename is the path and filename of the yaml config file.
-	filename  string
+	filename string
+	// envPrefix is prepended to the names in env tags.
+	envPrefix string
 	listening bool
 	// Mutex guards readwrite access to Config.
 	sync.RWMutex
@@ -49,6 +50,16 @@ func (c *Config) SetFilename(filename string) {
 	c.filename = filename
 }
 
+func (c *Config) GetEnvPrefix() string {
+	return c.envPrefix
+}
+
+// SetEnvPrefix sets a prefix, such as "MYAPP_", prepended to the environment
+// variable names in the env tags of this instance.
+func (c *Config) SetEnvPrefix(prefix string) {
+	c.envPrefix = prefix
+}
+
 func (c *Config) IsListening() bool {
 	return c.listening
 }
@@ -61,81 +72,535 @@ func (c *Config) DebugLevel(level string) bool {
 	return debugLevelMap[c.Debug] >= debugLevelMap[level]
 }
 
+// Configterface is implemented by configs that keep their own filename and
+// lock, as Config provides them. Reloads hold the write lock, so readers only
+// need RLock. Configs needn't implement it: the library keeps the state of
+// any other pointer to a struct passed to it, by pointer.
 type Configterface interface {
 	GetFilename() string
 	IsListening() bool
 	SetListening(bool)
 	Lock()
 	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// envPrefix returns the env prefix of c, if it has one.
+func envPrefix(c Configterface) string {
+	if p, ok := c.(interface{ GetEnvPrefix() string }); ok {
+		return p.GetEnvPrefix()
+	}
+	return ""
+}
+
+// MissingField describes a required field that was left unset.
+type MissingField struct {
+	// Path is the Go field path, e.g. "Database.Password".
+	Path string
+	// YAML is the yaml key path, e.g. "database.password".
+	YAML string
+	// Env is the name of the field's environment variable, if it has one.
+	Env string
+}
+
+func (f MissingField) String() string {
+	s := fmt.Sprintf("%s (yaml: %s", f.Path, f.YAML)
+	if f.Env != "" {
+		s += ", env: " + f.Env
+	}
+	return s + ")"
 }
 
 type MissingRequiredStructFields struct {
-	missing []string
+	Fields []MissingField
 }
 
 func (e MissingRequiredStructFields) Error() string {
-	return fmt.Sprintf("The following struct fields have missing values: %s", strings.Trim(fmt.Sprintf("%v", e.missing), "[]"))
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.String()
+	}
+	return fmt.Sprintf("The following struct fields have missing values: %s", strings.Join(parts, ", "))
+}
+
+// ErrNotPointer is returned when a config is passed by value rather than as a
+// pointer to a struct.
+var ErrNotPointer = errors.New("config must be a pointer to a struct")
+
+// ErrNoReloadSignal is returned by ListenForSignals on platforms without a
+// default reload signal, i.e. Windows, unless signals are given with
+// WithSignals.
+var ErrNoReloadSignal = errors.New("no signal to reload the config on")
+
+// managedConfig is implemented by configs whose values are held elsewhere and
+// replaced wholesale on each Load, such as a Handle.
+type managedConfig interface {
+	// target returns a pointer to the current value.
+	target() interface{}
+	// replace makes v the current value. It is called with the config
+	// locked.
+	replace(v interface{})
+	// snapshot returns a copy of the config holding a deep copy of its
+	// value, as Snapshot returns it.
+	snapshot() interface{}
+}
+
+// configTarget returns the struct pointer the values of c are loaded into.
+func configTarget(c interface{}) interface{} {
+	if m, ok := c.(managedConfig); ok {
+		return m.target()
+	}
+	return c
+}
+
+// checkPointer returns ErrNotPointer unless c is, or manages, a non-nil
+// pointer to a struct.
+func checkPointer(c Configterface) error {
+	value := reflect.ValueOf(configTarget(c))
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return ErrNotPointer
+	}
+	return nil
+}
+
+// Loads (or reloads) the config file from disk, then the environment. The file
+// format is picked from the filename extension (.yaml/.yml, .json, .toml or any
+// extension added with RegisterDecoder), defaulting to YAML. A directory stands
+// for all the config files in it, merged in lexical order, and a file may list
+// other files to merge it over, relative to itself, under an `include` key.
+//
+// A missing config file is ignored. Options tune each call, e.g. WithFile or
+// WithFiles to load other files, WithFormat, Strict, WithEnvPrefix or SkipEnv.
+//
+// config is a pointer to a struct, which needn't embed Config, as in
+//
+//	var cfg AppConfig
+//	err := goconfig.Load(&cfg, goconfig.WithFile("app.yaml"))
+//
+// in which case its filename and lock are kept by the library, and reloads
+// assign it in place: read it through Snapshot or a Store while it may be
+// reloaded, or use New for a Handle.
+func Load(config interface{}, opts ...Option) error {
+	return LoadContext(context.Background(), config, opts...)
+}
+
+// LoadContext is Load giving up once ctx is done, as with a timeout at
+// startup. Remote sources and secret stores are called with ctx, and the
+// load fails with its error between reading files, before validation and
+// before c is modified.
+func LoadContext(ctx context.Context, config interface{}, opts ...Option) (err error) {
+	c := configOf(config)
+	if err := checkPointer(c); err != nil {
+		return err
+	}
+	o := newOptions(opts)
+	ctx, span := o.startSpan(ctx, "goconfig.Load", "reload", o.reloading)
+	defer func() {
+		recordLoad(c, o, err)
+		span.End(err)
+	}()
+	files := o.files
+	if len(files) == 0 && c.GetFilename() == "" && o.discoverDirs != nil {
+		if found := discoverFile(o.discoverDirs, o.discoverNames); found != "" {
+			if s, ok := c.(interface{ SetFilename(string) }); ok && !o.dryRun {
+				s.SetFilename(found)
+			}
+			files = []string{found}
+		}
+	}
+	if len(files) == 0 {
+		files = []string{c.GetFilename()}
+	}
+	r, err := readerFor(ctx, osFS{}, o, c)
+	if err != nil {
+		return err
+	}
+	if o.filePermissions != nil && hasSecrets(reflect.TypeOf(configTarget(c))) {
+		r.permissions = o.filePermissions
+	}
+	var doc *document
+	// The errors of every file are reported together.
+	var errs []error
+	for _, filename := range files {
+		d, err := r.read(filename)
+		if err != nil {
+			errs = append(errs, &SourceError{Source: SourceFile, Err: err})
+			continue
+		}
+		if u, _, ok := remoteURL(filename); ok && !o.dryRun {
+			recordFetch(c, u.Redacted(), nil)
+		}
+		if d == nil && filename != "" {
+			o.log().Debug("config file not found", "file", filename)
+		}
+		if d == nil && o.requiredFiles[filename] && !o.fileOptional {
+			errs = append(errs, &SourceError{Source: SourceFile, Err: fmt.Errorf("config file %s: %w", filename, fs.ErrNotExist)})
+			continue
+		}
+		doc = overlay(doc, d, r.rules)
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	if !o.skipEnv {
+		r.sumEnvFiles(c)
+	}
+	o.checksum = r.digest.Sum(nil)
+	if o.reloading && o.unchanged != nil && unchangedSince(c, o) {
+		*o.unchanged = true
+		return nil
+	}
+	return load(ctx, c, doc, o)
+}
+
+// Loads the config from r instead of the configured file. The data is parsed
+// as YAML unless overridden with WithFormat.
+func LoadFrom(r io.Reader, config interface{}, opts ...Option) (err error) {
+	c := configOf(config)
+	if err := checkPointer(c); err != nil {
+		return err
+	}
+	o := newOptions(opts)
+	ctx, span := o.startSpan(context.Background(), "goconfig.Load", "reload", o.reloading)
+	defer func() {
+		recordLoad(c, o, err)
+		span.End(err)
+	}()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	span.SetAttributes("size", len(data))
+	reader, err := readerFor(ctx, osFS{}, o, c)
+	if err != nil {
+		return err
+	}
+	doc, err := reader.parse("", data)
+	if err != nil {
+		return &SourceError{Source: SourceFile, Err: err}
+	}
+	return load(ctx, c, doc, o)
+}
+
+// Loads the config from the named file in fsys, e.g. an embed.FS, instead of
+// the configured file. As with Load, the format is picked from the extension of
+// name and a missing file leaves env as the only source.
+func LoadFS(fsys fs.FS, name string, config interface{}, opts ...Option) (err error) {
+	c := configOf(config)
+	if err := checkPointer(c); err != nil {
+		return err
+	}
+	o := newOptions(opts)
+	ctx, span := o.startSpan(context.Background(), "goconfig.Load", "reload", o.reloading)
+	defer func() {
+		recordLoad(c, o, err)
+		span.End(err)
+	}()
+	r, err := readerFor(ctx, ioFS{fsys}, o, c)
+	if err != nil {
+		return err
+	}
+	doc, err := r.read(name)
+	if err != nil {
+		return &SourceError{Source: SourceFile, Err: err}
+	}
+	return load(ctx, c, doc, o)
+}
+
+// Validate checks that c would load with opts, as Load does, without modifying
+// it: the config files must parse, set no keys that don't map to a field, as
+// with Strict, and give every required field a value, and the result must
+// pass the `validate` tags and Validate methods. It suits a --check-config
+// mode or a CI step checking config changes before they are deployed, as in
+//
+//	err := goconfig.Validate(&Config{}, goconfig.WithFile("deploy/prod.yaml"))
+func Validate(config interface{}, opts ...Option) error {
+	return Load(config, append(append([]Option{Strict()}, opts...), dryRun)...)
+}
+
+// dryRun makes a Load check the config without modifying it.
+func dryRun(o *options) {
+	o.dryRun = true
+}
+
+var (
+	// loadsMu guards loads.
+	loadsMu sync.Mutex
+	// loads holds the locks of the configs being loaded, by loadKey.
+	loads = map[interface{}]*loadLock{}
+)
+
+// loadLock serializes the loads of a config. It is dropped once no load
+// holds or waits for it, so that configs that were loaded keep nothing.
+type loadLock struct {
+	sync.Mutex
+	// users counts the loads holding or waiting for the lock.
+	users int
+}
+
+// lockLoad waits for any other load of c to finish and returns the function
+// letting the next one go ahead.
+func lockLoad(c Configterface) (unlock func()) {
+	key := loadKey(c)
+	loadsMu.Lock()
+	l, ok := loads[key]
+	if !ok {
+		l = &loadLock{}
+		loads[key] = l
+	}
+	l.users++
+	loadsMu.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		loadsMu.Lock()
+		defer loadsMu.Unlock()
+		if l.users--; l.users == 0 {
+			delete(loads, key)
+		}
+	}
 }
 
-// Loads (or reloads) the config file from disk.
-func Load(c Configterface) error {
-	if reflect.ValueOf(c).Kind() != reflect.Ptr {
-		panic("Load only accepts pointers to structs")
+// loadKey identifies c across calls: the struct pointer of a plain config,
+// whose state may be a temporary one, or else c itself.
+func loadKey(c Configterface) interface{} {
+	if p, ok := c.(*plainConfig); ok {
+		return p.ptr
+	}
+	return c
+}
+
+// load fills in defaults, applies doc and the environment to c in order of
+// precedence, then validates the result, reporting all the errors it finds.
+// c is only modified if all of that succeeds. With the dryRun option it never
+// is.
+func load(ctx context.Context, c Configterface, doc *document, o *options) error {
+	if o.dotEnv != nil {
+		env, err := readDotEnv(o.dotEnv)
+		if err != nil {
+			return err
+		}
+		o.env = env
+	}
+	if o.profile != "" {
+		rules, err := mergeRulesFor(reflect.TypeOf(configTarget(c)))
+		if err != nil {
+			return err
+		}
+		if doc, err = selectProfile(doc, o.profile, rules); err != nil {
+			return err
+		}
+	}
+	if o.expandEnv && doc != nil {
+		var keep func(string) bool
+		if o.interpolate {
+			t := reflect.TypeOf(configTarget(c))
+			keep = func(ref string) bool {
+				name, _, _ := strings.Cut(ref, ":-")
+				return isKeyOf(t, strings.TrimSpace(name))
+			}
+		}
+		expandEnvTree(doc.tree, o.env, keep)
+		doc.merged = true
+	}
+	// Everything is applied to a copy, which only replaces c once it has
+	// been validated, so that c is left as it was if anything fails. c is
+	// only locked to copy it and to swap the copy in, so that it can be read
+	// while secret stores are queried, but loads of c take turns, so that
+	// none swaps in a copy taken before another one's swap.
+	defer lockLoad(c)()
+	c.RLock()
+	prefix := o.envPrefixFor(c)
+	scratch := copyConfig(configTarget(c))
+	c.RUnlock()
+	// Every source is applied and the result validated even if some fail,
+	// so that all the problems are reported at once.
+	var errs []error
+	var sections []sectionLoad
+	if o.sections {
+		var err error
+		if sections, err = loadSections(scratch, doc, o, prefix); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := applyDefaults(scratch); err != nil {
+		errs = append(errs, err)
+	}
+	for _, source := range o.precedence {
+		var err error
+		switch source {
+		case SourceFile:
+			err = doc.decode(scratch, o.strict)
+		case SourceEnv:
+			if !o.skipEnv {
+				err = parseEnv(scratch, prefix, o.env)
+				warnUnknownEnv(scratch, sections, prefix, o)
+			}
+		case SourceFlags:
+			if o.flags != nil {
+				err = parseFlags(scratch, o.flags)
+			}
+		case SourceOverrides:
+			if o.overrides != nil {
+				err = applyOverrides(scratch, o.overrides)
+			}
+		case SourceSecrets:
+			err = resolveSecrets(ctx, scratch, o)
+		default:
+			return fmt.Errorf("unknown config source %v", source)
+		}
+		if err != nil {
+			errs = append(errs, &SourceError{Source: source, Err: err})
+		}
+	}
+	if o.interpolate {
+		if err := interpolate(scratch, doc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if o.reloading && o.dynamicReload {
+		c.RLock()
+		keepStatic(reflect.ValueOf(configTarget(c)).Elem(), reflect.ValueOf(scratch).Elem())
+		c.RUnlock()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, span := o.startSpan(ctx, "goconfig.validate")
+	err := validate(scratch, prefix)
+	span.End(err)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
 	}
-	data, err := ioutil.ReadFile(c.GetFilename())
 	c.Lock()
 	defer c.Unlock()
-	if err == nil {
-		if err := yaml.Unmarshal(data, c); err != nil {
+	if o.reloading || o.overriding {
+		if err := checkReloadable(configTarget(c), scratch); err != nil {
 			return err
 		}
 	}
-	if err := env.Parse(c); err != nil {
-		return err
+	if o.dryRun {
+		return nil
 	}
-	if err := findMissingRequiredFields(c); err != nil {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
+	_, span = o.startSpan(ctx, "goconfig.swap")
+	if m, ok := c.(managedConfig); ok {
+		m.replace(scratch)
+	} else {
+		assignConfig(reflect.ValueOf(c).Elem(), reflect.ValueOf(scratch).Elem())
+	}
+	for _, section := range sections {
+		section.swap()
+	}
+	span.End(nil)
+	for _, publish := range o.stores {
+		publish(configTarget(c))
+	}
+	if o.expvarName != "" {
+		publishExpvar(o.expvarName, c, o)
+	}
+	recordChecksum(c, o.checksum)
 	return nil
 }
 
-// Reloads the config file on SIGHUP.
-func ListenForSignals(c Configterface) {
-	if reflect.ValueOf(c).Kind() != reflect.Ptr {
-		panic("ListenForSignals only accepts pointers to structs")
+// warnUnknownEnv logs a warning for each environment variable with the env
+// prefix that neither the config nor its sections read. Reloads don't repeat
+// the warnings.
+func warnUnknownEnv(scratch interface{}, sections []sectionLoad, prefix string, o *options) {
+	if prefix == "" || o.reloading {
+		return
+	}
+	vals := []interface{}{scratch}
+	for _, section := range sections {
+		vals = append(vals, section.scratch)
+	}
+	for _, name := range unknownEnv(prefix, o.env, vals...) {
+		o.log().Warn("unknown environment variable", "name", name, "prefix", prefix)
+	}
+}
+
+// validate reports missing required fields, fields breaking their `validate`
+// tag rules and errors returned by Validate methods.
+func validate(c interface{}, envPrefix string) error {
+	var errs []error
+	if err := findMissingRequiredFields(c, envPrefix); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkValidateTags(c); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, callValidators(c)...)
+	return joinErrors(errs)
+}
+
+// Reloads the config file on SIGHUP, or the signals given with WithSignals,
+// passing opts to each Load. There is no SIGHUP on Windows, which has to make
+// do with Reload, Watch or WatchTrigger. Reload
+// failures leave the config as it was and are passed to the OnError callback,
+// sent to subscribers or else logged.
+func ListenForSignals(config interface{}, opts ...Option) error {
+	return ListenForSignalsContext(context.Background(), config, opts...)
+}
+
+// ListenForSignalsContext is ListenForSignals until ctx is done, at which point
+// the signal handler is unregistered and the config may be listened to again.
+func ListenForSignalsContext(ctx context.Context, config interface{}, opts ...Option) error {
+	c := keepConfig(config)
+	if err := checkPointer(c); err != nil {
+		return err
 	}
 	c.Lock()
 	defer c.Unlock()
 	if c.IsListening() {
-		return
+		return nil
+	}
+	signals := newOptions(opts).signals
+	if len(signals) == 0 {
+		signals = reloadSignals
+	}
+	if len(signals) == 0 {
+		return ErrNoReloadSignal
 	}
-	c.SetListening(true)
 	s := make(chan os.Signal, 1)
-	signal.Notify(s, syscall.SIGHUP)
+	signal.Notify(s, signals...)
+	c.SetListening(true)
+	r := newReloader(c, opts, 0)
 	go func() {
 		for {
-			<-s
-			if err := Load(c); err != nil {
-				panic(fmt.Sprintf("config file error: %s", err))
+			select {
+			case <-s:
+				r.trigger("")
+			case <-ctx.Done():
+				signal.Stop(s)
+				r.stop()
+				c.Lock()
+				c.SetListening(false)
+				c.Unlock()
+				return
 			}
 		}
 	}()
+	return nil
 }
 
-func findMissingRequiredFields(val interface{}) error {
-	var missing []string
+func findMissingRequiredFields(val interface{}, envPrefix string) error {
+	if g, ok := val.(Generated); ok {
+		if missing := g.GoconfigMissing(envPrefix); missing != nil {
+			return MissingRequiredStructFields{missing}
+		}
+		return nil
+	}
 	value := reflect.ValueOf(val)
 	for {
 		switch value.Kind() {
 		case reflect.Struct:
-			for i := 0; i < value.NumField(); i++ {
-				tag := value.Type().Field(i).Tag
-				name := value.Type().Field(i).Name
-				field := value.Field(i)
-				if tag.Get("required") == "true" && isZero(field) {
-					missing = append(missing, name)
-				}
-			}
+			missing := findMissingInStruct(value, fieldPath{}, envPrefix)
 			if missing != nil {
 				return MissingRequiredStructFields{missing}
 			}
@@ -151,8 +616,86 @@ func findMissingRequiredFields(val interface{}) error {
 	}
 }
 
+// findMissingInStruct returns the paths of the required fields of value that
+// are unset, recursing into nested structs, non-nil pointers to structs and
+// collections of structs.
+func findMissingInStruct(value reflect.Value, path fieldPath, envPrefix string) []MissingField {
+	var missing []MissingField
+	fields := fieldsOf(value.Type())
+	for i := range fields {
+		f := &fields[i]
+		missing = append(missing, findMissingInField(value, value.Field(f.Index[0]), f, path.childField(f), envPrefix)...)
+	}
+	return missing
+}
+
+// findMissingInField returns field, found at name in the struct parent, if
+// it is required and unset, or else the required fields of the structs it
+// holds that are.
+func findMissingInField(parent, field reflect.Value, f *fieldInfo, name fieldPath, envPrefix string) []MissingField {
+	if isRequired(parent, f) && isZero(field) {
+		env := f.env
+		if env != "" {
+			env = envPrefix + env
+		}
+		return []MissingField{{Path: name.field, YAML: name.key, Env: env}}
+	}
+	var missing []MissingField
+	for _, nested := range nestedStructs(field, name) {
+		missing = append(missing, findMissingInStruct(nested.value, nested.path, envPrefix)...)
+	}
+	return missing
+}
+
+// isRequired reports whether the field f must be set, given the values of its
+// sibling fields in parent. `required_if:"Field=value ..."` requires it when
+// every named sibling has the given value, and `required_with:"Field ..."`
+// when any of the named siblings is set.
+func isRequired(parent reflect.Value, f *fieldInfo) bool {
+	if f.required {
+		return true
+	}
+	if f.hasRequiredIf {
+		met := true
+		for _, cond := range f.requiredIf {
+			name, want, _ := strings.Cut(cond, "=")
+			sibling := reflect.Indirect(parent.FieldByName(name))
+			if !sibling.IsValid() || fmt.Sprint(sibling.Interface()) != want {
+				met = false
+				break
+			}
+		}
+		if met {
+			return true
+		}
+	}
+	for _, name := range f.requiredWith {
+		if sibling := parent.FieldByName(name); sibling.IsValid() && !isZero(sibling) {
+			return true
+		}
+	}
+	return false
+}
+
+// zeroer is implemented by types with their own notion of being unset.
+type zeroer interface {
+	IsZero() bool
+}
+
 // Shamelessly stolen from the 2nd answer of https://stackoverflow.com/questions/23555241/golang-reflection-how-to-get-zero-value-of-a-field-type
 func isZero(v reflect.Value) bool {
+	// Modified: types that know when they're unset, like time.Time, get the
+	// final say.
+	if v.Kind() != reflect.Ptr && v.CanInterface() {
+		if z, ok := v.Interface().(zeroer); ok {
+			return z.IsZero()
+		}
+		if v.CanAddr() {
+			if z, ok := v.Addr().Interface().(zeroer); ok {
+				return z.IsZero()
+			}
+		}
+	}
 	switch v.Kind() {
 	case reflect.Func, reflect.Map, reflect.Slice:
 		return v.IsNil()
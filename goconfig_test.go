@@ -0,0 +1,234 @@
+package goconfig
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type lockingConfig struct {
+	Config
+	Host     string `yaml:"host"`
+	Password string `yaml:"password" locktest:"db"`
+}
+
+func TestLoadDoesNotLockWhileResolving(t *testing.T) {
+	c := &lockingConfig{Host: "before"}
+	var readable bool
+	var host string
+	RegisterResolver("locktest", func(ctx context.Context, ref string) (string, error) {
+		if readable = c.TryRLock(); readable {
+			host = c.Host
+			c.RUnlock()
+		}
+		return "secret", nil
+	})
+	if err := Load(c, WithFile(writeConfigFile(t, "config.yaml", "host: after\n"))); err != nil {
+		t.Fatal(err)
+	}
+	if !readable {
+		t.Fatal("config locked while its secrets were resolved")
+	}
+	if host != "before" {
+		t.Errorf("host read while resolving = %q, want the old value", host)
+	}
+	if c.Host != "after" || c.Password != "secret" {
+		t.Errorf("config = %q, %q", c.Host, c.Password)
+	}
+}
+
+func TestLoadReportsDefaultErrorsWithOthers(t *testing.T) {
+	var c struct {
+		Port    int    `yaml:"port" default:"eighty"`
+		Name    string `yaml:"name" required:"true"`
+		Timeout int    `yaml:"timeout"`
+	}
+	err := Load(&c, WithFile(writeConfigFile(t, "config.yaml", "timeout: soon\n")))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"eighty", "soon", "Name"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+}
+
+type concurrentConfig struct {
+	Config
+	Port     int    `yaml:"port"`
+	Password string `yaml:"password" concurrenttest:"db"`
+}
+
+func TestConcurrentLoads(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	RegisterResolver("concurrenttest", func(ctx context.Context, ref string) (string, error) {
+		close(started)
+		<-release
+		return "secret", nil
+	})
+	c := &concurrentConfig{}
+	filename := writeConfigFile(t, "config.yaml", "port: 1\n")
+	loaded := make(chan error)
+	go func() { loaded <- Load(c, WithFile(filename)) }()
+	<-started
+
+	// Both happen while the load is resolving its secrets.
+	c.Lock()
+	c.SetListening(true)
+	c.Unlock()
+	overridden := make(chan error)
+	go func() { overridden <- ApplyOverrides(c, []string{"port=2"}) }()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if err := <-loaded; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-overridden; err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsListening() {
+		t.Error("listening state lost by the load")
+	}
+	if c.Port != 2 || c.Password != "secret" {
+		t.Errorf("config = %d, %q, want the override applied after the load", c.Port, c.Password)
+	}
+}
+
+func TestIntegersParsedInBase10(t *testing.T) {
+	t.Setenv("BASETEST_PORT", "010")
+	t.Setenv("BASETEST_MASK", "0x1F")
+	t.Setenv("BASETEST_MODE", "ff")
+	var c struct {
+		Port  int    `env:"BASETEST_PORT"`
+		Mask  uint   `env:"BASETEST_MASK" base:"0"`
+		Mode  int    `env:"BASETEST_MODE" base:"16"`
+		Limit int    `default:"0100"`
+		Perm  uint32 `default:"0o644" base:"0"`
+	}
+	if err := Load(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 10 || c.Mask != 31 || c.Mode != 255 || c.Limit != 100 || c.Perm != 0o644 {
+		t.Errorf("config = %d, %d, %d, %d, %o", c.Port, c.Mask, c.Mode, c.Limit, c.Perm)
+	}
+
+	t.Setenv("BASETEST_MASK", "0x1F")
+	var bad struct {
+		Mask uint `env:"BASETEST_MASK"`
+	}
+	if err := Load(&bad); err == nil {
+		t.Error("prefixed literal accepted without a base tag")
+	}
+}
+
+// window implements IsZero with a pointer receiver, and is unset when it has
+// no end, whatever its start.
+type window struct {
+	Start, End int
+}
+
+func (w *window) IsZero() bool { return w.End == 0 }
+
+type requiredDB struct {
+	Host     string `yaml:"host"`
+	Password string `yaml:"password" required:"true" env:"DB_PASSWORD"`
+}
+
+type requiredConfig struct {
+	DB       requiredDB  `yaml:"db"`
+	Replica  *requiredDB `yaml:"replica"`
+	Primary  *requiredDB `yaml:"primary" required:"true"`
+	Since    time.Time   `yaml:"since" required:"true"`
+	Window   window      `yaml:"window" required:"true"`
+	Optional *requiredDB `yaml:"optional"`
+}
+
+func TestRequiredFields(t *testing.T) {
+	// Replica is nil, so its fields aren't required, but those of Optional
+	// are, as it is set.
+	c := requiredConfig{
+		Window:   window{Start: 1},
+		Optional: &requiredDB{Host: "b"},
+	}
+	err := findMissingRequiredFields(&c, "APP_")
+	missing, ok := err.(MissingRequiredStructFields)
+	if !ok {
+		t.Fatalf("err = %v", err)
+	}
+	want := []MissingField{
+		{Path: "DB.Password", YAML: "db.password", Env: "APP_DB_PASSWORD"},
+		{Path: "Primary", YAML: "primary"},
+		{Path: "Since", YAML: "since"},
+		{Path: "Window", YAML: "window"},
+		{Path: "Optional.Password", YAML: "optional.password", Env: "APP_DB_PASSWORD"},
+	}
+	if len(missing.Fields) != len(want) {
+		t.Fatalf("missing = %v", missing.Fields)
+	}
+	for i := range want {
+		if missing.Fields[i] != want[i] {
+			t.Errorf("missing %d = %v, want %v", i, missing.Fields[i], want[i])
+		}
+	}
+	const msg = "The following struct fields have missing values: DB.Password (yaml: db.password, env: APP_DB_PASSWORD), Primary (yaml: primary), Since (yaml: since), Window (yaml: window), Optional.Password (yaml: optional.password, env: APP_DB_PASSWORD)"
+	if err.Error() != msg {
+		t.Errorf("error = %q", err)
+	}
+
+	c.DB.Password, c.Optional.Password = "secret", "secret"
+	c.Primary = &requiredDB{Password: "secret"}
+	c.Since, c.Window.End = time.Now(), 2
+	if err := findMissingRequiredFields(&c, ""); err != nil {
+		t.Error(err)
+	}
+
+	if err := findMissingRequiredFields((*requiredConfig)(nil), ""); err == nil {
+		t.Error("nil config accepted")
+	}
+}
+
+func TestWithPrecedence(t *testing.T) {
+	t.Setenv("PRECEDENCETEST_PORT", "2")
+	t.Setenv("PRECEDENCETEST_HOST", "env")
+	type config struct {
+		Host string `yaml:"host" env:"PRECEDENCETEST_HOST"`
+		Port int    `yaml:"port" env:"PRECEDENCETEST_PORT" default:"8080"`
+		Name string `yaml:"name" default:"app"`
+	}
+	filename := writeConfigFile(t, "config.yaml", "host: file\nport: 1\n")
+	overrides := WithOverrides("port=3")
+	tests := []struct {
+		name       string
+		precedence []Source
+		host       string
+		port       int
+	}{
+		{"default", nil, "env", 3},
+		{"file over env", []Source{SourceEnv, SourceFile, SourceOverrides}, "file", 3},
+		{"env over overrides", []Source{SourceFile, SourceOverrides, SourceEnv}, "env", 2},
+		{"file only", []Source{SourceFile}, "file", 1},
+		{"env only", []Source{SourceEnv}, "env", 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := []Option{WithFile(filename), overrides}
+			if test.precedence != nil {
+				opts = append(opts, WithPrecedence(test.precedence...))
+			}
+			var c config
+			if err := Load(&c, opts...); err != nil {
+				t.Fatal(err)
+			}
+			if c.Host != test.host || c.Port != test.port {
+				t.Errorf("config = %q, %d, want %q, %d", c.Host, c.Port, test.host, test.port)
+			}
+			// Defaults are always applied beneath the sources.
+			if c.Name != "app" {
+				t.Errorf("name = %q", c.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package goconfig
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Handle manages a config of any struct type T, holding the filename and
+// locking that a config otherwise gets by embedding Config. Each Load builds a
+// new *T and swaps it in, so the values returned by Get are never modified and
+// can be read without locking.
+type Handle[T any] struct {
+	value     atomic.Pointer[T]
+	filename  string
+	listening bool
+	opts      []Option
+	mu        sync.RWMutex
+}
+
+// New loads a config of type T from filename, which may be empty to rely on
+// WithFile, WithFiles, Discover or the environment alone, and returns a handle
+// to it. opts are used for this and every later load through the handle.
+func New[T any](filename string, opts ...Option) (*Handle[T], error) {
+	h := &Handle[T]{filename: filename, opts: opts}
+	h.value.Store(new(T))
+	if err := Load(h, opts...); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Get returns the current config. It must not be modified.
+func (h *Handle[T]) Get() *T {
+	return h.value.Load()
+}
+
+// Reload reloads the config, as with the package-level Reload.
+func (h *Handle[T]) Reload() error {
+	return Reload(h, h.opts...)
+}
+
+// Watch reloads the config whenever its file changes, as with the
+// package-level Watch.
+func (h *Handle[T]) Watch() error {
+	return Watch(h, h.opts...)
+}
+
+// ListenForSignals reloads the config on SIGHUP, as with the package-level
+// ListenForSignals.
+func (h *Handle[T]) ListenForSignals() error {
+	return ListenForSignals(h, h.opts...)
+}
+
+func (h *Handle[T]) GetFilename() string {
+	return h.filename
+}
+
+func (h *Handle[T]) SetFilename(filename string) {
+	h.filename = filename
+}
+
+func (h *Handle[T]) IsListening() bool {
+	return h.listening
+}
+
+func (h *Handle[T]) SetListening(listening bool) {
+	h.listening = listening
+}
+
+func (h *Handle[T]) Lock() {
+	h.mu.Lock()
+}
+
+func (h *Handle[T]) Unlock() {
+	h.mu.Unlock()
+}
+
+func (h *Handle[T]) RLock() {
+	h.mu.RLock()
+}
+
+func (h *Handle[T]) RUnlock() {
+	h.mu.RUnlock()
+}
+
+func (h *Handle[T]) target() interface{} {
+	return h.value.Load()
+}
+
+func (h *Handle[T]) replace(v interface{}) {
+	h.value.Store(v.(*T))
+}
+
+func (h *Handle[T]) snapshot() interface{} {
+	cp := &Handle[T]{filename: h.filename, opts: h.opts}
+	cp.value.Store(copyConfig(h.value.Load()).(*T))
+	return cp
+}
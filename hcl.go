@@ -0,0 +1,54 @@
+//go:build hcl
+
+package goconfig
+
+import "github.com/hashicorp/hcl"
+
+// FormatHCL is the format of HCL config files, which Load understands when
+// built with the hcl tag.
+const FormatHCL = "hcl"
+
+func init() {
+	RegisterDecoder(".hcl", decodeHCL)
+}
+
+// decodeHCL unmarshals an HCL document into v, using yaml struct tags. Blocks
+// become maps, those with labels nested by label, as in
+//
+//	upstream "api" {
+//	  url = "http://localhost:8080"
+//	}
+//
+// for upstream.api.url.
+func decodeHCL(data []byte, v interface{}) error {
+	doc := map[string]interface{}{}
+	if err := hcl.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return decodeTree(normalize(mergeHCLBlocks(doc)), v)
+}
+
+// mergeHCLBlocks replaces the lists of objects hcl decodes blocks into with
+// a single map, merging the blocks with the same key, the later ones taking
+// precedence.
+func mergeHCLBlocks(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			t[k] = mergeHCLBlocks(val)
+		}
+		return t
+	case []map[string]interface{}:
+		merged := map[string]interface{}{}
+		for _, block := range t {
+			merged = mergeTrees(merged, mergeHCLBlocks(block).(map[string]interface{}))
+		}
+		return merged
+	case []interface{}:
+		for i, val := range t {
+			t[i] = mergeHCLBlocks(val)
+		}
+		return t
+	}
+	return v
+}
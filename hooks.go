@@ -0,0 +1,64 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// DecodeHook converts a value for a field of the type it is registered for.
+// From environment variables, flags and `default` tags the value is a string;
+// from config files it is whatever the file held: a string, a number, a bool,
+// a []interface{} or a map[string]interface{}. The hook returns a value of the
+// registered type, or one convertible to it.
+type DecodeHook func(value interface{}) (interface{}, error)
+
+var (
+	decodeHooksMu sync.RWMutex
+	decodeHooks   = map[reflect.Type]DecodeHook{flagsType: decodeFlags}
+)
+
+// RegisterDecodeHook makes every source decode fields of the type of example,
+// and pointers, slices and maps of it, with hook, as in
+//
+//	goconfig.RegisterDecodeHook(Level(0), func(v interface{}) (interface{}, error) {
+//		return ParseLevel(fmt.Sprint(v))
+//	})
+//
+// The hook takes precedence over the type's own UnmarshalText or
+// UnmarshalYAML. Errors are reported with the key or variable of the field.
+// Save and Dump write such fields back as the type marshals itself, so the
+// hook should accept that form too. Registering a type again replaces its
+// hook.
+func RegisterDecodeHook(example interface{}, hook DecodeHook) {
+	decodeHooksMu.Lock()
+	defer decodeHooksMu.Unlock()
+	decodeHooks[reflect.TypeOf(example)] = hook
+	forgetTypes()
+}
+
+// decodeHookFor returns the hook registered for t, if any.
+func decodeHookFor(t reflect.Type) (DecodeHook, bool) {
+	decodeHooksMu.RLock()
+	defer decodeHooksMu.RUnlock()
+	hook, ok := decodeHooks[t]
+	return hook, ok
+}
+
+// runDecodeHook converts value with the hook for type t.
+func runDecodeHook(hook DecodeHook, t reflect.Type, value interface{}) (reflect.Value, error) {
+	result, err := hook(value)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	v := reflect.ValueOf(result)
+	switch {
+	case !v.IsValid():
+		return reflect.Zero(t), nil
+	case v.Type() == t:
+		return v, nil
+	case v.Type().ConvertibleTo(t):
+		return v.Convert(t), nil
+	}
+	return reflect.Value{}, fmt.Errorf("decode hook for %s returned a %s", t, v.Type())
+}
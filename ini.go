@@ -0,0 +1,264 @@
+package goconfig
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decodeINI unmarshals an INI document into v, using yaml struct tags.
+// Sections are nested keys, dotted section names nesting further, as in
+//
+//	[server.tls]
+//	enabled = true
+//
+// for server.tls.enabled. Keys ending in [] append to a list instead of
+// replacing the value.
+func decodeINI(data []byte, v interface{}) error {
+	tree := newINITree()
+	var section []string
+	for i, line := range strings.Split(string(bytes.TrimPrefix(data, utf8BOM)), "\n") {
+		lineNo := i + 1
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			name := strings.TrimSuffix(line[1:], "]")
+			if len(name) == len(line)-1 {
+				return &ParseError{Line: lineNo, Msg: fmt.Sprintf("section %s: expected a closing ]", line)}
+			}
+			section = splitINIPath(strings.TrimSpace(name))
+			if section == nil {
+				return &ParseError{Line: lineNo, Msg: "expected a section name"}
+			}
+			if _, err := tree.mapping(section, lineNo); err != nil {
+				return err
+			}
+			continue
+		}
+		sep := strings.IndexAny(line, "=:")
+		if sep < 0 {
+			return &ParseError{Line: lineNo, Msg: fmt.Sprintf("expected key = value, got %q", line)}
+		}
+		key := strings.TrimSpace(line[:sep])
+		value := &yaml.Node{Kind: yaml.ScalarNode, Value: strings.TrimSpace(line[sep+1:]), Line: lineNo}
+		if s := value.Value; len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+			value.Value, value.Tag = s[1:len(s)-1], "!!str"
+		}
+		if err := tree.set(append(section[:len(section):len(section)], key), value); err != nil {
+			return err
+		}
+	}
+	return tree.root.Decode(v)
+}
+
+// decodeProperties unmarshals a Java properties document into v, using yaml
+// struct tags. Dotted keys are nested, so server.port=8080 sets server.port,
+// and keys ending in [] append to a list instead of replacing the value. Later
+// values of a key replace earlier ones.
+func decodeProperties(data []byte, v interface{}) error {
+	tree := newINITree()
+	lines := strings.Split(string(bytes.TrimPrefix(data, utf8BOM)), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimLeft(strings.TrimSuffix(lines[i], "\r"), " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// A line ending in an odd number of backslashes goes on to the next,
+		// whose leading whitespace is dropped.
+		for continued(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(strings.TrimSuffix(lines[i], "\r"), " \t\f")
+		}
+		if continued(line) {
+			line = line[:len(line)-1]
+		}
+		key, value := splitProperty(line)
+		key, err := unescapeProperty(key)
+		if err == nil {
+			value, err = unescapeProperty(value)
+		}
+		if err != nil {
+			return &ParseError{Line: lineNo, Msg: err.Error()}
+		}
+		path := splitINIPath(key)
+		if path == nil {
+			return &ParseError{Line: lineNo, Msg: fmt.Sprintf("invalid key %q", key)}
+		}
+		if err := tree.set(path, &yaml.Node{Kind: yaml.ScalarNode, Value: value, Line: lineNo}); err != nil {
+			return err
+		}
+	}
+	return tree.root.Decode(v)
+}
+
+// utf8BOM is the byte order mark some editors start files with.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// continued reports whether line ends in an odd number of backslashes.
+func continued(line string) bool {
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+// splitProperty splits a properties line at the first unescaped =, : or
+// whitespace, along with the whitespace around it.
+func splitProperty(line string) (key, value string) {
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t', '\f':
+			rest := line[i+1:]
+			if c != '=' && c != ':' {
+				rest = strings.TrimLeft(rest, " \t\f")
+				if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+					rest = rest[1:]
+				}
+			}
+			return line[:i], strings.TrimLeft(rest, " \t\f")
+		}
+	}
+	return line, ""
+}
+
+// unescapeProperty replaces the escapes of a properties key or value: \t,
+// \n, \r, \f, \uXXXX, and a backslash before any other character, which
+// stands for that character.
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("invalid escape %q", s[i-1:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape %q", s[i-1:i+5])
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// splitINIPath splits a dotted section name or key into its parts, or returns
+// nil if one of them is empty.
+func splitINIPath(name string) []string {
+	path := strings.Split(name, ".")
+	for i, part := range path {
+		if path[i] = strings.TrimSpace(part); path[i] == "" {
+			return nil
+		}
+	}
+	return path
+}
+
+// iniTree is the document an INI or properties file is read into, as a yaml
+// node, so that its values are resolved as in YAML once decoded: 8080 is a
+// number and true a bool, unless decoded into a string.
+type iniTree struct {
+	root *yaml.Node
+}
+
+func newINITree() *iniTree {
+	return &iniTree{root: &yaml.Node{Kind: yaml.MappingNode}}
+}
+
+// mapping returns the mapping at path, adding the mappings missing along the
+// way.
+func (t *iniTree) mapping(path []string, line int) (*yaml.Node, error) {
+	node := t.root
+	for i, key := range path {
+		child := mappingValue(node, key)
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Line: line}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key, Line: line}, child)
+		} else if child.Kind != yaml.MappingNode {
+			return nil, &ParseError{Line: line, Msg: fmt.Sprintf("%s is both a value and a section", strings.Join(path[:i+1], "."))}
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// set sets the key at path to value, or appends value to its list if the key
+// ends in [].
+func (t *iniTree) set(path []string, value *yaml.Node) error {
+	key := path[len(path)-1]
+	if key == "" || key == "[]" {
+		return &ParseError{Line: value.Line, Msg: "expected a key"}
+	}
+	parent, err := t.mapping(path[:len(path)-1], value.Line)
+	if err != nil {
+		return err
+	}
+	name := strings.Join(path, ".")
+	key = strings.TrimSpace(key)
+	appending := strings.HasSuffix(key, "[]")
+	if appending {
+		key = strings.TrimSpace(strings.TrimSuffix(key, "[]"))
+		name = strings.TrimSuffix(name, "[]")
+	}
+	existing := mappingValue(parent, key)
+	if existing != nil && existing.Kind == yaml.MappingNode {
+		return &ParseError{Line: value.Line, Msg: fmt.Sprintf("%s is both a value and a section", name)}
+	}
+	if appending {
+		if existing == nil || existing.Kind != yaml.SequenceNode {
+			existing = &yaml.Node{Kind: yaml.SequenceNode, Line: value.Line}
+			setMappingValue(parent, key, existing)
+		}
+		existing.Content = append(existing.Content, value)
+		return nil
+	}
+	setMappingValue(parent, key, value)
+	return nil
+}
+
+// mappingValue returns the value of key in the mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets the value of key in the mapping node, adding the key
+// if it is missing.
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key, Line: value.Line}, value)
+}
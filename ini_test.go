@@ -0,0 +1,201 @@
+package goconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeINI(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string]interface{}
+	}{
+		{
+			name: "sections and values",
+			data: "name = app\n[server]\nport = 8080\nenabled = true\n[server.tls]\ncert = 'a.pem'\n",
+			want: map[string]interface{}{
+				"name":   "app",
+				"server": map[string]interface{}{"port": 8080, "enabled": true, "tls": map[string]interface{}{"cert": "a.pem"}},
+			},
+		},
+		{
+			name: "comments",
+			data: "; a comment\n# another\n[a]\n  ; indented\nkey = value ; kept\n",
+			want: map[string]interface{}{"a": map[string]interface{}{"key": "value ; kept"}},
+		},
+		{
+			name: "colon separator",
+			data: "[a]\nkey: value\nurl = http://x\n",
+			want: map[string]interface{}{"a": map[string]interface{}{"key": "value", "url": "http://x"}},
+		},
+		{
+			name: "duplicate sections are merged",
+			data: "[a]\nx = 1\ny = 2\n[b]\nz = 3\n[a]\ny = 4\n",
+			want: map[string]interface{}{"a": map[string]interface{}{"x": 1, "y": 4}, "b": map[string]interface{}{"z": 3}},
+		},
+		{
+			name: "lists",
+			data: "[a]\nhosts[] = x\nhosts[] = y\n",
+			want: map[string]interface{}{"a": map[string]interface{}{"hosts": []interface{}{"x", "y"}}},
+		},
+		{
+			name: "quoted values stay strings",
+			data: "port = \"8080\"\n",
+			want: map[string]interface{}{"port": "8080"},
+		},
+		{
+			name: "byte order mark and CRLF",
+			data: "\xef\xbb\xbf[a]\r\nkey = value\r\n",
+			want: map[string]interface{}{"a": map[string]interface{}{"key": "value"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]interface{}
+			if err := decodeINI([]byte(tt.data), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeINIErrors(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"unclosed section", "[a\nx = 1\n", "line 1: section [a: expected a closing ]"},
+		{"empty section", "[ ]\n", "line 1: expected a section name"},
+		{"missing separator", "[a]\nx\n", `line 2: expected key = value, got "x"`},
+		{"value and section", "a = 1\n[a]\n", "line 2: a is both a value and a section"},
+		{"section and value", "[a.b]\n[a]\nb = 1\n", "line 3: a.b is both a value and a section"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v map[string]interface{}
+			err := decodeINI([]byte(tt.data), &v)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("err = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeProperties(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string]interface{}
+	}{
+		{
+			name: "separators",
+			data: "a=1\nb = 2\nc:3\nd : 4\ne 5\nf\t \t6\ng\nh = x = y\n",
+			want: map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5, "f": 6, "g": nil, "h": "x = y"},
+		},
+		{
+			name: "comments",
+			data: "# a comment\n! another\n   # indented\nkey = value # kept\n",
+			want: map[string]interface{}{"key": "value # kept"},
+		},
+		{
+			name: "continuation lines",
+			data: "fruits = apple, \\\n         banana, \\\n   pear\nnext = 1\n",
+			want: map[string]interface{}{"fruits": "apple, banana, pear", "next": 1},
+		},
+		{
+			name: "escaped backslash is no continuation",
+			data: "path = c:\\\\\nnext = 1\n",
+			want: map[string]interface{}{"path": `c:\`, "next": 1},
+		},
+		{
+			name: "continuation at the end",
+			data: "key = value\\",
+			want: map[string]interface{}{"key": "value"},
+		},
+		{
+			name: "unicode escapes",
+			data: "greeting = caf\\u00e9 \\u2603\nk\\u0065y = x\n",
+			want: map[string]interface{}{"greeting": "café ☃", "key": "x"},
+		},
+		{
+			name: "escaped separators and whitespace",
+			data: "a\\=b = 1\nc\\:d:2\nkey\\ with\\ spaces = tab\\there\nnewline = a\\nb\n",
+			want: map[string]interface{}{"a=b": 1, "c:d": 2, "key with spaces": "tab\there", "newline": "a\nb"},
+		},
+		{
+			name: "nested keys and lists",
+			data: "server.port = 8080\nserver.host = x\nhosts[] = a\nhosts[] = b\n",
+			want: map[string]interface{}{"server": map[string]interface{}{"port": 8080, "host": "x"}, "hosts": []interface{}{"a", "b"}},
+		},
+		{
+			name: "later values replace earlier ones",
+			data: "a = 1\na = 2\n",
+			want: map[string]interface{}{"a": 2},
+		},
+		{
+			name: "CRLF",
+			data: "a = 1 \\\r\n  2\r\nb = 3\r\n",
+			want: map[string]interface{}{"a": "1 2", "b": 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]interface{}
+			if err := decodeProperties([]byte(tt.data), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodePropertiesErrors(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"short unicode escape", "a = \\u12", `line 1: invalid escape "\\u12"`},
+		{"bad unicode escape", "a = x\nb = \\uzzzz\n", `line 2: invalid escape "\\uzzzz"`},
+		{"empty key part", "a..b = 1\n", `line 1: invalid key "a..b"`},
+		{"value and section", "a = 1\na.b = 2\n", "line 2: a is both a value and a section"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v map[string]interface{}
+			err := decodeProperties([]byte(tt.data), &v)
+			if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadINIAndProperties(t *testing.T) {
+	var c struct {
+		Server struct {
+			Port int `yaml:"port"`
+		} `yaml:"server"`
+	}
+	if err := Load(&c, WithFile(writeConfigFile(t, "config.ini", "[server]\nport = 8080\n"))); err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Port != 8080 {
+		t.Errorf("ini port = %d", c.Server.Port)
+	}
+	if err := Load(&c, WithFile(writeConfigFile(t, "config.properties", "server.port: 9090\n"))); err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Port != 9090 {
+		t.Errorf("properties port = %d", c.Server.Port)
+	}
+	filename := writeConfigFile(t, "bad.ini", "[server\nport = 1\n")
+	err := Load(&c, WithFile(filename))
+	if err == nil || !strings.Contains(err.Error(), filename+":1: section [server: expected a closing ]") {
+		t.Errorf("err = %v", err)
+	}
+}
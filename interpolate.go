@@ -0,0 +1,287 @@
+package goconfig
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// interpolator resolves the ${key} references between the string values of a
+// config.
+type interpolator struct {
+	root reflect.Value
+	// expandable holds the keys of the strings whose references are
+	// resolved: those still holding the value a config document set.
+	expandable map[string]bool
+	// resolved holds the values resolved so far, and failed the keys whose
+	// values can't be, by key.
+	resolved map[string]string
+	failed   map[string]bool
+	// resolving holds the keys being resolved, in order, to report cycles.
+	resolving []string
+	errs      []error
+}
+
+// interpolate replaces the ${key} references in the string values of the
+// config pointed to by target with the values at those keys, dotted yaml key
+// paths as taken by Get, once every source has been applied. Only the values
+// doc set are expanded, so that those of environment variables, flags and
+// secret stores are kept as they are, as are the fields that are secret or
+// tagged with a resolver's tag. The values referred to may have references of
+// their own, as long as they don't form a cycle.
+func interpolate(target interface{}, doc *document) error {
+	if doc == nil {
+		return nil
+	}
+	raw := map[string]string{}
+	documentStrings(doc.tree, "", raw)
+	if len(raw) == 0 {
+		return nil
+	}
+	in := &interpolator{
+		root:       reflect.ValueOf(target),
+		expandable: map[string]bool{},
+		resolved:   map[string]string{},
+		failed:     map[string]bool{},
+	}
+	root := reflect.ValueOf(target).Elem()
+	walkStrings(root, "", func(v reflect.Value, key string) {
+		if s, ok := raw[key]; ok && s == v.String() {
+			in.expandable[key] = true
+		}
+	})
+	walkStrings(root, "", func(v reflect.Value, key string) {
+		if !in.expandable[key] {
+			return
+		}
+		if s, ok := in.value(key, v.String()); ok {
+			v.SetString(s)
+		}
+	})
+	return joinErrors(in.errs)
+}
+
+// documentStrings adds the strings of the document tree v, at key, that may
+// hold references to raw, by key.
+func documentStrings(v interface{}, key string, raw map[string]string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			documentStrings(item, joinPath(key, k), raw)
+		}
+	case []interface{}:
+		for i, item := range t {
+			documentStrings(item, joinPath(key, strconv.Itoa(i)), raw)
+		}
+	case string:
+		if strings.Contains(t, "$") {
+			raw[key] = t
+		}
+	}
+}
+
+// walkStrings calls fn with the settable strings held by v, the value at key,
+// and their keys. Secret fields and those tagged with a resolver's tag are
+// left out.
+func walkStrings(v reflect.Value, key string, fn func(v reflect.Value, key string)) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			walkStrings(v.Elem(), key, fn)
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		// The element of an interface can't be set, so a copy is walked and
+		// stored back.
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		walkStrings(elem, key, fn)
+		v.Set(elem)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+		for _, f := range fieldsOf(v.Type()) {
+			if f.skipped || f.Type == secretType || f.Tag.Get("secret") == "true" || isResolved(f.StructField) {
+				continue
+			}
+			walkStrings(v.FieldByIndex(f.Index), joinPath(key, f.key), fn)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkStrings(v.Index(i), joinPath(key, strconv.Itoa(i)), fn)
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			// Map elements can't be set either.
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(k))
+			walkStrings(elem, joinPath(key, k.String()), fn)
+			v.SetMapIndex(k, elem)
+		}
+	case reflect.String:
+		fn(v, key)
+	}
+}
+
+// value returns s, the string at key, with its references resolved if it is
+// expandable, or false if they can't be, in which case the reason has been
+// recorded.
+func (in *interpolator) value(key, s string) (string, bool) {
+	if !in.expandable[key] {
+		return s, true
+	}
+	if resolved, ok := in.resolved[key]; ok {
+		return resolved, true
+	}
+	if in.failed[key] {
+		return "", false
+	}
+	for i, k := range in.resolving {
+		if k == key {
+			cycle := append(in.resolving[i:len(in.resolving):len(in.resolving)], key)
+			in.errs = append(in.errs, fmt.Errorf("%s: interpolation cycle: %s", key, strings.Join(cycle, " -> ")))
+			for _, k := range cycle {
+				in.failed[k] = true
+			}
+			return "", false
+		}
+	}
+	in.resolving = append(in.resolving, key)
+	resolved, ok := in.expand(key, s)
+	in.resolving = in.resolving[:len(in.resolving)-1]
+	if !ok || in.failed[key] {
+		in.failed[key] = true
+		return "", false
+	}
+	in.resolved[key] = resolved
+	return resolved, true
+}
+
+// expand replaces the references in s, the string at key. ${key:-default}
+// stands for default if the value at key is missing or zero, and $$ for a
+// literal $.
+func (in *interpolator) expand(key, s string) (string, bool) {
+	var b strings.Builder
+	ok := true
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+			continue
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				break
+			}
+			value, found := in.ref(key, s[i+2:i+end])
+			ok = ok && found
+			b.WriteString(value)
+			i += end
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), ok
+}
+
+// ref returns the value of the reference ref, without its ${ }, found in the
+// string at key.
+func (in *interpolator) ref(key, ref string) (string, bool) {
+	name, def, hasDefault := strings.Cut(ref, ":-")
+	name = strings.TrimSpace(name)
+	v, ok := lookupPath(in.root, name)
+	if !ok || hasDefault && isZero(v) {
+		if hasDefault {
+			return def, true
+		}
+		in.errs = append(in.errs, fmt.Errorf("%s: ${%s}: no such key", key, name))
+		return "", false
+	}
+	if v.Kind() == reflect.String {
+		return in.value(name, v.String())
+	}
+	if v.CanInterface() {
+		if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+			text, err := m.MarshalText()
+			if err != nil {
+				in.errs = append(in.errs, fmt.Errorf("%s: ${%s}: %s", key, name, err))
+				return "", false
+			}
+			return string(text), true
+		}
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		in.errs = append(in.errs, fmt.Errorf("%s: ${%s}: expected a single value, got a %s", key, name, v.Kind()))
+		return "", false
+	}
+	return fmt.Sprint(v.Interface()), true
+}
+
+// isKeyOf reports whether key is a dotted yaml key path the struct type t, or
+// a pointer to it, has a value at, as interpolate resolves it.
+func isKeyOf(t reflect.Type, key string) bool {
+	for _, part := range strings.Split(key, ".") {
+		t = structType(t)
+		switch t.Kind() {
+		case reflect.Struct:
+			var ok bool
+			if t, ok = fieldTypeOf(t, part); !ok {
+				return false
+			}
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String {
+				return false
+			}
+			t = t.Elem()
+		case reflect.Slice, reflect.Array:
+			if _, err := strconv.Atoi(part); err != nil {
+				return false
+			}
+			t = t.Elem()
+		case reflect.Interface:
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// fieldTypeOf returns the type of the field of the struct type t with the
+// given yaml key, looking inside inlined structs, as lookupField does.
+func fieldTypeOf(t reflect.Type, key string) (reflect.Type, bool) {
+	for _, f := range fieldsOf(t) {
+		if f.skipped {
+			continue
+		}
+		if f.key == "" {
+			if inner := structType(f.Type); inner.Kind() == reflect.Struct {
+				if ft, ok := fieldTypeOf(inner, key); ok {
+					return ft, true
+				}
+			}
+			continue
+		}
+		if f.key == key {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
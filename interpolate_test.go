@@ -0,0 +1,166 @@
+package goconfig
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type interpolateConfig struct {
+	Server struct {
+		Host string `yaml:"host" env:"INTERPOLATE_HOST"`
+		Port int    `yaml:"port"`
+	} `yaml:"server"`
+	BaseURL  string            `yaml:"base_url"`
+	APIURL   string            `yaml:"api_url"`
+	Price    string            `yaml:"price"`
+	Password string            `yaml:"password" env:"INTERPOLATE_PASSWORD"`
+	Token    string            `yaml:"token" secret:"true"`
+	Key      Secret            `yaml:"key"`
+	Resolved string            `yaml:"resolved" interpolatetest:"ref"`
+	Links    map[string]string `yaml:"links"`
+	Mirrors  []string          `yaml:"mirrors"`
+	A        string            `yaml:"a"`
+	B        string            `yaml:"b"`
+	C        string            `yaml:"c"`
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestInterpolate(t *testing.T) {
+	RegisterResolver("interpolatetest", func(ctx context.Context, ref string) (string, error) {
+		return "${server.host}", nil
+	})
+	tests := []struct {
+		name  string
+		yaml  string
+		env   map[string]string
+		check func(c *interpolateConfig) string
+	}{
+		{
+			name: "references",
+			yaml: "server: {host: example.com, port: 8443}\nbase_url: https://${server.host}:${server.port}\napi_url: ${base_url}/api\nlinks: {docs: '${base_url}/docs'}\nmirrors: ['mirror.${server.host}']\n",
+			check: func(c *interpolateConfig) string {
+				if c.APIURL != "https://example.com:8443/api" {
+					return "api_url = " + c.APIURL
+				}
+				if c.Links["docs"] != "https://example.com:8443/docs" {
+					return "links.docs = " + c.Links["docs"]
+				}
+				if c.Mirrors[0] != "mirror.example.com" {
+					return "mirrors.0 = " + c.Mirrors[0]
+				}
+				return ""
+			},
+		},
+		{
+			name: "escapes and defaults",
+			yaml: "price: '$$5 ${a:-none} ${nope:-x}'\n",
+			check: func(c *interpolateConfig) string {
+				if c.Price != "$5 none x" {
+					return "price = " + c.Price
+				}
+				return ""
+			},
+		},
+		{
+			name: "environment values are referred to but not expanded",
+			yaml: "server: {host: file}\nbase_url: https://${server.host}\npassword: x\n",
+			env:  map[string]string{"INTERPOLATE_HOST": "env.example.com", "INTERPOLATE_PASSWORD": "pa$$word${x}"},
+			check: func(c *interpolateConfig) string {
+				if c.BaseURL != "https://env.example.com" {
+					return "base_url = " + c.BaseURL
+				}
+				if c.Password != "pa$$word${x}" {
+					return "password = " + c.Password
+				}
+				return ""
+			},
+		},
+		{
+			name: "secret and resolved fields are not expanded",
+			yaml: "server: {host: example.com}\ntoken: '${server.host}$$'\nkey: '${nope}'\n",
+			check: func(c *interpolateConfig) string {
+				if c.Token != "${server.host}$$" {
+					return "token = " + c.Token
+				}
+				if c.Key.Value() != "${nope}" {
+					return "key = " + c.Key.Value()
+				}
+				if c.Resolved != "${server.host}" {
+					return "resolved = " + c.Resolved
+				}
+				return ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			var c interpolateConfig
+			if err := Load(&c, WithFile(writeConfigFile(t, "config.yaml", tt.yaml)), Interpolate()); err != nil {
+				t.Fatal(err)
+			}
+			if msg := tt.check(&c); msg != "" {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{"missing key", "a: ${nope}\n", []string{"a: ${nope}: no such key"}},
+		{"cycle", "a: ${b}\nb: ${c}\nc: ${a}\n", []string{"a: interpolation cycle: a -> b -> c -> a"}},
+		{"self reference", "a: x${a}\n", []string{"a: interpolation cycle: a -> a"}},
+		{"not a value", "a: ${server}\n", []string{"a: ${server}: expected a single value, got a struct"}},
+		{"reported once", "a: ${nope}\nb: ${a}\nc: ${a}\n", []string{"a: ${nope}: no such key"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c interpolateConfig
+			err := Load(&c, WithFile(writeConfigFile(t, "config.yaml", tt.yaml)), Interpolate())
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if n := strings.Count(err.Error(), want); n != 1 {
+					t.Errorf("error %q has %q %d times, want once", err, want, n)
+				}
+			}
+			if c.A != "" {
+				t.Errorf("config modified: a = %q", c.A)
+			}
+		})
+	}
+}
+
+func TestInterpolateWithExpandEnv(t *testing.T) {
+	t.Setenv("INTERPOLATE_SCHEME", "https")
+	var c interpolateConfig
+	yaml := "server: {host: example.com}\nbase_url: ${INTERPOLATE_SCHEME}://${server.host}\nprice: $$5\n"
+	if err := Load(&c, WithFile(writeConfigFile(t, "config.yaml", yaml)), Interpolate(), ExpandEnv()); err != nil {
+		t.Fatal(err)
+	}
+	if c.BaseURL != "https://example.com" {
+		t.Errorf("base_url = %q", c.BaseURL)
+	}
+	if c.Price != "$5" {
+		t.Errorf("price = %q", c.Price)
+	}
+}
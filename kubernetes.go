@@ -0,0 +1,321 @@
+package goconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config URLs such as configmap://namespace/name/key and
+// secret://namespace/name/key are read from the Kubernetes API, with no volume
+// mount. The key names a data entry holding the document; without one, the
+// data entries themselves become top-level config keys. An empty namespace,
+// as in configmap:///name/key, stands for the pod's own.
+
+// serviceAccountDir holds the credentials Kubernetes mounts into pods.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Kubernetes configures how goconfig talks to the Kubernetes API, for
+// configmap:// and secret:// config URLs. If left empty, the in-cluster
+// service account is used.
+type Kubernetes struct {
+	// Host is the URL of the API server, e.g. "http://127.0.0.1:8001" for
+	// `kubectl proxy`.
+	Host string
+	// Token returns the bearer token to authenticate with, if any.
+	Token func(ctx context.Context) (string, error)
+	// Client makes the requests, one trusting the service account's CA if
+	// nil.
+	Client *http.Client
+}
+
+// WithKubernetes configures how goconfig talks to the Kubernetes API, rather
+// than as the pod's service account.
+func WithKubernetes(k Kubernetes) Option {
+	return func(o *options) {
+		o.kubernetes = &k
+	}
+}
+
+var (
+	// inClusterOnce sets up inClusterClient.
+	inClusterOnce   sync.Once
+	inClusterClient *http.Client
+	inClusterErr    error
+)
+
+// kubernetesSettings returns the Kubernetes settings of o with their empty
+// fields filled in from the pod's service account.
+func kubernetesSettings(o *options) (Kubernetes, error) {
+	k := Kubernetes{}
+	if o.kubernetes != nil {
+		k = *o.kubernetes
+	}
+	if k.Host == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return k, errors.New("not running in a Kubernetes cluster: give WithKubernetes a Host")
+		}
+		k.Host = "https://" + net.JoinHostPort(host, port)
+		if k.Token == nil {
+			k.Token = serviceAccountToken
+		}
+	}
+	k.Host = strings.TrimSuffix(k.Host, "/")
+	if k.Client == nil {
+		inClusterOnce.Do(func() {
+			inClusterClient, inClusterErr = serviceAccountClient()
+		})
+		if inClusterErr != nil {
+			return k, inClusterErr
+		}
+		k.Client = inClusterClient
+	}
+	return k, nil
+}
+
+// serviceAccountToken reads the pod's service account token. It is read on
+// every request, as the kubelet rotates it.
+func serviceAccountToken(context.Context) (string, error) {
+	data, err := os.ReadFile(serviceAccountDir + "/token")
+	return strings.TrimSpace(string(data)), err
+}
+
+// serviceAccountClient returns a client trusting the cluster's CA, or the
+// system roots outside of a pod.
+func serviceAccountClient() (*http.Client, error) {
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultHTTPClient, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid service account CA certificate")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Timeout: defaultHTTPClient.Timeout, Transport: transport}, nil
+}
+
+// kubernetesObject locates a ConfigMap or Secret and one of its keys.
+type kubernetesObject struct {
+	// resource is "configmaps" or "secrets".
+	resource  string
+	namespace string
+	name      string
+	key       string
+}
+
+func parseKubernetesURL(u *url.URL) (kubernetesObject, error) {
+	obj := kubernetesObject{resource: "configmaps", namespace: u.Host}
+	if u.Scheme == "secret" {
+		obj.resource = "secrets"
+	}
+	obj.name, obj.key, _ = strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if obj.name == "" {
+		return obj, fmt.Errorf("expected %s://namespace/name[/key]", u.Scheme)
+	}
+	if obj.namespace == "" {
+		data, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return obj, fmt.Errorf("no namespace given: %w", err)
+		}
+		obj.namespace = strings.TrimSpace(string(data))
+	}
+	return obj, nil
+}
+
+// kubernetesData is the part of a ConfigMap or Secret holding its data.
+type kubernetesData struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data       map[string]string `json:"data"`
+	BinaryData map[string]string `json:"binaryData"`
+}
+
+// values returns the data of the object, decoding base64 where needed.
+func (d kubernetesData) values(secret bool) (map[string]string, error) {
+	values := map[string]string{}
+	for k, v := range d.Data {
+		if secret {
+			decoded, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", k, err)
+			}
+			v = string(decoded)
+		}
+		values[k] = v
+	}
+	for k, v := range d.BinaryData {
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", k, err)
+		}
+		values[k] = string(decoded)
+	}
+	return values, nil
+}
+
+var (
+	// kubernetesVersionsMu guards kubernetesVersions.
+	kubernetesVersionsMu sync.Mutex
+	// kubernetesVersions holds the resource version each Kubernetes URL was
+	// last fetched at, for watches to start from.
+	kubernetesVersions = map[string]string{}
+)
+
+// fetchKubernetes fetches the ConfigMap or Secret u refers to.
+func fetchKubernetes(ctx context.Context, u *url.URL, o *options) ([]byte, error) {
+	obj, err := parseKubernetesURL(u)
+	if err != nil {
+		return nil, err
+	}
+	k, err := kubernetesSettings(o)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := k.get(ctx, k.Client, "/api/v1/namespaces/"+obj.namespace+"/"+obj.resource+"/"+obj.name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var d kubernetesData
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, err
+	}
+	kubernetesVersionsMu.Lock()
+	kubernetesVersions[u.String()] = d.Metadata.ResourceVersion
+	kubernetesVersionsMu.Unlock()
+	values, err := d.values(obj.resource == "secrets")
+	if err != nil {
+		return nil, err
+	}
+	if obj.key != "" {
+		value, ok := values[obj.key]
+		if !ok {
+			return nil, missingError(fmt.Sprintf("no key %q in %s %s/%s", obj.key, obj.resource, obj.namespace, obj.name))
+		}
+		return []byte(value), nil
+	}
+	tree := map[string]interface{}{}
+	for k, v := range values {
+		tree[k] = resolveScalar(v)
+	}
+	return yaml.Marshal(tree)
+}
+
+// watchKubernetes calls changed whenever the ConfigMap or Secret u refers to
+// changes after the version it was last fetched at, until ctx is done or the
+// watch ends.
+func watchKubernetes(ctx context.Context, u *url.URL, o *options, changed func()) error {
+	obj, err := parseKubernetesURL(u)
+	if err != nil {
+		return err
+	}
+	k, err := kubernetesSettings(o)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"watch": {"true"}, "fieldSelector": {"metadata.name=" + obj.name}}
+	kubernetesVersionsMu.Lock()
+	if version := kubernetesVersions[u.String()]; version != "" {
+		query.Set("resourceVersion", version)
+	}
+	kubernetesVersionsMu.Unlock()
+	path := "/api/v1/namespaces/" + obj.namespace + "/" + obj.resource + "?" + query.Encode()
+	resp, err := k.get(ctx, streamingClient(k.Client), path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	d := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string `json:"type"`
+			Object struct {
+				Metadata struct {
+					ResourceVersion string `json:"resourceVersion"`
+				} `json:"metadata"`
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"object"`
+		}
+		if err := d.Decode(&event); err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				// The API server ends watches after a while; the
+				// caller starts a new one.
+				return nil
+			}
+			return err
+		}
+		switch event.Type {
+		case "ADDED", "MODIFIED", "DELETED":
+			kubernetesVersionsMu.Lock()
+			kubernetesVersions[u.String()] = event.Object.Metadata.ResourceVersion
+			kubernetesVersionsMu.Unlock()
+			changed()
+		case "ERROR":
+			if event.Object.Code == http.StatusGone {
+				// The version is too old to watch from, so start over
+				// from a fresh load.
+				kubernetesVersionsMu.Lock()
+				delete(kubernetesVersions, u.String())
+				kubernetesVersionsMu.Unlock()
+				changed()
+			}
+			return fmt.Errorf("kubernetes watch: %s", event.Object.Message)
+		}
+	}
+}
+
+// get makes an authenticated GET request to the API server. A 404 gives an
+// error matching fs.ErrNotExist.
+func (k Kubernetes) get(ctx context.Context, client *http.Client, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.Host+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if k.Token != nil {
+		token, err := k.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var status struct {
+		Message string `json:"message"`
+	}
+	msg := resp.Status
+	if data, _ := io.ReadAll(resp.Body); json.Unmarshal(data, &status) == nil && status.Message != "" {
+		msg = status.Message
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, missingError("kubernetes: " + msg)
+	}
+	return nil, fmt.Errorf("kubernetes: %s", msg)
+}
@@ -0,0 +1,142 @@
+package goconfig
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Level is a log level given in config as "debug", "info", "warn" or "error",
+// regardless of case. "verbose" and "warning" are accepted too, as for the
+// Debug field of Config. Levels order and convert like slog levels, and the
+// zero Level is LevelInfo. Any other value fails the load with its key.
+type Level int32
+
+// Log levels, with the values of the slog levels.
+const (
+	LevelDebug Level = Level(slog.LevelDebug)
+	LevelInfo  Level = Level(slog.LevelInfo)
+	LevelWarn  Level = Level(slog.LevelWarn)
+	LevelError Level = Level(slog.LevelError)
+)
+
+var levelType = reflect.TypeOf(Level(0))
+
+// levelNames are the names of the levels, canonical ones first.
+var levelNames = []struct {
+	name  string
+	level Level
+}{
+	{"debug", LevelDebug}, {"info", LevelInfo}, {"warn", LevelWarn}, {"error", LevelError},
+	{"verbose", LevelDebug}, {"warning", LevelWarn},
+}
+
+// ParseLevel parses a level name such as "info" or "WARN".
+func ParseLevel(s string) (Level, error) {
+	for _, n := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(s), n.name) {
+			return n.level, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", s)
+}
+
+// String returns the name of l, or its slog name for levels in between.
+func (l Level) String() string {
+	for _, n := range levelNames {
+		if n.level == l {
+			return n.name
+		}
+	}
+	return strings.ToLower(slog.Level(l).String())
+}
+
+// Level returns l as a slog level, making it a slog.Leveler.
+func (l Level) Level() slog.Level {
+	return slog.Level(l)
+}
+
+// MarshalText formats l as String does.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses a level as ParseLevel does.
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// LevelVar holds a Level that can be read and changed safely while it is in
+// use, and tells the functions registered with OnChange when it changes. Kept
+// in step with a Level field of a config by WithLevelVar, it carries a
+// reloaded log level to the loggers using it: slog handlers can take it as
+// their Leveler and other loggers, such as zap's AtomicLevel or logrus, can
+// be set from OnChange. The zero LevelVar holds LevelInfo.
+type LevelVar struct {
+	level atomic.Int32
+
+	mu        sync.Mutex
+	listeners []func(Level)
+}
+
+// Get returns the level.
+func (v *LevelVar) Get() Level {
+	return Level(v.level.Load())
+}
+
+// Level returns the level as a slog level, making v a slog.Leveler.
+func (v *LevelVar) Level() slog.Level {
+	return slog.Level(v.Get())
+}
+
+// Set changes the level to l, calling the OnChange functions if it differs.
+func (v *LevelVar) Set(l Level) {
+	if Level(v.level.Swap(int32(l))) == l {
+		return
+	}
+	v.mu.Lock()
+	listeners := append([]func(Level){}, v.listeners...)
+	v.mu.Unlock()
+	for _, fn := range listeners {
+		fn(l)
+	}
+}
+
+// OnChange registers fn to be called with the new level whenever it changes.
+func (v *LevelVar) OnChange(fn func(Level)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.listeners = append(v.listeners, fn)
+}
+
+// WithLevelVar sets v, after each successful Load or reload, to the Level
+// field of the config at the dotted yaml key path key, as in
+//
+//	var level goconfig.LevelVar
+//	level.OnChange(func(l goconfig.Level) { zapLevel.SetLevel(zapcore.Level(l / 4)) })
+//	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: &level})
+//	goconfig.ListenForSignals(config, goconfig.WithLevelVar(&level, "log.level"))
+func WithLevelVar(v *LevelVar, key string) Option {
+	return func(o *options) {
+		o.stores = append(o.stores, func(c interface{}) {
+			field := reflect.ValueOf(c)
+			for _, part := range strings.Split(key, ".") {
+				var ok bool
+				if field, ok = lookupKey(field, part); !ok {
+					return
+				}
+			}
+			if field, ok := indirectValue(field); ok && field.Type() == levelType {
+				v.Set(Level(field.Int()))
+			}
+		})
+	}
+}
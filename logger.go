@@ -0,0 +1,73 @@
+package goconfig
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger receives goconfig's messages about its own work: reloads starting,
+// succeeding and failing, config files that weren't found and warnings. The
+// arguments after the message are alternating keys and values, as for
+// log/slog, whose *slog.Logger is a Logger.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// WithLogger sends goconfig's messages to l, e.g. slog.Default(). Without it,
+// warnings and errors go to the standard log package and the rest is dropped.
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// log returns the logger to send messages to.
+func (o *options) log() Logger {
+	if o.logger != nil {
+		return o.logger
+	}
+	return stdLogger{}
+}
+
+// stdLogger logs warnings and errors with the log package, as in
+// "config file warning: <error> file=config.yaml".
+type stdLogger struct{}
+
+func (stdLogger) Debug(msg string, args ...interface{}) {}
+
+func (stdLogger) Info(msg string, args ...interface{}) {}
+
+func (stdLogger) Warn(msg string, args ...interface{}) {
+	log.Print(formatLogLine(msg, args))
+}
+
+func (stdLogger) Error(msg string, args ...interface{}) {
+	log.Print(formatLogLine(msg, args))
+}
+
+// formatLogLine formats a message and its arguments on one line, with the
+// value of an "error" argument after a colon and the others as key=value.
+func formatLogLine(msg string, args []interface{}) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	var attrs []string
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			attrs = append(attrs, fmt.Sprint(args[i]))
+			break
+		}
+		if args[i] == "error" {
+			fmt.Fprintf(&b, ": %v", args[i+1])
+			continue
+		}
+		attrs = append(attrs, fmt.Sprintf("%v=%v", args[i], args[i+1]))
+	}
+	if attrs != nil {
+		b.WriteString(" " + strings.Join(attrs, " "))
+	}
+	return b.String()
+}
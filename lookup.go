@@ -0,0 +1,191 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Get returns the value at key, a dotted path of yaml keys such as
+// "plugins.cache.size", in the loaded config c, or nil if there is nothing
+// there. Map keys and slice indexes are path elements too, as in
+// "upstreams.0.url". This is for code that picks keys at runtime, e.g. plugins
+// reading sections the config struct holds as a map; the value returned is a
+// copy, so it can be kept and modified freely.
+func Get(c interface{}, key string) interface{} {
+	v, ok := lookup(c, key)
+	if !ok {
+		return nil
+	}
+	return v.Interface()
+}
+
+// IsSet reports whether key names a value in c that isn't the zero value.
+func IsSet(c interface{}, key string) bool {
+	v, ok := lookup(c, key)
+	return ok && !isZero(v)
+}
+
+// GetString returns the value at key as a string, or "" if there is none.
+func GetString(c interface{}, key string) string {
+	v, ok := lookup(c, key)
+	if !ok {
+		return ""
+	}
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// GetInt returns the value at key as an int, or 0 if there is none or it isn't
+// a number.
+func GetInt(c interface{}, key string) int {
+	v, ok := lookup(c, key)
+	if !ok {
+		return 0
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int(v.Float())
+	case reflect.String:
+		i, _ := strconv.ParseInt(strings.TrimSpace(v.String()), 10, 0)
+		return int(i)
+	}
+	return 0
+}
+
+// GetBool returns the value at key as a bool, or false if there is none or it
+// isn't a bool.
+func GetBool(c interface{}, key string) bool {
+	v, ok := lookup(c, key)
+	if !ok {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String:
+		b, _ := strconv.ParseBool(strings.TrimSpace(v.String()))
+		return b
+	}
+	return false
+}
+
+// GetDuration returns the value at key as a duration, or 0 if there is none or
+// it isn't one. Strings are parsed with time.ParseDuration, and plain numbers
+// are taken as nanoseconds, like time.Duration itself.
+func GetDuration(c interface{}, key string) time.Duration {
+	v, ok := lookup(c, key)
+	if !ok {
+		return 0
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return time.Duration(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return time.Duration(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return time.Duration(v.Float())
+	case reflect.String:
+		d, _ := time.ParseDuration(strings.TrimSpace(v.String()))
+		return d
+	}
+	return 0
+}
+
+// lookup finds the value at key in c and returns a copy of it.
+func lookup(config interface{}, key string) (reflect.Value, bool) {
+	c := configOf(config)
+	if checkPointer(c) != nil {
+		return reflect.Value{}, false
+	}
+	c.RLock()
+	defer c.RUnlock()
+	v, ok := lookupPath(reflect.ValueOf(configTarget(c)), key)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return deepCopy(v), true
+}
+
+// lookupPath returns the value at key, a dotted path of yaml keys, below v,
+// following pointers.
+func lookupPath(v reflect.Value, key string) (reflect.Value, bool) {
+	if key != "" {
+		for _, part := range strings.Split(key, ".") {
+			var ok bool
+			if v, ok = lookupKey(v, part); !ok {
+				return reflect.Value{}, false
+			}
+		}
+	}
+	return indirectValue(v)
+}
+
+// lookupKey returns the element of v at key: a struct field with that yaml
+// key, a map entry or a slice element.
+func lookupKey(v reflect.Value, key string) (reflect.Value, bool) {
+	v, ok := indirectValue(v)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return lookupField(v, key)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, false
+		}
+		elem := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+		return elem, elem.IsValid()
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= v.Len() {
+			return reflect.Value{}, false
+		}
+		return v.Index(i), true
+	}
+	return reflect.Value{}, false
+}
+
+// lookupField returns the exported field of the struct v with the given yaml
+// key, looking inside inlined structs.
+func lookupField(v reflect.Value, key string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if !exported(sf) || sf.Tag.Get("yaml") == "-" {
+			continue
+		}
+		name := yamlKey(sf)
+		if name == "" {
+			if inner, ok := indirectValue(v.Field(i)); ok && inner.Kind() == reflect.Struct {
+				if field, ok := lookupField(inner, key); ok {
+					return field, true
+				}
+			}
+			continue
+		}
+		if name == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// indirectValue follows pointers and interfaces, reporting false on nil.
+func indirectValue(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	return v, v.IsValid()
+}
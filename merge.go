@@ -0,0 +1,217 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Merge strategies, as given in `merge` tags.
+const (
+	// mergeReplace makes a later source replace a map or slice whole.
+	mergeReplace = "replace"
+	// mergeDeep merges maps key by key, the default for maps.
+	mergeDeep = "deep"
+	// mergeAppend appends the elements of a later source to a slice.
+	mergeAppend = "append"
+	// mergeUnique appends the elements that a slice doesn't hold yet.
+	mergeUnique = "unique"
+)
+
+// mergeRules are the merge strategies of the fields of a config struct, laid
+// out like the document trees they merge. A nil *mergeRules has none.
+type mergeRules struct {
+	strategy string
+	// keys holds the rules of the fields below, by key.
+	keys map[string]*mergeRules
+	// entries holds the rules of the entries of a map.
+	entries *mergeRules
+}
+
+// child returns the rules for the value at key below r.
+func (r *mergeRules) child(key string) *mergeRules {
+	if r == nil {
+		return nil
+	}
+	if child, ok := r.keys[key]; ok {
+		return child
+	}
+	return r.entries
+}
+
+// typeMergeRules holds the results of mergeRulesFor, by type.
+var typeMergeRules sync.Map
+
+type cachedMergeRules struct {
+	rules *mergeRules
+	err   error
+}
+
+// mergeRulesFor returns the merge strategies given by the `merge` tags of the
+// fields of the struct type t: "replace", "append" or "unique" for slices and
+// "deep" or "replace" for maps. They are worked out once per type.
+func mergeRulesFor(t reflect.Type) (*mergeRules, error) {
+	if cached, ok := typeMergeRules.Load(t); ok {
+		c := cached.(cachedMergeRules)
+		return c.rules, c.err
+	}
+	rules, err := mergeRulesOf(t, "", map[reflect.Type]bool{})
+	typeMergeRules.Store(t, cachedMergeRules{rules, err})
+	return rules, err
+}
+
+func mergeRulesOf(t reflect.Type, path string, seen map[reflect.Type]bool) (*mergeRules, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if seen[t] {
+		return nil, nil
+	}
+	switch {
+	case t.Kind() == reflect.Map:
+		entries, err := mergeRulesOf(t.Elem(), path+"[]", seen)
+		if err != nil || entries == nil {
+			return nil, err
+		}
+		return &mergeRules{entries: entries}, nil
+	case t.Kind() != reflect.Struct || parsesItself(t):
+		return nil, nil
+	}
+	seen[t] = true
+	defer delete(seen, t)
+	r := &mergeRules{keys: map[string]*mergeRules{}}
+	if err := r.addFields(t, path, seen); err != nil {
+		return nil, err
+	}
+	if len(r.keys) == 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// addFields adds the rules for the fields of the struct type t, flattening
+// inlined structs.
+func (r *mergeRules) addFields(t reflect.Type, path string, seen map[reflect.Type]bool) error {
+	fields := fieldsOf(t)
+	for i := range fields {
+		sf := &fields[i]
+		if sf.skipped {
+			continue
+		}
+		ft := structType(sf.Type)
+		key := sf.key
+		if key == "" && ft.Kind() == reflect.Struct {
+			if err := r.addFields(ft, path, seen); err != nil {
+				return err
+			}
+			continue
+		}
+		name := joinPath(path, sf.Name)
+		child, err := mergeRulesOf(ft, name, seen)
+		if err != nil {
+			return err
+		}
+		if strategy := sf.Tag.Get("merge"); strategy != "" {
+			if err := checkStrategy(strategy, ft); err != nil {
+				return fmt.Errorf("field %s: %s", name, err)
+			}
+			if child == nil {
+				child = &mergeRules{}
+			}
+			child.strategy = strategy
+		}
+		if child != nil {
+			r.keys[key] = child
+		}
+	}
+	return nil
+}
+
+// checkStrategy checks that strategy can merge values of type t.
+func checkStrategy(strategy string, t reflect.Type) error {
+	switch strategy {
+	case mergeReplace:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+			return nil
+		}
+	case mergeDeep:
+		if t.Kind() == reflect.Map {
+			return nil
+		}
+		return fmt.Errorf("merge strategy %q is only for maps", strategy)
+	case mergeAppend, mergeUnique:
+		if t.Kind() == reflect.Slice {
+			return nil
+		}
+		return fmt.Errorf("merge strategy %q is only for slices", strategy)
+	default:
+		return fmt.Errorf("unknown merge strategy %q", strategy)
+	}
+	return fmt.Errorf("merge strategy %q is only for maps and slices", strategy)
+}
+
+// mergeTrees deep-merges src into dst: nested maps are merged key by key while
+// any other value in src replaces the one in dst.
+func mergeTrees(dst, src map[string]interface{}) map[string]interface{} {
+	return mergeTreesWith(dst, src, nil)
+}
+
+// mergeTreesWith is mergeTrees with the strategies of rules.
+func mergeTreesWith(dst, src map[string]interface{}, rules *mergeRules) map[string]interface{} {
+	if dst == nil {
+		dst = map[string]interface{}{}
+	}
+	for k, v := range src {
+		r := rules.child(k)
+		strategy := ""
+		if r != nil {
+			strategy = r.strategy
+		}
+		if srcMap, ok := v.(map[string]interface{}); ok && strategy != mergeReplace {
+			if dstMap, ok := dst[k].(map[string]interface{}); ok {
+				dst[k] = mergeTreesWith(dstMap, srcMap, r)
+				continue
+			}
+		}
+		srcList, srcOk := v.([]interface{})
+		dstList, dstOk := dst[k].([]interface{})
+		if srcOk && dstOk && (strategy == mergeAppend || strategy == mergeUnique) {
+			merged := append([]interface{}{}, dstList...)
+			for _, item := range srcList {
+				if strategy == mergeUnique && containsValue(merged, item) {
+					continue
+				}
+				merged = append(merged, item)
+			}
+			dst[k] = merged
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// containsValue reports whether list holds a value equal to v.
+func containsValue(list []interface{}, v interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// setTreePath sets the value at path in a document tree, creating maps along
+// the way.
+func setTreePath(tree map[string]interface{}, path []string, value interface{}) {
+	node := tree
+	for _, part := range path[:len(path)-1] {
+		child, ok := node[part].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			node[part] = child
+		}
+		node = child
+	}
+	node[path[len(path)-1]] = value
+}
@@ -0,0 +1,122 @@
+package goconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// reloadDurationBuckets are the upper bounds, in seconds, of the buckets of
+// the reload duration histogram, as for the Prometheus client's defaults.
+var reloadDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics counts the loads and reloads of a config and serves them in the
+// Prometheus text format:
+//
+//	goconfig_reloads_total                     reloads attempted
+//	goconfig_reload_failures_total             reloads that failed
+//	goconfig_reload_duration_seconds           histogram of reload durations
+//	goconfig_last_success_timestamp_seconds    Unix time of the last successful load
+//	goconfig_config_info{hash="..."}           1, labelled with a hash of the config
+//
+// The hash is of the config as Dump writes it, so it changes with every
+// field except secrets. Comparing it across replicas shows config drift.
+type Metrics struct {
+	mu             sync.Mutex
+	reloads        uint64
+	reloadFailures uint64
+	buckets        []uint64
+	durationSum    float64
+	lastSuccess    time.Time
+	hash           string
+}
+
+// NewMetrics returns Metrics to pass to every Load and reload of one config
+// with WithMetrics, and to serve, e.g. on /metrics, as an http.Handler.
+func NewMetrics() *Metrics {
+	return &Metrics{buckets: make([]uint64, len(reloadDurationBuckets))}
+}
+
+// WithMetrics records loads and reloads in m.
+func WithMetrics(m *Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+		o.stores = append(o.stores, m.loaded)
+	}
+}
+
+// loaded records a successful load of c.
+func (m *Metrics) loaded(c interface{}) {
+	hash := configHash(c)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastSuccess = time.Now()
+	m.hash = hash
+}
+
+// reloaded records a reload that took d and failed with err, if not nil.
+func (m *Metrics) reloaded(d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reloads++
+	if err != nil {
+		m.reloadFailures++
+	}
+	seconds := d.Seconds()
+	m.durationSum += seconds
+	for i, bound := range reloadDurationBuckets {
+		if seconds <= bound {
+			m.buckets[i]++
+		}
+	}
+}
+
+// configHash returns a short hash of the config c, with secrets masked.
+func configHash(c interface{}) string {
+	data, err := marshalConfig(c, FormatYAML, true)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	var buf bytes.Buffer
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	metric("goconfig_reloads_total", "counter", "Config reloads attempted.")
+	fmt.Fprintf(&buf, "goconfig_reloads_total %d\n", m.reloads)
+	metric("goconfig_reload_failures_total", "counter", "Config reloads that failed.")
+	fmt.Fprintf(&buf, "goconfig_reload_failures_total %d\n", m.reloadFailures)
+	metric("goconfig_reload_duration_seconds", "histogram", "Time taken by config reloads.")
+	for i, bound := range reloadDurationBuckets {
+		fmt.Fprintf(&buf, "goconfig_reload_duration_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), m.buckets[i])
+	}
+	fmt.Fprintf(&buf, "goconfig_reload_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.reloads)
+	fmt.Fprintf(&buf, "goconfig_reload_duration_seconds_sum %g\n", m.durationSum)
+	fmt.Fprintf(&buf, "goconfig_reload_duration_seconds_count %d\n", m.reloads)
+	if !m.lastSuccess.IsZero() {
+		metric("goconfig_last_success_timestamp_seconds", "gauge", "Unix time of the last successful config load.")
+		fmt.Fprintf(&buf, "goconfig_last_success_timestamp_seconds %.3f\n", float64(m.lastSuccess.UnixNano())/1e9)
+		metric("goconfig_config_info", "gauge", "The hash of the loaded config.")
+		fmt.Fprintf(&buf, "goconfig_config_info{hash=%q} 1\n", m.hash)
+	}
+	m.mu.Unlock()
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
@@ -0,0 +1,147 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// versionKey is the top-level key holding the schema version of a document.
+const versionKey = "version"
+
+// Migration upgrades the tree of a config document from the version it is
+// registered for to the next one, editing it in place. The tree holds what the
+// file held: strings, numbers, bools, []interface{} and
+// map[string]interface{}.
+type Migration func(tree map[string]interface{}) error
+
+var (
+	migrationsMu sync.RWMutex
+	// migrations holds the migrations registered for each config struct
+	// type, by the version they upgrade from.
+	migrations = map[reflect.Type]map[int]Migration{}
+)
+
+// RegisterMigration registers migrate to upgrade the documents of configs of
+// the type of config, a struct or a pointer to one, from version from to
+// from+1, as in
+//
+//	goconfig.RegisterMigration(&Config{}, 1, func(tree map[string]interface{}) error {
+//		tree["listen"] = fmt.Sprintf(":%v", tree["port"])
+//		delete(tree, "port")
+//		return nil
+//	})
+//
+// Once a type has migrations, the version its documents are written for is
+// the one after the highest registered, and every file is expected to say
+// which version it holds under a top-level `version` key. A file without one
+// is taken to be version 1, from before the type had migrations. Older files
+// are upgraded one version at a time before they are decoded, with a warning
+// to update them; files newer than the current version are an error. Save
+// writes the current version.
+func RegisterMigration(config interface{}, from int, migrate Migration) {
+	t := structType(reflect.TypeOf(config))
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	if migrations[t] == nil {
+		migrations[t] = map[int]Migration{}
+	}
+	migrations[t][from] = migrate
+}
+
+// versionMigrations are the migrations of a config type.
+type versionMigrations struct {
+	// current is the version documents are written for.
+	current int
+	steps   map[int]Migration
+	// keep is set if the config has a field for the version key, which then
+	// gets the current version.
+	keep bool
+}
+
+// migrationsFor returns the migrations registered for the config type t,
+// or nil if there are none.
+func migrationsFor(t reflect.Type) *versionMigrations {
+	t = structType(t)
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	steps := migrations[t]
+	if len(steps) == 0 {
+		return nil
+	}
+	m := &versionMigrations{steps: make(map[int]Migration, len(steps))}
+	for from, migrate := range steps {
+		m.steps[from] = migrate
+		if from+1 > m.current {
+			m.current = from + 1
+		}
+	}
+	if t.Kind() == reflect.Struct {
+		_, m.keep = lookupField(reflect.New(t).Elem(), versionKey)
+	}
+	return m
+}
+
+// migrate upgrades the tree of doc, read from filename, to the current
+// version.
+func (m *versionMigrations) migrate(filename string, doc *document, o *options) error {
+	version, err := documentVersion(doc.tree[versionKey])
+	if err != nil {
+		return fileError(filename, err)
+	}
+	if version > m.current {
+		return fileError(filename, fmt.Errorf("%s %d is newer than the supported %d", versionKey, version, m.current))
+	}
+	if version < m.current {
+		o.log().Warn("config file migrated from an old version, update it", "file", filename, "version", version, "current", m.current)
+	}
+	for ; version < m.current; version++ {
+		migrate, ok := m.steps[version]
+		if !ok {
+			return fileError(filename, fmt.Errorf("no migration from %s %d", versionKey, version))
+		}
+		if err := migrate(doc.tree); err != nil {
+			return fileError(filename, fmt.Errorf("migrating from %s %d: %w", versionKey, version, err))
+		}
+	}
+	if m.keep {
+		doc.tree[versionKey] = m.current
+	} else {
+		delete(doc.tree, versionKey)
+	}
+	doc.merged = true
+	return nil
+}
+
+// documentVersion returns the version held by the version key of a document,
+// 1 if it has none.
+func documentVersion(v interface{}) (int, error) {
+	switch t := v.(type) {
+	case nil:
+		return 1, nil
+	case int:
+		return t, nil
+	case int64:
+		return int(t), nil
+	case uint64:
+		return int(t), nil
+	case float64:
+		if t == float64(int(t)) {
+			return int(t), nil
+		}
+	case string:
+		if i, err := strconv.Atoi(t); err == nil {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: expected a whole number, got %v", versionKey, v)
+}
+
+// structType returns t, or what it points to if it is a pointer.
+func structType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
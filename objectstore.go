@@ -0,0 +1,74 @@
+package goconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// fetchS3 fetches the object s3://bucket/key from S3, in the region and with
+// the credentials given with WithAWS or by the environment.
+func fetchS3(ctx context.Context, u *url.URL, o *options) ([]byte, error) {
+	a := awsSettings(o)
+	key := strings.TrimPrefix(u.Path, "/")
+	var endpoint string
+	if a.Endpoint != "" {
+		// Custom endpoints such as LocalStack and MinIO want path-style
+		// requests.
+		endpoint = strings.TrimSuffix(a.Endpoint, "/") + "/" + u.Host
+	} else if a.Region != "" {
+		endpoint = "https://" + u.Host + ".s3." + a.Region + ".amazonaws.com"
+	} else {
+		return nil, errors.New("no AWS region: set AWS_REGION, or give WithAWS a Region")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/"+s3Escape(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.do(req, nil, "s3")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, fs.ErrNotExist
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, fmt.Errorf("s3: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// s3Escape escapes an object key as S3 and Signature Version 4 expect:
+// everything but unreserved characters and slashes is percent-encoded.
+func s3Escape(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// fetchGCS fetches the object gs://bucket/object from Cloud Storage, with the
+// credentials given with WithGCP or Application Default Credentials.
+func fetchGCS(ctx context.Context, u *url.URL, o *options) ([]byte, error) {
+	object := strings.TrimPrefix(u.Path, "/")
+	endpoint := "https://storage.googleapis.com/storage/v1/b/" + url.PathEscape(u.Host) + "/o/" + url.PathEscape(object) + "?alt=media"
+	resp, err := gcpSettings(o).get(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,377 @@
+package goconfig
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"text/template"
+	"time"
+)
+
+// Option tunes a single call to Load.
+type Option func(*options)
+
+// Source is a place config values are loaded from.
+type Source int
+
+const (
+	// SourceFile is the config file, or whatever document Load was given.
+	SourceFile Source = iota
+	// SourceEnv is the environment variables named by env tags.
+	SourceEnv
+	// SourceFlags is the command-line flags named by flag tags, given with
+	// WithFlags.
+	SourceFlags
+	// SourceOverrides is the key=value overrides given with WithOverrides.
+	SourceOverrides
+	// SourceSecrets is the secret stores referred to by field tags, such as
+	// `vault` tags.
+	SourceSecrets
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	case SourceFlags:
+		return "flags"
+	case SourceOverrides:
+		return "overrides"
+	case SourceSecrets:
+		return "secrets"
+	}
+	return fmt.Sprintf("Source(%d)", int(s))
+}
+
+// defaultPrecedence lets secrets override the file, the environment override
+// both, so that secrets can be stubbed out locally, flags override all those
+// and explicit overrides everything.
+var defaultPrecedence = []Source{SourceFile, SourceSecrets, SourceEnv, SourceFlags, SourceOverrides}
+
+type options struct {
+	// format overrides the format detected from the filename extension.
+	format string
+	// files replaces the configured filename with a list of overlays.
+	files []string
+	// requiredFiles are the files that must exist, unless fileOptional.
+	requiredFiles map[string]bool
+	fileOptional  bool
+	// discoverDirs and discoverNames are searched for a config file when
+	// there is none.
+	discoverDirs  []string
+	discoverNames []string
+	// vault configures how vault tags are resolved.
+	vault *Vault
+	// aws configures how AWS services are called.
+	aws *AWS
+	// gcp configures how Google Cloud services are called.
+	gcp *GCP
+	// pollInterval and pollJitter tune the polling of remote configs.
+	pollInterval time.Duration
+	pollJitter   *float64
+	// kubernetes configures how the Kubernetes API is called.
+	kubernetes *Kubernetes
+	// httpClient fetches http and https config URLs.
+	httpClient *http.Client
+	// ageKeys are the age identities given with WithAgeKey.
+	ageKeys []string
+	// filePermissions, if set, checks the permissions of config files
+	// holding secrets.
+	filePermissions *FilePermissions
+	// profile selects a section of the config to overlay the default one.
+	profile string
+	// strict rejects keys that don't map to a struct field.
+	strict bool
+	// document selects a document of a multi-document YAML stream.
+	document int
+	// mergeDocuments merges all documents of a multi-document YAML stream.
+	mergeDocuments bool
+	// expandEnv expands ${VAR} references in string values.
+	expandEnv bool
+	// interpolate resolves ${key} references to other config values.
+	interpolate bool
+	// template, if set, preprocesses the raw config files.
+	template *templateOptions
+	// dotEnv lists dotenv files to load into the environment.
+	dotEnv []string
+	// env holds the variables of the dotEnv files, read by load.
+	env environment
+	// precedence lists the sources to load, lowest precedence first.
+	precedence []Source
+	skipEnv    bool
+	// flags are the parsed command-line flags to apply.
+	flags []flagSource
+	// overrides are key=value overrides to apply.
+	overrides []string
+	// envPrefix, if set, overrides the env prefix of the config.
+	envPrefix *string
+	// debounce, if set, overrides the settle window of background reloads.
+	debounce *time.Duration
+	// onReload are called after each successful background reload.
+	onReload []func(old, new interface{})
+	// signals trigger reloads by ListenForSignals instead of SIGHUP.
+	signals []os.Signal
+	// stores are published to after each successful Load, with the config
+	// locked.
+	stores []func(interface{})
+	// reloading is set when reloading a config that was already loaded.
+	reloading bool
+	// overriding is set by ApplyOverrides, which changes a config that was
+	// already loaded as a reload does, though it isn't counted as one.
+	overriding bool
+	// dynamicReload limits reloads to the fields tagged reload:"dynamic".
+	dynamicReload bool
+	// onError are called when a background reload fails.
+	onError []func(error)
+	// logger receives goconfig's own messages, if set.
+	logger Logger
+	// tracer traces loads, if set.
+	tracer Tracer
+	// metrics records loads and reloads, if set.
+	metrics *Metrics
+	// expvarName is the name config metadata is published under with expvar,
+	// if set.
+	expvarName string
+	// sections loads the sections registered with RegisterSection.
+	sections bool
+	// dryRun checks the config without modifying it, for Validate.
+	dryRun bool
+	// retry, if set, retries failed reads.
+	retry *Retry
+	// checksum is the checksum of the files read by Load, set by it.
+	checksum []byte
+	// unchanged, if set, is set by a reload skipped because the files read
+	// match the checksum of the last load.
+	unchanged *bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{precedence: defaultPrecedence}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithFormat forces the config file to be parsed as the given format ("yaml",
+// "json" or "toml") instead of guessing it from the filename extension.
+func WithFormat(format string) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// envPrefixFor returns the env prefix to use for c.
+func (o *options) envPrefixFor(c Configterface) string {
+	if o.envPrefix != nil {
+		return *o.envPrefix
+	}
+	return envPrefix(c)
+}
+
+// WithFile loads filename instead of the configured filename. Unlike the
+// configured filename, it is an error for it not to exist, unless FileOptional
+// is given too.
+func WithFile(filename string) Option {
+	return func(o *options) {
+		o.files = append(o.files, filename)
+		if o.requiredFiles == nil {
+			o.requiredFiles = map[string]bool{}
+		}
+		o.requiredFiles[filename] = true
+	}
+}
+
+// FileOptional lets a file given to WithFile be missing, in which case the
+// config is loaded from the other sources alone.
+func FileOptional() Option {
+	return func(o *options) {
+		o.fileOptional = true
+	}
+}
+
+// WithEnvPrefix overrides the env prefix of the config for this Load.
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.envPrefix = &prefix
+	}
+}
+
+// SkipEnv doesn't parse environment variables at all.
+func SkipEnv() Option {
+	return func(o *options) {
+		o.skipEnv = true
+	}
+}
+
+// WithFiles loads the given files in order instead of the configured filename,
+// deep-merging each one over the ones before it. Missing files are skipped.
+func WithFiles(filenames ...string) Option {
+	return func(o *options) {
+		o.files = append(o.files, filenames...)
+	}
+}
+
+// WithProfile treats the top-level keys of the config as profiles and loads
+// the named one merged over the "default" profile.
+func WithProfile(profile string) Option {
+	return func(o *options) {
+		o.profile = profile
+	}
+}
+
+// Strict makes Load fail if the config contains keys that don't map to any
+// struct field, such as misspelled options.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithDocument loads the document with the given zero-based index from YAML
+// files holding several documents separated by `---`, instead of the first.
+func WithDocument(index int) Option {
+	return func(o *options) {
+		o.document = index
+	}
+}
+
+// MergeDocuments deep-merges all documents of YAML files holding several
+// documents separated by `---`, in order, instead of loading only the first.
+func MergeDocuments() Option {
+	return func(o *options) {
+		o.mergeDocuments = true
+	}
+}
+
+// ExpandEnv expands ${VAR} and ${VAR:-default} references to environment
+// variables inside string values of the config before it is decoded.
+func ExpandEnv() Option {
+	return func(o *options) {
+		o.expandEnv = true
+	}
+}
+
+// Interpolate resolves ${key} references in the string values of the config to
+// the values at other keys, dotted yaml key paths such as "server.port", once
+// every source has been applied, so that derived values can't drift apart:
+//
+//	base_url: https://${server.host}:${server.port}/api
+//
+// ${key:-default} stands for default if the value at key is missing or zero,
+// and $$ for a literal $. A reference to a missing key, or a cycle of
+// references, fails the load. Only the values set by config files are
+// expanded; those of environment variables, flags, overrides and secret
+// stores, and the fields that are secret or tagged with a resolver's tag, are
+// kept as they are, though they can be referred to. With ExpandEnv,
+// references to keys of the config are left for Interpolate and the rest
+// expanded from the environment.
+func Interpolate() Option {
+	return func(o *options) {
+		o.interpolate = true
+	}
+}
+
+type templateOptions struct {
+	data  interface{}
+	funcs template.FuncMap
+}
+
+// execute runs the contents of the named file through text/template.
+func (t *templateOptions) execute(name string, data []byte) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(t.funcs).Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, t.data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WithTemplate runs the raw contents of each config file through text/template
+// before decoding it, executing it with data and the given functions.
+func WithTemplate(data interface{}, funcs template.FuncMap) Option {
+	return func(o *options) {
+		o.template = &templateOptions{data, funcs}
+	}
+}
+
+// WithDotEnv reads variables from the given dotenv files, or from .env in the
+// working directory if none are given, along with the environment. Variables
+// that are set in the environment take precedence and missing files are
+// skipped. The variables are only seen by the Load, for `env` tags and
+// ExpandEnv, and aren't set in the process, so settings goconfig reads from
+// the environment itself, such as VAULT_ADDR or AWS credentials, don't come
+// from them.
+func WithDotEnv(filenames ...string) Option {
+	return func(o *options) {
+		if len(filenames) == 0 {
+			filenames = []string{defaultDotEnv}
+		}
+		o.dotEnv = append(o.dotEnv, filenames...)
+	}
+}
+
+// WithPrecedence sets the sources to load, lowest precedence first, each one
+// overriding the values set by the ones before it. The default is
+// WithPrecedence(SourceFile, SourceSecrets, SourceEnv, SourceFlags,
+// SourceOverrides); treating the file as authoritative over the environment
+// takes WithPrecedence(SourceEnv, SourceFile, SourceSecrets, SourceFlags,
+// SourceOverrides). Sources left out aren't loaded.
+// Defaults from `default` tags always have the lowest precedence.
+func WithPrecedence(sources ...Source) Option {
+	return func(o *options) {
+		o.precedence = sources
+	}
+}
+
+// WithSignals makes ListenForSignals reload the config on the given signals,
+// e.g. syscall.SIGUSR1, instead of SIGHUP.
+func WithSignals(signals ...os.Signal) Option {
+	return func(o *options) {
+		o.signals = append(o.signals, signals...)
+	}
+}
+
+// WithDebounce sets how long ListenForSignals and Watch wait for things to
+// settle before reloading: a reload happens once no further signal or file
+// change has arrived for d. Watch waits 100ms by default and ListenForSignals
+// reloads straight away.
+func WithDebounce(d time.Duration) Option {
+	return func(o *options) {
+		o.debounce = &d
+	}
+}
+
+// OnReload registers fn to be called after each successful reload by
+// ListenForSignals or Watch, with a copy of the config as it was before the
+// reload and the reloaded config itself, as it was passed to them. The config
+// isn't locked while fn runs.
+func OnReload(fn func(old, new interface{})) Option {
+	return func(o *options) {
+		o.onReload = append(o.onReload, fn)
+	}
+}
+
+// OnError registers fn to be called when a reload by ListenForSignals or Watch
+// fails, instead of the error being logged. The config keeps its previous
+// values.
+func OnError(fn func(error)) Option {
+	return func(o *options) {
+		o.onError = append(o.onError, fn)
+	}
+}
+
+// DynamicReload makes reloads by ListenForSignals, Watch and Reload only apply
+// the fields tagged `reload:"dynamic"`, and structs holding them. Every other
+// field keeps the value it was first loaded with until the process restarts.
+func DynamicReload() Option {
+	return func(o *options) {
+		o.dynamicReload = true
+	}
+}
@@ -0,0 +1,77 @@
+package goconfig
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WithOverrides applies overrides of the form "server.port=9090", as passed to
+// Helm's --set, on top of every other source unless told otherwise with
+// WithPrecedence. Keys are dotted yaml key paths and values are parsed as YAML,
+// so "9090" is a number and "[a, b]" a list. Unlike config files, overrides of
+// keys that don't exist are an error.
+func WithOverrides(overrides ...string) Option {
+	return func(o *options) {
+		o.overrides = append(o.overrides, overrides...)
+	}
+}
+
+// ApplyOverrides applies overrides of the form "server.port=9090" to an
+// already loaded config, as with WithOverrides but without reloading anything
+// else. The result is validated as by Load, and c is left as it was if it
+// isn't valid or if the overrides change fields tagged `reload:"forbidden"`,
+// which fails with ForbiddenChanges as a reload would.
+func ApplyOverrides(config interface{}, overrides []string) error {
+	c := configOf(config)
+	if err := checkPointer(c); err != nil {
+		return err
+	}
+	o := newOptions([]Option{WithPrecedence(SourceOverrides), WithOverrides(overrides...)})
+	o.overriding = true
+	return load(context.Background(), c, nil, o)
+}
+
+// overrideTree turns overrides into a document tree.
+func overrideTree(overrides []string) (map[string]interface{}, error) {
+	tree := map[string]interface{}{}
+	for _, override := range overrides {
+		key, raw, ok := strings.Cut(override, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("override %q: expected key=value", override)
+		}
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, fmt.Errorf("override %q: %s", override, err)
+		}
+		if value == nil && raw != "null" && raw != "~" {
+			value = raw
+		}
+		setTreePath(tree, strings.Split(key, "."), normalize(value))
+	}
+	return tree, nil
+}
+
+// applyOverrides decodes overrides into the struct pointed to by val. Keys
+// that don't map to a field are an error.
+func applyOverrides(val interface{}, overrides []string) error {
+	tree, err := overrideTree(overrides)
+	if err != nil {
+		return err
+	}
+	prepared, err := prepareTree(tree, reflect.TypeOf(val))
+	if err != nil {
+		return fmt.Errorf("overrides: %s", err)
+	}
+	if err := allocateSections(tree, reflect.ValueOf(val).Elem(), fieldPath{}); err != nil {
+		return err
+	}
+	if err := decodeTreeWith(tree, val, true); err != nil {
+		return fmt.Errorf("overrides: %s", err)
+	}
+	prepared.apply(val)
+	return nil
+}
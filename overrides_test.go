@@ -0,0 +1,33 @@
+package goconfig
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyOverridesForbiddenChanges(t *testing.T) {
+	var c struct {
+		Listen string `yaml:"listen" reload:"forbidden"`
+		Port   int    `yaml:"port"`
+	}
+	if err := Load(&c, WithFile(writeConfigFile(t, "config.yaml", "listen: ':80'\nport: 1\n"))); err != nil {
+		t.Fatal(err)
+	}
+	err := ApplyOverrides(&c, []string{"listen=:81", "port=2"})
+	var forbidden ForbiddenChanges
+	if !errors.As(err, &forbidden) {
+		t.Fatalf("err = %v, want ForbiddenChanges", err)
+	}
+	if len(forbidden.Changes) != 1 || forbidden.Changes[0].Path != "Listen" {
+		t.Errorf("changes = %v", forbidden.Changes)
+	}
+	if c.Listen != ":80" || c.Port != 1 {
+		t.Errorf("config = %q, %d after a rejected override", c.Listen, c.Port)
+	}
+	if err := ApplyOverrides(&c, []string{"listen=:80", "port=2"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 2 {
+		t.Errorf("port = %d", c.Port)
+	}
+}
@@ -0,0 +1,104 @@
+package goconfig
+
+import (
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+)
+
+// FilePermissions configures the check CheckFilePermissions makes of config
+// files.
+type FilePermissions struct {
+	// Warn logs problems instead of failing Load.
+	Warn bool
+	// Owners are the user IDs allowed to own config files, by default the
+	// user running the process and root.
+	Owners []int
+}
+
+// CheckFilePermissions makes Load refuse config files that others than their
+// owner can write, that anyone can read, or that are owned by another user
+// than those allowed, as SSH does for key files. Only configs with secret
+// fields, tagged `secret:"true"` or of type Secret, are checked, and only
+// local files. Windows permissions aren't checked.
+func CheckFilePermissions(p FilePermissions) Option {
+	return func(o *options) {
+		o.filePermissions = &p
+	}
+}
+
+// check checks the permissions of the config file filename, whose info is
+// info, returning an error for a problem unless p.Warn, in which case it is
+// logged to logger.
+func (p *FilePermissions) check(filename string, info fs.FileInfo, logger Logger) error {
+	if !permissionsChecked {
+		return nil
+	}
+	var problems []string
+	mode := info.Mode().Perm()
+	if mode&0022 != 0 {
+		problems = append(problems, "is writable by others")
+	}
+	if mode&0004 != 0 {
+		problems = append(problems, "is readable by anyone")
+	}
+	if problems != nil {
+		problems[len(problems)-1] += fmt.Sprintf(" (mode %04o)", mode)
+	}
+	if uid, ok := fileOwner(info); ok && !p.allowed(uid) {
+		problems = append(problems, fmt.Sprintf("is owned by user %d", uid))
+	}
+	if problems == nil {
+		return nil
+	}
+	err := fmt.Errorf("%s holds secrets but %s", filename, strings.Join(problems, " and "))
+	if p.Warn {
+		logger.Warn("config file warning", "error", err)
+		return nil
+	}
+	return err
+}
+
+func (p *FilePermissions) allowed(uid int) bool {
+	owners := p.Owners
+	if owners == nil {
+		owners = defaultOwners()
+	}
+	for _, owner := range owners {
+		if uid == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSecrets reports whether values of type t can hold secret fields.
+func hasSecrets(t reflect.Type) bool {
+	return typeHasSecrets(t, map[reflect.Type]bool{})
+}
+
+func typeHasSecrets(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if t == secretType {
+		return true
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return typeHasSecrets(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !exported(sf) {
+				continue
+			}
+			if sf.Tag.Get("secret") == "true" || typeHasSecrets(sf.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package goconfig
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// permissionsChecked tells whether CheckFilePermissions has any effect.
+const permissionsChecked = true
+
+// fileOwner returns the user ID owning the file described by info.
+func fileOwner(info fs.FileInfo) (int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(stat.Uid), true
+}
+
+// defaultOwners are the users allowed to own config files holding secrets by
+// default: the user running the process and root.
+func defaultOwners() []int {
+	return []int{os.Geteuid(), 0}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package goconfig
+
+import (
+	"io/fs"
+)
+
+// permissionsChecked is false, as Windows doesn't have Unix permissions and
+// its ACLs aren't checked.
+const permissionsChecked = false
+
+func fileOwner(info fs.FileInfo) (int, bool) {
+	return 0, false
+}
+
+func defaultOwners() []int {
+	return nil
+}
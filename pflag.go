@@ -0,0 +1,135 @@
+package goconfig
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// pFlags is a flagSource for a pflag.FlagSet, on which every field has a flag
+// named by its `flag` tag or else its yaml key path.
+type pFlags struct {
+	fs *pflag.FlagSet
+}
+
+func (pFlags) flagName(sf reflect.StructField, path fieldPath) string {
+	if name := sf.Tag.Get("flag"); name != "" {
+		return name
+	}
+	if sf.Tag.Get("yaml") == "-" || !flaggable(sf.Type) {
+		return ""
+	}
+	return path.key
+}
+
+func (f pFlags) lookup(name string) (string, bool) {
+	fl := f.fs.Lookup(name)
+	if fl == nil || !fl.Changed {
+		return "", false
+	}
+	if sv, ok := fl.Value.(pflag.SliceValue); ok {
+		return strings.Join(sv.GetSlice(), ","), true
+	}
+	return fl.Value.String(), true
+}
+
+// pflagValue is the pflag.Value BindPFlags defines. Like flagValue, it only
+// holds the string given on the command line.
+type pflagValue struct {
+	flagValue
+	typ string
+}
+
+func (v *pflagValue) Type() string {
+	return v.typ
+}
+
+// BindPFlags defines a flag on fs for every field of c, nested or not, that
+// can be set from a string, for cobra and other pflag based CLIs. Flags are
+// named by the field's `flag` tag or else its yaml key path, e.g.
+// --server.port=8080, use the `desc` tag as their usage and the `default` tag
+// as their default. Pass WithPFlags(fs) to Load once fs has been parsed to
+// apply them.
+func BindPFlags(fs *pflag.FlagSet, c interface{}) error {
+	if cfg, ok := c.(Configterface); ok {
+		c = configTarget(cfg)
+	}
+	t := reflect.TypeOf(c)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ErrNotPointer
+	}
+	bindStructPFlags(fs, t, fieldPath{})
+	return nil
+}
+
+func bindStructPFlags(fs *pflag.FlagSet, t reflect.Type, path fieldPath) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !exported(sf) {
+			continue
+		}
+		name := path.child(sf)
+		if sf.Type.Kind() == reflect.Struct && !parsesItself(sf.Type) && sf.Tag.Get("flag") == "" {
+			bindStructPFlags(fs, sf.Type, name)
+			continue
+		}
+		flagName := pFlags{}.flagName(sf, name)
+		if flagName == "" || fs.Lookup(flagName) != nil {
+			continue
+		}
+		value := &pflagValue{flagValue{value: sf.Tag.Get("default")}, pflagType(sf.Type)}
+		fl := fs.VarPF(value, flagName, "", sf.Tag.Get("desc"))
+		if value.typ == "bool" {
+			fl.NoOptDefVal = "true"
+		}
+	}
+}
+
+// flaggable reports whether setFromString can parse a value of type t.
+func flaggable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if parsesItself(t) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Slice && flaggable(t.Elem())
+	}
+	return false
+}
+
+// pflagType names the type of a flag for t in the pflag manner, as shown in
+// usage messages.
+func pflagType(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == durationType:
+		return "duration"
+	case parsesItself(t):
+		return "string"
+	case t.Kind() == reflect.Slice:
+		return pflagType(t.Elem()) + "s"
+	}
+	return t.Kind().String()
+}
+
+// WithPFlags applies the flags set on fs, which must have been parsed, to the
+// fields they were bound to by BindPFlags, or that are tagged with their
+// names. As with WithFlags, flags that weren't set are ignored.
+func WithPFlags(fs *pflag.FlagSet) Option {
+	return func(o *options) {
+		o.flags = append(o.flags, pFlags{fs})
+	}
+}
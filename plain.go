@@ -0,0 +1,143 @@
+package goconfig
+
+import (
+	"reflect"
+	"sync"
+)
+
+var (
+	plainMu sync.Mutex
+	// plainConfigs holds the state kept for plain config structs, by
+	// pointer, until they are released.
+	plainConfigs = map[interface{}]*plainConfig{}
+)
+
+// plainConfig holds the filename, listening state and lock of a config
+// struct that doesn't implement Configterface itself, so that any pointer to
+// a struct can be loaded. Reloads assign the struct in place.
+type plainConfig struct {
+	ptr       interface{}
+	filename  string
+	listening bool
+	sync.RWMutex
+}
+
+// StateOf returns config as a Configterface: config itself if it is one, or
+// else the filename, listening state and lock kept for it, so that the
+// readers of a plain struct can take its read lock while it may be reloaded,
+// as in
+//
+//	state := goconfig.StateOf(&cfg)
+//	state.RLock()
+//	port := cfg.Port
+//	state.RUnlock()
+//
+// The state is kept, along with the load status Status reports, until config
+// is released with Release.
+func StateOf(config interface{}) Configterface {
+	return keepConfig(config)
+}
+
+// Release drops the state kept for config by StateOf, ListenForSignals, Watch,
+// Subscribe and the other calls that outlive themselves, once it is no longer
+// reloaded, and closes the channels returned by Subscribe. Loads only keep
+// state for configs that already have it, so a config that is loaded once
+// needs no releasing.
+func Release(config interface{}) {
+	c := configOf(config)
+	plainMu.Lock()
+	if p, ok := c.(*plainConfig); ok {
+		delete(plainConfigs, p.ptr)
+	}
+	plainMu.Unlock()
+	statesMu.Lock()
+	delete(states, c)
+	statesMu.Unlock()
+	subscribersMu.Lock()
+	for _, ch := range subscribers[c] {
+		close(ch)
+	}
+	delete(subscribers, c)
+	subscribersMu.Unlock()
+}
+
+// configOf returns c as a Configterface: c itself if it is one, or else the
+// state kept for it, or a temporary one for a single call if none is, so that
+// one-off loads of plain structs leave nothing behind.
+func configOf(c interface{}) Configterface {
+	if cfg, ok := c.(Configterface); ok {
+		return cfg
+	}
+	if value := reflect.ValueOf(c); value.Kind() == reflect.Ptr && !value.IsNil() {
+		plainMu.Lock()
+		defer plainMu.Unlock()
+		if p, ok := plainConfigs[c]; ok {
+			return p
+		}
+	}
+	return &plainConfig{ptr: c}
+}
+
+// keepConfig is configOf for the calls that go on using c after they return,
+// such as ListenForSignals. It keeps the state of c, the same for the same
+// pointer each time, and records its loads from then on, until c is
+// released.
+func keepConfig(c interface{}) Configterface {
+	cfg, ok := c.(Configterface)
+	if !ok {
+		if value := reflect.ValueOf(c); value.Kind() != reflect.Ptr || value.IsNil() {
+			// checkPointer rejects it, and it may not be usable as a map key.
+			return &plainConfig{ptr: c}
+		}
+		plainMu.Lock()
+		p, ok := plainConfigs[c]
+		if !ok {
+			p = &plainConfig{ptr: c}
+			plainConfigs[c] = p
+		}
+		plainMu.Unlock()
+		cfg = p
+	}
+	statesMu.Lock()
+	defer statesMu.Unlock()
+	if _, ok := states[cfg]; !ok {
+		states[cfg] = &loadState{}
+	}
+	return cfg
+}
+
+// userConfig returns c as the caller passed it to configOf.
+func userConfig(c Configterface) interface{} {
+	if p, ok := c.(*plainConfig); ok {
+		return p.ptr
+	}
+	return c
+}
+
+func (p *plainConfig) GetFilename() string {
+	return p.filename
+}
+
+func (p *plainConfig) SetFilename(filename string) {
+	p.filename = filename
+}
+
+func (p *plainConfig) IsListening() bool {
+	return p.listening
+}
+
+func (p *plainConfig) SetListening(listening bool) {
+	p.listening = listening
+}
+
+func (p *plainConfig) target() interface{} {
+	return p.ptr
+}
+
+func (p *plainConfig) replace(v interface{}) {
+	assignConfig(reflect.ValueOf(p.ptr).Elem(), reflect.ValueOf(v).Elem())
+}
+
+func (p *plainConfig) snapshot() interface{} {
+	return copyConfig(p.ptr)
+}
@@ -0,0 +1,111 @@
+package goconfig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"time"
+)
+
+const (
+	// defaultPollInterval is how often Watch polls remote configs that
+	// can't be watched natively, unless told otherwise.
+	defaultPollInterval = 30 * time.Second
+	// defaultPollJitter is the fraction by which poll intervals vary.
+	defaultPollJitter = 0.1
+	// maxPollBackoff caps the wait between polls after failures, unless the
+	// poll interval itself is longer.
+	maxPollBackoff = 5 * time.Minute
+)
+
+// WithPollInterval sets how often Watch polls remote configs whose backend
+// can't be watched, such as http and s3 URLs. The default is every 30 seconds.
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.pollInterval = interval
+	}
+}
+
+// WithPollJitter makes each poll interval vary randomly by up to the given
+// fraction of it, e.g. 0.2 for ±20%, so that a fleet started together doesn't
+// poll in lockstep. The default is 0.1.
+func WithPollJitter(fraction float64) Option {
+	return func(o *options) {
+		o.pollJitter = &fraction
+	}
+}
+
+// poller polls a remote config for changes.
+type poller struct {
+	interval time.Duration
+	jitter   float64
+}
+
+func newPoller(o *options) poller {
+	p := poller{interval: defaultPollInterval, jitter: defaultPollJitter}
+	if o.pollInterval > 0 {
+		p.interval = o.pollInterval
+	}
+	if o.pollJitter != nil {
+		p.jitter = *o.pollJitter
+	}
+	return p
+}
+
+// wait returns how long to wait before the next poll, after the given number
+// of failures in a row. Each failure doubles the wait, up to maxPollBackoff.
+func (p poller) wait(failures int) time.Duration {
+	d := p.interval
+	for i := 0; i < failures && d < maxPollBackoff; i++ {
+		d *= 2
+	}
+	if d > maxPollBackoff && p.interval < maxPollBackoff {
+		d = maxPollBackoff
+	}
+	if p.jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.jitter * float64(d))
+	}
+	return d
+}
+
+// pollRemote fetches the document at u every poll interval and triggers r
+// when it has changed, until ctx is done. Failed fetches are reported as
+// failed reloads, and back off exponentially.
+func pollRemote(ctx context.Context, u *url.URL, fetch fetcher, opts []Option, r *reloader) {
+	o := newOptions(opts)
+	p := newPoller(o)
+	last, err := fetch(ctx, u, o)
+	failures := 0
+	if err != nil {
+		failures++
+	}
+	for {
+		select {
+		case <-time.After(p.wait(failures)):
+		case <-ctx.Done():
+			return
+		}
+		data, err := fetch(ctx, u, o)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			err = fmt.Errorf("polling %s: %w", u.Redacted(), err)
+			recordFetch(r.c, u.Redacted(), err)
+			failures++
+			r.fail(err)
+			continue
+		}
+		recordFetch(r.c, u.Redacted(), nil)
+		if failures == 0 && bytes.Equal(data, last) {
+			continue
+		}
+		// After failures, reload regardless, as the config may have
+		// changed unseen.
+		failures = 0
+		last = data
+		r.trigger(u.Redacted())
+	}
+}
@@ -0,0 +1,323 @@
+package goconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseError is an error at a known position in a config document.
+type ParseError struct {
+	// Filename is empty for documents that weren't read from a file.
+	Filename string
+	Line     int
+	// Column is 0 when only the line is known.
+	Column int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	if e.Filename != "" {
+		pos := e.Filename + ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			pos += ":" + strconv.Itoa(e.Column)
+		}
+		return pos + ": " + e.Msg
+	}
+	pos := "line " + strconv.Itoa(e.Line)
+	if e.Column > 0 {
+		pos += ", column " + strconv.Itoa(e.Column)
+	}
+	return pos + ": " + e.Msg
+}
+
+var (
+	yamlLineRe      = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	yamlFieldRe     = regexp.MustCompile(`^field (\S+) (?:not found|already set) in type`)
+	yamlKeyRe       = regexp.MustCompile(`^mapping key "(.*)" already defined`)
+	yamlScalarRe    = regexp.MustCompile("^cannot unmarshal \\S+ `(.*?)(\\.\\.\\.)?` into")
+	yamlNonScalarRe = regexp.MustCompile(`^cannot unmarshal !!(seq|map) into`)
+)
+
+// positionError converts err, returned while decoding data from filename in
+// the given format, into ParseErrors where the decoder reports a position.
+// Other errors are just prefixed with the filename.
+func positionError(filename, format string, data []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch format {
+	case FormatYAML:
+		if errs := yamlPositionErrors(filename, data, err); errs != nil {
+			return errs
+		}
+	case FormatJSON:
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, col := offsetPosition(data, syntaxErr.Offset)
+			return &ParseError{Filename: filename, Line: line, Column: col, Msg: syntaxErr.Error()}
+		}
+	case FormatTOML:
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return &ParseError{Filename: filename, Line: parseErr.Position.Line, Column: parseErr.Position.Col, Msg: parseErr.Message}
+		}
+	case FormatINI, FormatProperties:
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			return &ParseError{Filename: filename, Line: parseErr.Line, Column: parseErr.Column, Msg: parseErr.Msg}
+		}
+	}
+	return fileError(filename, err)
+}
+
+// yamlPositionErrors turns the "line N: ..." messages of a yaml error into
+// ParseErrors, looking up the column of the offending node where possible. It
+// returns nil if err carries no positions.
+func yamlPositionErrors(filename string, data []byte, err error) error {
+	var msgs []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+	var root yaml.Node
+	// Type errors imply the document parsed, so its nodes can be searched.
+	hasNodes := typeErr != nil && yaml.Unmarshal(data, &root) == nil
+	var errs []error
+	for _, msg := range msgs {
+		m := yamlLineRe.FindStringSubmatch(strings.TrimSpace(msg))
+		if m == nil {
+			return nil
+		}
+		line, _ := strconv.Atoi(m[1])
+		col := 0
+		if hasNodes {
+			col = yamlColumn(&root, line, m[2])
+		}
+		errs = append(errs, &ParseError{Filename: filename, Line: line, Column: col, Msg: m[2]})
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// yamlColumn returns the column of the node on line that msg most likely
+// refers to, or 0 if there is no node on that line.
+func yamlColumn(root *yaml.Node, line int, msg string) int {
+	var onLine []*yaml.Node
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Line == line && n.Kind != yaml.DocumentNode {
+			onLine = append(onLine, n)
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(root)
+	match := yamlMatcher(msg)
+	for _, n := range onLine {
+		if match(n) {
+			return n.Column
+		}
+	}
+	if len(onLine) > 0 {
+		return onLine[0].Column
+	}
+	return 0
+}
+
+// yamlMatcher returns a func reporting whether a node is the one a yaml error
+// message refers to, going by the key or value it quotes.
+func yamlMatcher(msg string) func(*yaml.Node) bool {
+	match := func(*yaml.Node) bool { return true }
+	if m := yamlFieldRe.FindStringSubmatch(msg); m != nil {
+		match = func(n *yaml.Node) bool { return n.Kind == yaml.ScalarNode && n.Value == m[1] }
+	} else if m := yamlKeyRe.FindStringSubmatch(msg); m != nil {
+		match = func(n *yaml.Node) bool { return n.Kind == yaml.ScalarNode && n.Value == m[1] }
+	} else if m := yamlScalarRe.FindStringSubmatch(msg); m != nil {
+		match = func(n *yaml.Node) bool {
+			if n.Kind != yaml.ScalarNode {
+				return false
+			}
+			if m[2] != "" {
+				return strings.HasPrefix(n.Value, m[1])
+			}
+			return n.Value == m[1]
+		}
+	} else if m := yamlNonScalarRe.FindStringSubmatch(msg); m != nil {
+		kind := yaml.SequenceNode
+		if m[1] == "map" {
+			kind = yaml.MappingNode
+		}
+		match = func(n *yaml.Node) bool { return n.Kind == kind }
+	}
+	return match
+}
+
+// treePositionErrors converts err, returned while decoding data, the yaml a
+// document tree was marshaled to, into errors at the positions of the values
+// in the documents originOf returns for their keys, or at the keys alone, as
+// the lines of data are no use to anyone. It returns nil if err carries no
+// positions.
+func treePositionErrors(data []byte, err error, originOf func(key string) *origin) error {
+	var msgs []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+	var root yaml.Node
+	if yaml.Unmarshal(data, &root) != nil {
+		return nil
+	}
+	roots := map[*origin]*yaml.Node{}
+	var errs []error
+	for _, msg := range msgs {
+		m := yamlLineRe.FindStringSubmatch(strings.TrimSpace(msg))
+		if m == nil {
+			return nil
+		}
+		line, _ := strconv.Atoi(m[1])
+		key, isKey := yamlKeyOnLine(&root, line, m[2])
+		o := originOf(key)
+		if o == nil {
+			errs = append(errs, keyError(key, m[2]))
+			continue
+		}
+		if o.format == FormatYAML && len(o.data) > 0 {
+			r, ok := roots[o]
+			if !ok {
+				r = &yaml.Node{}
+				if yaml.Unmarshal(o.data, r) != nil {
+					r = nil
+				}
+				roots[o] = r
+			}
+			if n := yamlNodeAt(r, key, isKey); n != nil {
+				errs = append(errs, &ParseError{Filename: o.filename, Line: n.Line, Column: n.Column, Msg: m[2]})
+				continue
+			}
+		}
+		errs = append(errs, fileError(o.filename, keyError(key, m[2])))
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// keyError is the error msg about the value at key, which may be empty.
+func keyError(key, msg string) error {
+	if key == "" {
+		return errors.New(msg)
+	}
+	return errors.New(key + ": " + msg)
+}
+
+// yamlKeyOnLine returns the dotted key path of the node on line that msg most
+// likely refers to, and whether that node is the key rather than the value.
+func yamlKeyOnLine(root *yaml.Node, line int, msg string) (string, bool) {
+	type candidate struct {
+		node  *yaml.Node
+		key   string
+		isKey bool
+	}
+	var onLine []candidate
+	var walk func(n *yaml.Node, key string, isKey bool)
+	walk = func(n *yaml.Node, key string, isKey bool) {
+		if n.Line == line && n.Kind != yaml.DocumentNode {
+			onLine = append(onLine, candidate{n, key, isKey})
+		}
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, child := range n.Content {
+				walk(child, key, false)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k := joinPath(key, n.Content[i].Value)
+				walk(n.Content[i], k, true)
+				walk(n.Content[i+1], k, false)
+			}
+		case yaml.SequenceNode:
+			for i, child := range n.Content {
+				walk(child, joinPath(key, strconv.Itoa(i)), false)
+			}
+		}
+	}
+	walk(root, "", false)
+	match := yamlMatcher(msg)
+	for _, c := range onLine {
+		if match(c.node) {
+			return c.key, c.isKey
+		}
+	}
+	if len(onLine) > 0 {
+		return onLine[0].key, onLine[0].isKey
+	}
+	return "", false
+}
+
+// yamlNodeAt returns the node of root at the dotted key path, the key node if
+// isKey, or nil if there is none.
+func yamlNodeAt(root *yaml.Node, key string, isKey bool) *yaml.Node {
+	if root == nil || key == "" {
+		return nil
+	}
+	n := root
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		last := i == len(parts)-1
+		switch n.Kind {
+		case yaml.MappingNode:
+			var next *yaml.Node
+			for j := 0; j+1 < len(n.Content); j += 2 {
+				if n.Content[j].Value == part {
+					if last && isKey {
+						return n.Content[j]
+					}
+					next = n.Content[j+1]
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			n = next
+		case yaml.SequenceNode:
+			j, err := strconv.Atoi(part)
+			if err != nil || j < 0 || j >= len(n.Content) {
+				return nil
+			}
+			n = n.Content[j]
+		default:
+			return nil
+		}
+	}
+	return n
+}
+
+// offsetPosition converts a byte offset in data into a 1-based line and
+// column.
+func offsetPosition(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
@@ -0,0 +1,213 @@
+package goconfig
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// preparedTree is a document tree made ready to be decoded into a struct.
+type preparedTree struct {
+	// changed is set if the tree was modified.
+	changed bool
+	// deferred are the values the decoder can't set itself, to set once the
+	// tree has been decoded.
+	deferred []deferredValue
+	errs     []string
+}
+
+// deferredValue is a parsed value to set at a path in the decoded struct.
+type deferredValue struct {
+	steps []pathStep
+	value reflect.Value
+}
+
+// pathStep leads from a value to one within it: a struct field, a slice or
+// array element or a map entry.
+type pathStep struct {
+	kind  reflect.Kind
+	index int
+	key   string
+}
+
+// prepareTree makes tree ready to be decoded into the struct type t. The
+// string values for fields with a type in valueParsers, such as
+// time.Duration, are checked so that errors name the key rather than just the
+// type, whatever the format. Times with a `layout` tag are parsed with it, and
+// values of types with a decode hook or a textCodec, such as url.URL, are
+// taken out of the tree to be set by apply. Errors for every value are
+// collected.
+func prepareTree(tree map[string]interface{}, t reflect.Type) (*preparedTree, error) {
+	p := &preparedTree{}
+	p.value(tree, t, "", "", nil)
+	if p.errs != nil {
+		return p, errors.New(strings.Join(p.errs, ". "))
+	}
+	return p, nil
+}
+
+// value prepares v, found at path, filling a value of type t whose field has
+// the given layout tag, and returns what to replace it with.
+func (p *preparedTree) value(v interface{}, t reflect.Type, layout, path string, steps []pathStep) interface{} {
+	pointer := t.Kind() == reflect.Ptr
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s, isString := v.(string)
+	if hook, ok := decodeHookFor(t); ok {
+		if v == nil {
+			return v
+		}
+		p.changed = true
+		converted, err := runDecodeHook(hook, t, v)
+		if err != nil {
+			p.errs = append(p.errs, fmt.Sprintf("%s: %s", path, err))
+		} else {
+			p.deferred = append(p.deferred, deferredValue{steps, converted})
+		}
+		return placeholder(t, pointer)
+	}
+	if codec, ok := textCodecs[t]; ok {
+		if !isString {
+			return v
+		}
+		p.changed = true
+		if s == "" {
+			return placeholder(t, pointer)
+		}
+		parsed, err := codec.parse(s)
+		if err != nil {
+			p.errs = append(p.errs, fmt.Sprintf("%s: %s", path, err))
+		} else {
+			p.deferred = append(p.deferred, deferredValue{steps, reflect.ValueOf(parsed)})
+		}
+		return placeholder(t, pointer)
+	}
+	if isString && t == timeType && layout != "" {
+		parsed, err := parseTime(s, layout)
+		if err != nil {
+			p.errs = append(p.errs, fmt.Sprintf("%s: %s", path, err))
+			return v
+		}
+		p.changed = true
+		return parsed
+	}
+	if parse, ok := valueParsers[t]; ok {
+		if isString {
+			if err := parse(s); err != nil {
+				p.errs = append(p.errs, fmt.Sprintf("%s: %s", path, err))
+			}
+		}
+		return v
+	}
+	child := func(step pathStep) []pathStep {
+		return append(steps[:len(steps):len(steps)], step)
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		tree, ok := v.(map[string]interface{})
+		if !ok || parsesItself(t) {
+			return v
+		}
+		fields := fieldsOf(t)
+		for i := range fields {
+			f := &fields[i]
+			step := pathStep{kind: reflect.Struct, index: f.Index[0]}
+			if f.key == "" {
+				if f.PkgPath != "" && unflattened(f.StructField) && hasAnyKey(tree, structKeys(f.Type, true)) {
+					// yaml can only set the fields of such a struct if it
+					// flattens it.
+					p.errs = append(p.errs, fmt.Sprintf("%s: the embedded %s must be tagged `yaml:\",inline\"` to be loaded from config files", joinPath(path, f.Name), f.Type))
+					continue
+				}
+				p.value(tree, f.Type, "", path, child(step))
+				continue
+			}
+			if value, ok := tree[f.key]; ok {
+				tree[f.key] = p.value(value, f.Type, f.layout, joinPath(path, f.key), child(step))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		list, _ := v.([]interface{})
+		for i, item := range list {
+			list[i] = p.value(item, t.Elem(), layout, fmt.Sprintf("%s[%d]", path, i), child(pathStep{kind: reflect.Slice, index: i}))
+		}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return v
+		}
+		m, _ := v.(map[string]interface{})
+		for k, item := range m {
+			m[k] = p.value(item, t.Elem(), layout, fmt.Sprintf("%s[%s]", path, k), child(pathStep{kind: reflect.Map, key: k}))
+		}
+	}
+	return v
+}
+
+// placeholder returns what to leave in the tree in place of a value of type t,
+// or a pointer to one, that apply sets: something that decodes to the zero
+// value rather than null, so that it keeps its place in a list.
+func placeholder(t reflect.Type, pointer bool) interface{} {
+	if pointer || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return map[string]interface{}{}
+	case reflect.Slice, reflect.Array:
+		return []interface{}{}
+	case reflect.String:
+		return ""
+	case reflect.Bool:
+		return false
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return 0
+	}
+	return nil
+}
+
+// apply sets the deferred values in v, the pointer the tree was decoded into.
+func (p *preparedTree) apply(v interface{}) {
+	root := reflect.ValueOf(v)
+	for _, d := range p.deferred {
+		setAtPath(root, d.steps, d.value)
+	}
+}
+
+// setAtPath sets the value at the end of steps from v to value, allocating
+// pointers and maps along the way.
+func setAtPath(v reflect.Value, steps []pathStep, value reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if len(steps) == 0 {
+		v.Set(value)
+		return
+	}
+	step := steps[0]
+	switch step.kind {
+	case reflect.Struct:
+		setAtPath(v.Field(step.index), steps[1:], value)
+	case reflect.Slice:
+		if step.index < v.Len() {
+			setAtPath(v.Index(step.index), steps[1:], value)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(step.key).Convert(v.Type().Key())
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		}
+		setAtPath(elem, steps[1:], value)
+		v.SetMapIndex(key, elem)
+	}
+}
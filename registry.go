@@ -0,0 +1,122 @@
+package goconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Registry manages several independent configs by name, such as "app",
+// "plugins" and "secrets", each loaded from its own sources with its own
+// options, including reload signals, OnReload and OnError listeners. It saves
+// repeating the loading and listening for every config of a process.
+type Registry struct {
+	mu      sync.RWMutex
+	configs map[string]*registered
+	// names holds the names of the configs in the order they were registered,
+	// which is the order they are loaded in.
+	names []string
+}
+
+// registered is a config in a Registry.
+type registered struct {
+	c    Configterface
+	opts []Option
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{configs: map[string]*registered{}}
+}
+
+// Register adds config to the registry under name, to be loaded, reloaded and
+// watched with opts. Names must be unique.
+func (r *Registry) Register(name string, config interface{}, opts ...Option) error {
+	c := keepConfig(config)
+	if err := checkPointer(c); err != nil {
+		return fmt.Errorf("config %s: %w", name, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.configs[name]; ok {
+		return fmt.Errorf("config %s is already registered", name)
+	}
+	r.configs[name] = &registered{c: c, opts: opts}
+	r.names = append(r.names, name)
+	return nil
+}
+
+// Get returns the config registered under name, as it was registered, or nil
+// if there is none.
+func (r *Registry) Get(name string) interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if reg, ok := r.configs[name]; ok {
+		return userConfig(reg.c)
+	}
+	return nil
+}
+
+// Names returns the names of the registered configs, in the order they were
+// registered.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.names...)
+}
+
+// Load loads every registered config, in the order they were registered. All
+// of them are loaded even if some fail, and the errors are returned together,
+// each prefixed with the name of its config.
+func (r *Registry) Load() error {
+	return r.each(func(reg *registered) error {
+		return Load(reg.c, reg.opts...)
+	})
+}
+
+// Reload reloads the config registered under name, as Reload does.
+func (r *Registry) Reload(name string) error {
+	r.mu.RLock()
+	reg, ok := r.configs[name]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no config named %s", name)
+	}
+	return Reload(reg.c, reg.opts...)
+}
+
+// ListenForSignals reloads every registered config on its reload signals, as
+// ListenForSignalsContext does, until ctx is done.
+func (r *Registry) ListenForSignals(ctx context.Context) error {
+	return r.each(func(reg *registered) error {
+		return ListenForSignalsContext(ctx, reg.c, reg.opts...)
+	})
+}
+
+// Watch reloads every registered config whenever its sources change, as
+// WatchContext does, until ctx is done.
+func (r *Registry) Watch(ctx context.Context) error {
+	return r.each(func(reg *registered) error {
+		return WatchContext(ctx, reg.c, reg.opts...)
+	})
+}
+
+// each calls fn for every registered config in order, and returns the errors
+// it returned.
+func (r *Registry) each(fn func(*registered) error) error {
+	r.mu.RLock()
+	names := append([]string(nil), r.names...)
+	configs := make([]*registered, len(names))
+	for i, name := range names {
+		configs[i] = r.configs[name]
+	}
+	r.mu.RUnlock()
+	var errs []error
+	for i, reg := range configs {
+		if err := fn(reg); err != nil {
+			errs = append(errs, fmt.Errorf("config %s: %w", names[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,320 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultWatchDebounce is the settle window Watch uses unless told otherwise,
+// long enough to cover a truncate and write or a write and rename.
+const defaultWatchDebounce = 100 * time.Millisecond
+
+// reloader reloads a config in the background, coalescing reload requests that
+// arrive within its debounce window into one.
+type reloader struct {
+	c     Configterface
+	opts  []Option
+	delay time.Duration
+
+	mu    sync.Mutex
+	timer *time.Timer
+	// triggers are the files and URLs whose changes the pending reload is
+	// for, in the order they changed.
+	triggers []string
+}
+
+func newReloader(c Configterface, opts []Option, defaultDelay time.Duration) *reloader {
+	delay := defaultDelay
+	if d := newOptions(opts).debounce; d != nil {
+		delay = *d
+	}
+	return &reloader{c: c, opts: opts, delay: delay}
+}
+
+// trigger requests a reload, which happens once no other request has arrived
+// for the debounce window. source is the file or URL whose change requested
+// it, if any.
+func (r *reloader) trigger(source string) {
+	r.mu.Lock()
+	if source != "" && !containsString(r.triggers, source) {
+		r.triggers = append(r.triggers, source)
+	}
+	if r.delay <= 0 {
+		r.mu.Unlock()
+		r.reload()
+		return
+	}
+	defer r.mu.Unlock()
+	if r.timer == nil {
+		r.timer = time.AfterFunc(r.delay, r.reload)
+	} else {
+		r.timer.Reset(r.delay)
+	}
+}
+
+// stop cancels any pending reload.
+func (r *reloader) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
+
+// Reloads the config straight away, as a signal to ListenForSignals would, and
+// returns the error of a failed reload. OnReload hooks and subscribers are
+// notified as for background reloads. This gives platforms without SIGHUP, and
+// admin endpoints, a way of triggering reloads.
+func Reload(config interface{}, opts ...Option) error {
+	c := configOf(config)
+	if err := checkPointer(c); err != nil {
+		return err
+	}
+	return newReloader(c, opts, 0).run(nil)
+}
+
+// reload reloads the config in the background, for the changes to the files
+// and URLs that triggered it, reporting failures.
+func (r *reloader) reload() {
+	r.mu.Lock()
+	triggers := r.triggers
+	r.triggers = nil
+	r.mu.Unlock()
+	if err := r.run(triggers); err != nil {
+		r.fail(err)
+	}
+}
+
+// run reloads the config, for the changes to triggers, then calls the
+// OnReload hooks and notifies subscribers of the outcome. Reloads that find
+// the config files as they were last loaded are skipped without notifying
+// anyone.
+func (r *reloader) run(triggers []string) (err error) {
+	o := newOptions(r.opts)
+	hooks := o.onReload
+	o.log().Debug("config reload started", "file", r.c.GetFilename())
+	if o.metrics != nil {
+		start := time.Now()
+		defer func() {
+			o.metrics.reloaded(time.Since(start), err)
+		}()
+	}
+	subscribed := hasSubscribers(r.c)
+	var old interface{}
+	if len(hooks) > 0 || subscribed {
+		old = Snapshot(r.c)
+	}
+	var unchanged bool
+	opts := append(append([]Option(nil), r.opts...), reloading, func(o *options) {
+		o.unchanged = &unchanged
+	})
+	if err := Load(r.c, opts...); err != nil {
+		if subscribed {
+			publish(r.c, ChangeEvent{Err: err, Triggers: triggers})
+		}
+		return err
+	}
+	if unchanged {
+		o.log().Debug("config unchanged, reload skipped", "file", r.c.GetFilename())
+		return nil
+	}
+	if triggers != nil {
+		o.log().Info("config reloaded", "file", r.c.GetFilename(), "triggers", triggers)
+	} else {
+		o.log().Info("config reloaded", "file", r.c.GetFilename())
+	}
+	for _, hook := range hooks {
+		hook(old, userConfig(r.c))
+	}
+	if subscribed {
+		r.c.RLock()
+		changes := Diff(old, r.c)
+		r.c.RUnlock()
+		publish(r.c, ChangeEvent{Changed: changedFields(changes), Changes: changes, Triggers: triggers})
+	}
+	return nil
+}
+
+// reloading marks a Load as a reload of an already loaded config.
+func reloading(o *options) {
+	o.reloading = true
+}
+
+// ForbiddenChanges is returned when a reload would change fields tagged
+// `reload:"forbidden"`, such as a listen address that can only take effect on
+// restart. The reload is rejected as a whole.
+type ForbiddenChanges struct {
+	Changes []Change
+}
+
+func (e ForbiddenChanges) Error() string {
+	parts := make([]string, len(e.Changes))
+	for i, c := range e.Changes {
+		parts[i] = fmt.Sprintf("%s (from %s to %s)", c.Path, formatValue(c.Old), formatValue(c.New))
+	}
+	return fmt.Sprintf("The following struct fields can't be changed by a reload: %s", strings.Join(parts, ", "))
+}
+
+// checkReloadable returns ForbiddenChanges if new, a reload of old, changes
+// any of its fields tagged `reload:"forbidden"`.
+func checkReloadable(old, new interface{}) error {
+	forbidden := taggedPaths(reflect.TypeOf(new).Elem(), "", "reload", "forbidden")
+	if len(forbidden) == 0 {
+		return nil
+	}
+	var changes []Change
+	for _, change := range diff(old, new) {
+		if underAny(change.Path, forbidden) {
+			changes = append(changes, change)
+		}
+	}
+	if changes != nil {
+		return ForbiddenChanges{changes}
+	}
+	return nil
+}
+
+// keepStatic restores the fields of new, a reload of old, that aren't tagged
+// `reload:"dynamic"` to their values in old. Structs holding dynamic fields are
+// descended into.
+func keepStatic(old, new reflect.Value) {
+	for i := 0; i < new.NumField(); i++ {
+		sf := new.Type().Field(i)
+		if sf.Type == mutexType || sf.Type == rwMutexType || sf.Tag.Get("reload") == "dynamic" {
+			continue
+		}
+		if exported(sf) && sf.Type.Kind() == reflect.Struct && sf.Type != timeType && len(taggedPaths(sf.Type, "", "reload", "dynamic")) > 0 {
+			keepStatic(old.Field(i), new.Field(i))
+			continue
+		}
+		settable(new.Field(i)).Set(deepCopy(readable(old.Field(i))))
+	}
+}
+
+// taggedPaths returns the Go paths of the fields of the struct type t, nested
+// or not, whose tag key has the given value.
+func taggedPaths(t reflect.Type, path, key, value string) []string {
+	var paths []string
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !exported(sf) {
+			continue
+		}
+		name := joinPath(path, sf.Name)
+		if sf.Tag.Get(key) == value {
+			paths = append(paths, name)
+			continue
+		}
+		if sf.Type.Kind() == reflect.Struct && sf.Type != timeType {
+			paths = append(paths, taggedPaths(sf.Type, name, key, value)...)
+		}
+	}
+	return paths
+}
+
+// underAny reports whether path is one of paths or lies within one of them.
+func underAny(path string, paths []string) bool {
+	for _, p := range paths {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// log returns the logger to send messages about reloads to.
+func (r *reloader) log() Logger {
+	return newOptions(r.opts).log()
+}
+
+// fail passes the error of a failed background reload to the OnError
+// callbacks, and logs it if there is a Logger or no callbacks or subscribers
+// to see it.
+func (r *reloader) fail(err error) {
+	o := newOptions(r.opts)
+	for _, fn := range o.onError {
+		fn(err)
+	}
+	if o.logger != nil || len(o.onError) == 0 && !hasSubscribers(r.c) {
+		o.log().Error("config file error", "error", err, "file", r.c.GetFilename())
+	}
+}
+
+// ChangeEvent reports the outcome of a background reload to subscribers.
+type ChangeEvent struct {
+	// Err is the error the reload failed with, if it failed.
+	Err error
+	// Changed lists the Go paths, e.g. "Database.Host", of the fields the
+	// reload changed.
+	Changed []string
+	// Changes holds the old and new values of those fields, with secrets
+	// masked.
+	Changes []Change
+	// Triggers lists the files and URLs whose changes Watch saw before the
+	// reload, empty for reloads on signals.
+	Triggers []string
+}
+
+// subscriberBuffer is the number of events a subscriber may fall behind by
+// before further events are dropped.
+const subscriberBuffer = 16
+
+var (
+	// subscribersMu guards subscribers.
+	subscribersMu sync.Mutex
+	subscribers   = map[Configterface][]chan ChangeEvent{}
+)
+
+// Subscribe returns a channel receiving an event after each background reload
+// of c by ListenForSignals or Watch, successful or not. Any number of
+// goroutines may subscribe. Events are dropped for subscribers that fall too
+// far behind rather than holding up reloads.
+func Subscribe(config interface{}) <-chan ChangeEvent {
+	c := keepConfig(config)
+	ch := make(chan ChangeEvent, subscriberBuffer)
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers[c] = append(subscribers[c], ch)
+	return ch
+}
+
+// Unsubscribe stops events being sent to ch, which must have been returned by
+// Subscribe(c), and closes it.
+func Unsubscribe(config interface{}, ch <-chan ChangeEvent) {
+	c := configOf(config)
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subs := subscribers[c]
+	for i, sub := range subs {
+		if sub == ch {
+			close(sub)
+			subs = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(subs) == 0 {
+		delete(subscribers, c)
+	} else {
+		subscribers[c] = subs
+	}
+}
+
+func hasSubscribers(c Configterface) bool {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	return len(subscribers[c]) > 0
+}
+
+func publish(c Configterface, event ChangeEvent) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	for _, ch := range subscribers[c] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
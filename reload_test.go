@@ -0,0 +1,85 @@
+package goconfig
+
+import (
+	"errors"
+	"testing"
+)
+
+type dynamicConfig struct {
+	Listen string `yaml:"listen"`
+	Log    struct {
+		Level  string `yaml:"level" reload:"dynamic"`
+		Output string `yaml:"output"`
+	} `yaml:"log"`
+	Limit int `yaml:"limit" reload:"dynamic"`
+}
+
+func TestReloadDynamicOnly(t *testing.T) {
+	var c dynamicConfig
+	StateOf(&c)
+	t.Cleanup(func() { Release(&c) })
+	if err := Load(&c, WithFile(writeConfigFile(t, "config.yaml", "listen: ':80'\nlog: {level: info, output: stderr}\nlimit: 1\n"))); err != nil {
+		t.Fatal(err)
+	}
+	filename := writeConfigFile(t, "config.yaml", "listen: ':81'\nlog: {level: debug, output: stdout}\nlimit: 2\n")
+	if err := Reload(&c, WithFile(filename), DynamicReload()); err != nil {
+		t.Fatal(err)
+	}
+	if c.Log.Level != "debug" || c.Limit != 2 {
+		t.Errorf("dynamic fields = %q, %d, want them reloaded", c.Log.Level, c.Limit)
+	}
+	if c.Listen != ":80" || c.Log.Output != "stderr" {
+		t.Errorf("static fields = %q, %q, want them kept", c.Listen, c.Log.Output)
+	}
+
+	// Without DynamicReload, every field is reloaded.
+	filename = writeConfigFile(t, "config.yaml", "listen: ':81'\nlog: {level: debug, output: stdout}\nlimit: 3\n")
+	if err := Reload(&c, WithFile(filename)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Listen != ":81" || c.Log.Output != "stdout" {
+		t.Errorf("static fields = %q, %q", c.Listen, c.Log.Output)
+	}
+}
+
+func TestReloadForbiddenChanges(t *testing.T) {
+	var c struct {
+		Listen string `yaml:"listen" reload:"forbidden"`
+		Port   int    `yaml:"port"`
+	}
+	StateOf(&c)
+	t.Cleanup(func() { Release(&c) })
+	if err := Load(&c, WithFile(writeConfigFile(t, "config.yaml", "listen: ':80'\nport: 1\n"))); err != nil {
+		t.Fatal(err)
+	}
+	err := Reload(&c, WithFile(writeConfigFile(t, "config.yaml", "listen: ':81'\nport: 2\n")))
+	var forbidden ForbiddenChanges
+	if !errors.As(err, &forbidden) {
+		t.Fatalf("err = %v, want ForbiddenChanges", err)
+	}
+	if len(forbidden.Changes) != 1 || forbidden.Changes[0].Path != "Listen" {
+		t.Errorf("changes = %v", forbidden.Changes)
+	}
+	const msg = `The following struct fields can't be changed by a reload: Listen (from ":80" to ":81")`
+	if err.Error() != msg {
+		t.Errorf("error = %q", err)
+	}
+	if c.Listen != ":80" || c.Port != 1 {
+		t.Errorf("config = %q, %d after a rejected reload", c.Listen, c.Port)
+	}
+
+	// The first load isn't a reload, so it may set the field.
+	var fresh struct {
+		Listen string `yaml:"listen" reload:"forbidden"`
+	}
+	if err := Load(&fresh, WithFile(writeConfigFile(t, "config.yaml", "listen: ':81'\n"))); err != nil {
+		t.Error(err)
+	}
+
+	if err := Reload(&c, WithFile(writeConfigFile(t, "config.yaml", "listen: ':80'\nport: 2\n"))); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 2 {
+		t.Errorf("port = %d", c.Port)
+	}
+}
@@ -0,0 +1,336 @@
+package goconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FetchFunc fetches the config document at u. A missing document is reported
+// with an error wrapping fs.ErrNotExist, so that it is treated like a missing
+// config file.
+type FetchFunc func(ctx context.Context, u *url.URL) ([]byte, error)
+
+// fetcher is a FetchFunc that also sees the options of the Load.
+type fetcher func(ctx context.Context, u *url.URL, o *options) ([]byte, error)
+
+// watchFunc calls changed whenever the document at u changes, until ctx is
+// done. It returns early if the watch breaks.
+type watchFunc func(ctx context.Context, u *url.URL, o *options, changed func()) error
+
+var (
+	// fetchersMu guards fetchers and watchers.
+	fetchersMu sync.RWMutex
+	fetchers   = map[string]fetcher{
+		"http":           fetchHTTP,
+		"https":          fetchHTTP,
+		"etcd":           fetchEtcd,
+		"etcd+https":     fetchEtcd,
+		"consul":         fetchConsul,
+		"consul+https":   fetchConsul,
+		"ssm":            fetchSSM,
+		"secretsmanager": fetchSecretsManager,
+		"s3":             fetchS3,
+		"gs":             fetchGCS,
+		"configmap":      fetchKubernetes,
+		"secret":         fetchKubernetes,
+	}
+	// watchers are the schemes whose documents Watch can follow.
+	watchers = map[string]watchFunc{
+		"etcd":         watchEtcd,
+		"etcd+https":   watchEtcd,
+		"consul":       watchConsul,
+		"consul+https": watchConsul,
+		"configmap":    watchKubernetes,
+		"secret":       watchKubernetes,
+	}
+)
+
+// RegisterScheme makes Load fetch config filenames that are URLs with the given
+// scheme, e.g. "s3", using fn. The format of the document is picked from the
+// extension of the URL's path, as for files, unless given with WithFormat.
+// Registering a known scheme replaces its fetcher.
+func RegisterScheme(scheme string, fn FetchFunc) {
+	registerFetcher(scheme, func(ctx context.Context, u *url.URL, _ *options) ([]byte, error) {
+		return fn(ctx, u)
+	})
+}
+
+// WatchFunc calls changed whenever the document at u changes, until ctx is
+// done. It returns early, with an error, if the watch breaks, and is then
+// called again after a while.
+type WatchFunc func(ctx context.Context, u *url.URL, changed func()) error
+
+// RegisterWatcher makes Watch follow the documents of URLs with the given
+// scheme, registered with RegisterScheme, with fn rather than polling them.
+// Registering a known scheme replaces its watcher.
+func RegisterWatcher(scheme string, fn WatchFunc) {
+	fetchersMu.Lock()
+	defer fetchersMu.Unlock()
+	watchers[strings.ToLower(scheme)] = func(ctx context.Context, u *url.URL, _ *options, changed func()) error {
+		return fn(ctx, u, changed)
+	}
+}
+
+func registerFetcher(scheme string, fn fetcher) {
+	fetchersMu.Lock()
+	defer fetchersMu.Unlock()
+	fetchers[strings.ToLower(scheme)] = fn
+}
+
+// remoteURL parses filename as a URL if its scheme has a fetcher.
+func remoteURL(filename string) (*url.URL, fetcher, bool) {
+	scheme, _, ok := strings.Cut(filename, "://")
+	if !ok {
+		return nil, nil, false
+	}
+	fetchersMu.RLock()
+	fetch, ok := fetchers[strings.ToLower(scheme)]
+	fetchersMu.RUnlock()
+	if !ok {
+		return nil, nil, false
+	}
+	u, err := url.Parse(filename)
+	if err != nil {
+		return nil, nil, false
+	}
+	return u, fetch, true
+}
+
+// remoteWatcher returns the watchFunc for the scheme of u.
+func remoteWatcher(u *url.URL) (watchFunc, bool) {
+	fetchersMu.RLock()
+	defer fetchersMu.RUnlock()
+	watch, ok := watchers[strings.ToLower(u.Scheme)]
+	return watch, ok
+}
+
+// remoteRetryDelay is how long watchRemote waits before re-establishing a
+// broken watch.
+const remoteRetryDelay = 5 * time.Second
+
+// watchRemote watches the document at u, triggering r on changes, until ctx is
+// done. Broken watches are reported as failed reloads and re-established.
+func watchRemote(ctx context.Context, u *url.URL, watch watchFunc, opts []Option, r *reloader) {
+	o := newOptions(opts)
+	for {
+		err := watch(ctx, u, o, func() {
+			r.trigger(u.Redacted())
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			r.fail(fmt.Errorf("watching %s: %w", u.Redacted(), err))
+		}
+		select {
+		case <-time.After(remoteRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// missingError reports a missing remote document, matching fs.ErrNotExist.
+type missingError string
+
+func (e missingError) Error() string {
+	return string(e)
+}
+
+func (e missingError) Is(target error) bool {
+	return target == fs.ErrNotExist
+}
+
+// resolveRemote resolves name relative to the URL parent.
+func resolveRemote(parent *url.URL, name string) string {
+	ref, err := url.Parse(name)
+	if err != nil {
+		return name
+	}
+	return parent.ResolveReference(ref).String()
+}
+
+var (
+	// remoteDocsMu guards remoteDocs.
+	remoteDocsMu sync.Mutex
+	// remoteDocs holds the last document decoded from each URL along with
+	// the data and the decoding options it was decoded with, so that
+	// unchanged remote documents aren't parsed again.
+	remoteDocs = map[string]remoteDoc{}
+)
+
+type remoteDoc struct {
+	// key identifies the decoding options, see remoteDocKey.
+	key  string
+	data []byte
+	doc  *document
+}
+
+// readRemote fetches and decodes the config document at the URL filename. A
+// missing document yields a nil document.
+func (r *docReader) readRemote(filename string, u *url.URL, fetch fetcher) (*document, error) {
+	var data []byte
+	err := r.retry(u.Redacted(), func() (err error) {
+		data, err = fetch(r.ctx, u, r.o)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fileError(u.Redacted(), err)
+	}
+	r.span.SetAttributes("size", len(data), "remote", true)
+	r.sum(filename, data)
+	// Templates and includes make a document depend on more than its own
+	// data, so only plain documents are cached.
+	cacheable := r.o.template == nil
+	key := r.remoteDocKey()
+	if cacheable {
+		remoteDocsMu.Lock()
+		cached, ok := remoteDocs[filename]
+		remoteDocsMu.Unlock()
+		if ok && cached.key == key && bytes.Equal(cached.data, data) {
+			return cached.doc.clone(), nil
+		}
+	}
+	doc, err := r.parse(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	if cacheable && !doc.merged {
+		remoteDocsMu.Lock()
+		remoteDocs[filename] = remoteDoc{key, data, doc.clone()}
+		remoteDocsMu.Unlock()
+	}
+	return doc, nil
+}
+
+// remoteDocKey returns a key for everything besides its data that decoding a
+// remote document depends on, so that a cached document is only reused when
+// it would be decoded the same way: the format and document selected, the
+// struct it is read for, whose aliases and migrations apply, and the keys and
+// credentials used to decrypt it. The Vault, AWS and GCP settings are told
+// apart by identity, as their credential functions can't be compared, so they
+// only match across loads with the same options, such as reloads. The key is
+// hashed so that no decryption key is kept in it.
+func (r *docReader) remoteDocKey() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q %d %t %p %p %p %p", r.o.format, r.o.document, r.o.mergeDocuments, r.target, r.o.vault, r.o.aws, r.o.gcp)
+	for _, key := range r.o.ageKeys {
+		fmt.Fprintf(h, " %q", key)
+	}
+	fmt.Fprintf(h, " %q %q", os.Getenv("SOPS_AGE_KEY"), os.Getenv("SOPS_AGE_KEY_FILE"))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// clone returns a copy of the document that can be merged without affecting
+// d.
+func (d *document) clone() *document {
+	copied := *d
+	copied.tree = deepCopy(reflect.ValueOf(d.tree)).Interface().(map[string]interface{})
+	return &copied
+}
+
+// defaultHTTPClient fetches remote configs unless WithHTTPClient says
+// otherwise.
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// WithHTTPClient fetches http and https config URLs with client rather than one
+// with a 30 second timeout, e.g. to set up TLS client certificates or a custom
+// CA, or to change the timeout.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// streamingClient returns a copy of client, or of the default client, without
+// a timeout, for long-lived requests such as watches.
+func streamingClient(client *http.Client) *http.Client {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	copied := *client
+	copied.Timeout = 0
+	return &copied
+}
+
+var (
+	// httpCacheMu guards httpCache.
+	httpCacheMu sync.Mutex
+	// httpCache holds the last response for each URL that came with
+	// validators, to make conditional requests with.
+	httpCache = map[string]httpResponse{}
+)
+
+type httpResponse struct {
+	etag         string
+	lastModified string
+	data         []byte
+}
+
+// fetchHTTP fetches the config at u with a GET request, conditional on it
+// having changed since the last fetch if the server gave an ETag or
+// Last-Modified header. A 404 or 410 counts as a missing config.
+func fetchHTTP(ctx context.Context, u *url.URL, o *options) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	key := u.String()
+	httpCacheMu.Lock()
+	cached, ok := httpCache[key]
+	httpCacheMu.Unlock()
+	if ok {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+	client := o.httpClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ok:
+		return cached.data, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, fs.ErrNotExist
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	httpCacheMu.Lock()
+	if etag != "" || lastModified != "" {
+		httpCache[key] = httpResponse{etag, lastModified, data}
+	} else {
+		delete(httpCache, key)
+	}
+	httpCacheMu.Unlock()
+	return data, nil
+}
@@ -0,0 +1,59 @@
+package goconfig
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+func TestRemoteDocCacheKeepsOptionsApart(t *testing.T) {
+	docs := map[string]string{
+		"multi": "port: 1\n---\nport: 2\n",
+		"yaml":  "port: 3\n",
+		"alias": "old_port: 4\n",
+	}
+	RegisterScheme("remotecachetest", func(ctx context.Context, u *url.URL) ([]byte, error) {
+		return []byte(docs[u.Host]), nil
+	})
+	type config struct {
+		Port int `yaml:"port"`
+	}
+
+	var c config
+	if err := Load(&c, WithFile("remotecachetest://multi/config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(&c, WithFile("remotecachetest://multi/config.yaml"), WithDocument(1)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 2 {
+		t.Errorf("port = %d, want that of the second document", c.Port)
+	}
+
+	if err := Load(&c, WithFile("remotecachetest://yaml/config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(&c, WithFile("remotecachetest://yaml/config.yaml"), WithFormat(FormatJSON)); err == nil {
+		t.Error("YAML decoded as JSON from the cache")
+	}
+
+	// The aliases of the struct loaded rewrite the document.
+	if err := Load(&c, WithFile("remotecachetest://alias/config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	var aliased struct {
+		Port int `yaml:"port" alias:"old_port"`
+	}
+	if err := Load(&aliased, WithFile("remotecachetest://alias/config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	var old struct {
+		OldPort int `yaml:"old_port"`
+	}
+	if err := Load(&old, WithFile("remotecachetest://alias/config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if aliased.Port != 4 || old.OldPort != 4 {
+		t.Errorf("ports = %d, %d", aliased.Port, old.OldPort)
+	}
+}
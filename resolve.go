@@ -0,0 +1,227 @@
+package goconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResolveFunc returns the value that ref, the value of a field's tag, refers
+// to, such as a secret held in a secret store.
+type ResolveFunc func(ctx context.Context, ref string) (string, error)
+
+// resolver is a ResolveFunc that also sees the options of the Load, and can
+// keep what it fetches in fetched for the other fields of the same Load, e.g.
+// because several fields refer to keys of one secret.
+type resolver func(ctx context.Context, ref string, o *options, fetched map[string]interface{}) (string, error)
+
+var (
+	// resolversMu guards resolvers.
+	resolversMu sync.RWMutex
+	// resolvers maps tag names to the resolvers of the references they
+	// hold.
+	resolvers = map[string]resolver{
+		"vault":          resolveVault,
+		"ssm":            resolveSSM,
+		"secretsmanager": resolveSecretsManager,
+		"gcpsecret":      resolveGCPSecret,
+	}
+)
+
+// RegisterResolver makes Load set fields tagged with tag, e.g.
+// `mysecret:"db-password"`, to what fn resolves the tag's value to. Fields are
+// resolved as SourceSecrets. Registering a known tag replaces its resolver.
+func RegisterResolver(tag string, fn ResolveFunc) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	resolvers[tag] = func(ctx context.Context, ref string, _ *options, _ map[string]interface{}) (string, error) {
+		return fn(ctx, ref)
+	}
+}
+
+// hasResolverTags reports whether the struct type t has fields tagged with a
+// resolver's tag, whose values may change without the config files changing.
+func hasResolverTags(t reflect.Type) bool {
+	return hasResolverTagsIn(t, map[reflect.Type]bool{})
+}
+
+// hasResolverTagsIn is hasResolverTags, skipping the types in seen, which
+// have been looked at already, so that recursive types end.
+func hasResolverTagsIn(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || parsesItself(t) || seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !exported(sf) {
+			continue
+		}
+		if isResolved(sf) {
+			return true
+		}
+		if k := structType(sf.Type).Kind(); k == reflect.Struct && hasResolverTagsIn(sf.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// isResolved reports whether sf is tagged with a resolver's tag.
+func isResolved(sf reflect.StructField) bool {
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	for tag := range resolvers {
+		if sf.Tag.Get(tag) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSecrets sets the fields of the struct pointed to by val that are
+// tagged with a resolver's tag to what their references resolve to, with ctx.
+// Each reference is resolved once per call, and errors for every field are
+// collected.
+func resolveSecrets(ctx context.Context, val interface{}, o *options) error {
+	value := reflect.ValueOf(val)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return errors.New("Expected a pointer to a Struct")
+	}
+	resolversMu.RLock()
+	tags := make(map[string]resolver, len(resolvers))
+	for tag, fn := range resolvers {
+		tags[tag] = fn
+	}
+	resolversMu.RUnlock()
+	r := &secretResolver{ctx: ctx, o: o, tags: tags, resolved: map[string]string{}, fetched: map[string]interface{}{}, resolving: map[reflect.Type]bool{}}
+	r.resolveStruct(value.Elem(), fieldPath{})
+	if r.errs != nil {
+		return errors.New(strings.Join(r.errs, ". "))
+	}
+	return nil
+}
+
+type secretResolver struct {
+	ctx  context.Context
+	o    *options
+	tags map[string]resolver
+	// resolved caches the values of the references resolved so far, by tag
+	// and reference.
+	resolved map[string]string
+	// fetched is shared by the resolvers.
+	fetched map[string]interface{}
+	// resolving holds the struct types being resolved, so that nil pointers
+	// to them aren't allocated over and over in recursive types.
+	resolving map[reflect.Type]bool
+	errs      []string
+}
+
+func (r *secretResolver) resolveStruct(value reflect.Value, path fieldPath) {
+	if t := value.Type(); !r.resolving[t] {
+		r.resolving[t] = true
+		defer delete(r.resolving, t)
+	}
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		field := value.Field(i)
+		if !exported(structField) {
+			continue
+		}
+		tag, ref := r.secretTag(structField)
+		if tag == "" {
+			r.resolveSection(field, path.child(structField))
+			continue
+		}
+		s, err := r.resolve(tag, ref)
+		if err == nil {
+			err = setFromString(field, s)
+		}
+		if err != nil {
+			r.errs = append(r.errs, fmt.Sprintf("%s %s: %s", tag, ref, err))
+		}
+	}
+}
+
+// resolveSection resolves the fields of the struct held by field, itself or
+// pointed to by it. A nil pointer to a struct with fields to resolve is set
+// to one holding its defaults, as a config file setting one of its keys
+// would.
+func (r *secretResolver) resolveSection(field reflect.Value, path fieldPath) {
+	if inner, ok := structValue(field); ok {
+		if !parsesItself(inner.Type()) {
+			r.resolveStruct(inner, path)
+		}
+		return
+	}
+	if !isPointerSection(field.Type()) || r.resolving[field.Type().Elem()] || !hasResolverTags(field.Type()) {
+		return
+	}
+	section, err := newSection(field.Type(), path)
+	if err != nil {
+		r.errs = append(r.errs, err.Error())
+		return
+	}
+	r.resolveStruct(section.Elem(), path)
+	field.Set(section)
+}
+
+// secretTag returns the resolver tag of sf and its reference, if it has one.
+func (r *secretResolver) secretTag(sf reflect.StructField) (string, string) {
+	for tag := range r.tags {
+		if ref := sf.Tag.Get(tag); ref != "" {
+			return tag, ref
+		}
+	}
+	return "", ""
+}
+
+func (r *secretResolver) resolve(tag, ref string) (string, error) {
+	key := tag + "\x00" + ref
+	if s, ok := r.resolved[key]; ok {
+		return s, nil
+	}
+	s, err := r.tags[tag](r.ctx, ref, r.o, r.fetched)
+	if err != nil {
+		return "", err
+	}
+	r.resolved[key] = s
+	return s, nil
+}
+
+// secretString converts a value decoded from a JSON secret into the string a
+// field is set from: strings as they are, anything else as JSON.
+func secretString(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// secretKey returns the value of key in secret, a JSON object.
+func secretKey(secret, key string) (string, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &data); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %s", err)
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("no key %q in secret", key)
+	}
+	return secretString(value)
+}
+
+// cachedToken is an access token cached until it is about to expire.
+type cachedToken struct {
+	token   string
+	expires time.Time
+}
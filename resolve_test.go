@@ -0,0 +1,54 @@
+package goconfig
+
+import (
+	"context"
+	"testing"
+)
+
+type pointerSectionConfig struct {
+	DB    *dbSection            `yaml:"db"`
+	Cache *cacheSection         `yaml:"cache"`
+	Next  *pointerSectionConfig `yaml:"next"`
+}
+
+type dbSection struct {
+	Host     string `yaml:"host" default:"localhost"`
+	Password string `yaml:"password" sectiontest:"db" flag:"db-password"`
+}
+
+type cacheSection struct {
+	Size int `yaml:"size" flag:"cache-size"`
+}
+
+func TestResolvePointerSections(t *testing.T) {
+	RegisterResolver("sectiontest", func(ctx context.Context, ref string) (string, error) {
+		return "secret-" + ref, nil
+	})
+	t.Run("set", func(t *testing.T) {
+		var c pointerSectionConfig
+		if err := Load(&c, WithFile(writeConfigFile(t, "config.yaml", "db: {host: db.example.com}\n"))); err != nil {
+			t.Fatal(err)
+		}
+		if c.DB.Host != "db.example.com" || c.DB.Password != "secret-db" {
+			t.Errorf("db = %+v", *c.DB)
+		}
+	})
+	t.Run("nil", func(t *testing.T) {
+		var c pointerSectionConfig
+		if err := Load(&c, WithFile(writeConfigFile(t, "config.yaml", "{}\n"))); err != nil {
+			t.Fatal(err)
+		}
+		if c.DB == nil {
+			t.Fatal("db not allocated for its secret")
+		}
+		if c.DB.Host != "localhost" || c.DB.Password != "secret-db" {
+			t.Errorf("db = %+v", *c.DB)
+		}
+		if c.Cache != nil {
+			t.Errorf("cache allocated without a secret: %+v", *c.Cache)
+		}
+		if c.Next != nil {
+			t.Error("recursive section allocated")
+		}
+	})
+}
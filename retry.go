@@ -0,0 +1,77 @@
+package goconfig
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// Retry configures how loads retry reading config files and fetching remote
+// configs that fail, such as with a momentary network or NFS failure at
+// startup. Decoding and validation errors are never retried.
+type Retry struct {
+	// Attempts is how many times a read is tried in all, 3 if zero.
+	Attempts int
+	// Backoff is the delay before the first retry, 100ms if zero. It doubles
+	// for each later retry, up to MaxBackoff, 5s if zero.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retryable reports whether a read that failed with err is worth
+	// retrying. By default every error is, except for missing files, denied
+	// permissions and the context of the load being done.
+	Retryable func(err error) bool
+}
+
+// WithRetry retries failed reads of config files and remote configs as r
+// says, logging a warning for each retry.
+func WithRetry(r Retry) Option {
+	return func(o *options) {
+		o.retry = &r
+	}
+}
+
+// retryable is the default Retry.Retryable.
+func retryable(err error) bool {
+	return !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// retry calls fn until it succeeds, fails with an error that isn't retryable
+// or runs out of attempts, with the retries of the load, and returns its last
+// error. source names what fn reads, for the log.
+func (r *docReader) retry(source string, fn func() error) error {
+	err := fn()
+	if r.o.retry == nil || err == nil {
+		return err
+	}
+	settings := *r.o.retry
+	if settings.Attempts == 0 {
+		settings.Attempts = 3
+	}
+	if settings.Backoff == 0 {
+		settings.Backoff = 100 * time.Millisecond
+	}
+	if settings.MaxBackoff == 0 {
+		settings.MaxBackoff = 5 * time.Second
+	}
+	if settings.Retryable == nil {
+		settings.Retryable = retryable
+	}
+	delay := settings.Backoff
+	for attempt := 1; attempt < settings.Attempts && settings.Retryable(err); attempt++ {
+		r.o.log().Warn("config read failed, retrying", "source", source, "error", err, "attempt", attempt, "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-r.ctx.Done():
+			return err
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+		if delay *= 2; delay > settings.MaxBackoff {
+			delay = settings.MaxBackoff
+		}
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package goconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Saves the config back to its file, the first file given with WithFile or
+// WithFiles or else the configured filename, in the format picked from the
+// extension or given with WithFormat. Only YAML, JSON and TOML can be written.
+// Keys follow the order of the struct fields.
+//
+// The file is replaced atomically, keeping its permissions if it already
+// exists. Only what may have come from the file is written: fields that are
+// secret, tagged with a resolver's tag or read from the environment keep the
+// value the file has for them, or are left out if it has none, so that
+// secrets and values from the environment don't end up in the file. Configs
+// with migrations are written with the current version under the `version`
+// key.
+func Save(config interface{}, opts ...Option) error {
+	c := configOf(config)
+	if err := checkPointer(c); err != nil {
+		return err
+	}
+	o := newOptions(opts)
+	filename := c.GetFilename()
+	if len(o.files) > 0 {
+		filename = o.files[0]
+	}
+	if filename == "" {
+		return errors.New("no config file to save to")
+	}
+	if u, _, ok := remoteURL(filename); ok {
+		return fmt.Errorf("can't save to remote config %s", u.Redacted())
+	}
+	format, unmarshal, err := decoderFor(filename, o.format)
+	if err != nil {
+		return err
+	}
+	e := nodeEncoder{saving: true}
+	if o.dotEnv != nil && !o.skipEnv {
+		if e.env, err = readDotEnv(o.dotEnv); err != nil {
+			return err
+		}
+	}
+	if data, err := os.ReadFile(filename); err == nil {
+		if tree, err := decodeToTree(data, unmarshal); err == nil {
+			if isSOPS(tree) {
+				return fmt.Errorf("can't save over SOPS-encrypted config %s", filename)
+			}
+			e.original = tree
+		}
+	}
+	c.RLock()
+	if !o.skipEnv {
+		prefix := o.envPrefixFor(c)
+		e.envPrefix = &prefix
+	}
+	target := configTarget(c)
+	node, err := e.node(reflect.ValueOf(target), false)
+	c.RUnlock()
+	if err != nil {
+		return err
+	}
+	if m := migrationsFor(reflect.TypeOf(target)); m != nil {
+		setVersion(node, m.current)
+	}
+	data, err := marshalNode(node, format)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
+
+// setVersion makes version the first key of the mapping node, replacing any
+// version it already has.
+func setVersion(node *yaml.Node, version int) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	content := []*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: versionKey},
+		{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)},
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != versionKey {
+			content = append(content, node.Content[i], node.Content[i+1])
+		}
+	}
+	node.Content = content
+}
+
+// writeFileAtomic writes data to a temporary file beside filename and renames
+// it over filename, so that readers never see a partly written file.
+func writeFileAtomic(filename string, data []byte) error {
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
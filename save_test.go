@@ -0,0 +1,71 @@
+package goconfig
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+type saveConfig struct {
+	Name     string `yaml:"name"`
+	Port     int    `yaml:"port" env:"SAVE_TEST_PORT"`
+	Password string `yaml:"password" env:"SAVE_TEST_PASSWORD"`
+	Token    string `yaml:"token" secret:"true"`
+	Key      Secret `yaml:"key"`
+	Vaulted  string `yaml:"vaulted" savetest:"ref"`
+	Nested   struct {
+		Host string `yaml:"host" env:"SAVE_TEST_HOST"`
+		Zone string `yaml:"zone"`
+	} `yaml:"nested"`
+}
+
+func TestSaveWritesOnlyFileValues(t *testing.T) {
+	RegisterResolver("savetest", func(ctx context.Context, ref string) (string, error) {
+		return "resolved-secret", nil
+	})
+	t.Setenv("SAVE_TEST_PORT", "9090")
+	t.Setenv("SAVE_TEST_PASSWORD", "env-secret")
+	t.Setenv("SAVE_TEST_HOST", "env-host")
+	filename := writeConfigFile(t, "config.yaml", "name: svc\nport: 80\ntoken: file-token\nnested:\n  zone: a\n")
+	var c saveConfig
+	if err := Load(&c, WithFile(filename)); err != nil {
+		t.Fatal(err)
+	}
+	c.Name = "renamed"
+	c.Key = "runtime-key"
+	if err := Save(&c, WithFile(filename)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"env-secret", "resolved-secret", "runtime-key", "env-host", "9090"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("saved file holds %q:\n%s", secret, data)
+		}
+	}
+	var saved map[string]interface{}
+	if err := yaml.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"name": "renamed", "port": 80, "token": "file-token", "zone": "a"}
+	nested, _ := saved["nested"].(map[string]interface{})
+	got := map[string]interface{}{"name": saved["name"], "port": saved["port"], "token": saved["token"], "zone": nested["zone"]}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	for _, key := range []string{"password", "key", "vaulted"} {
+		if _, ok := saved[key]; ok {
+			t.Errorf("saved file has %s:\n%s", key, data)
+		}
+	}
+	if _, ok := nested["host"]; ok {
+		t.Errorf("saved file has nested.host:\n%s", data)
+	}
+}
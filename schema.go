@@ -0,0 +1,215 @@
+package goconfig
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schemaDraft is the JSON Schema dialect SchemaFor produces.
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// SchemaFor returns a JSON Schema describing the config files accepted for c,
+// which may be a config, a struct or a pointer to one. Keys come from the
+// yaml tags, `required` fields are listed as required, `desc` tags become
+// descriptions and `default` tags defaults, and the min, max, oneof and regexp
+// rules of `validate` tags are translated where JSON Schema has an equivalent.
+// The schema can be used for editor completion or to check config files in
+// CI.
+func SchemaFor(c interface{}) ([]byte, error) {
+	if cfg, ok := c.(Configterface); ok {
+		c = configTarget(cfg)
+	}
+	t := reflect.TypeOf(c)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, ErrNotPointer
+	}
+	schema := (&schemaBuilder{seen: map[reflect.Type]bool{}}).typeSchema(t)
+	schema["$schema"] = schemaDraft
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+type schemaBuilder struct {
+	// seen holds the struct types being described, to cut recursion short.
+	seen map[reflect.Type]bool
+}
+
+// typeSchema returns the schema of values of type t.
+func (b *schemaBuilder) typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := decodeHookFor(t); ok {
+		// Whatever the hook accepts.
+		return map[string]interface{}{}
+	}
+	switch {
+	case t == durationType:
+		return map[string]interface{}{"type": "string", "pattern": `^([-+]?([0-9]*(\.[0-9]*)?[a-zµμ]+)+|0)$`}
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == levelType:
+		return map[string]interface{}{"type": "string", "enum": []string{"debug", "info", "warn", "error", "verbose", "warning"}}
+	case t == byteSizeType:
+		return map[string]interface{}{"type": []string{"integer", "string"}, "pattern": `^[0-9.]+ *[a-zA-Z]*$`, "minimum": 0}
+	case t == urlType:
+		return map[string]interface{}{"type": "string", "format": "uri"}
+	case t == ipType || t == addrType:
+		return map[string]interface{}{"type": "string", "anyOf": []interface{}{
+			map[string]interface{}{"format": "ipv4"}, map[string]interface{}{"format": "ipv6"},
+		}}
+	case t == ipNetType || t == prefixType:
+		return map[string]interface{}{"type": "string"}
+	case parsesItself(t):
+		return map[string]interface{}{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if b.seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		b.seen[t] = true
+		defer delete(b.seen, t)
+		schema := map[string]interface{}{"type": "object"}
+		properties := map[string]interface{}{}
+		var required []string
+		b.addFields(t, properties, &required)
+		schema["properties"] = properties
+		if required != nil {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// addFields adds the schemas of the fields of the struct type t to properties,
+// flattening inlined structs, and the keys of its required fields to required.
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !exported(sf) || sf.Type == mutexType || sf.Type == rwMutexType || sf.Tag.Get("yaml") == "-" {
+			continue
+		}
+		key := yamlKey(sf)
+		if ft := sf.Type; key == "" {
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.addFields(ft, properties, required)
+				continue
+			}
+		}
+		schema := b.typeSchema(sf.Type)
+		layout := sf.Tag.Get("layout")
+		if layout != "" {
+			// Times with a layout aren't RFC 3339 date-times.
+			delete(schema, "format")
+			if items, ok := schema["items"].(map[string]interface{}); ok {
+				delete(items, "format")
+			}
+		}
+		if desc := sf.Tag.Get("desc"); desc != "" {
+			schema["description"] = desc
+		}
+		if _, ok := sf.Tag.Lookup("deprecated"); ok {
+			schema["deprecated"] = true
+		}
+		if def, ok := sf.Tag.Lookup("default"); ok {
+			if layout != "" {
+				schema["default"] = def
+			} else if value, ok := schemaValue(sf.Type, def); ok {
+				schema["default"] = value
+			}
+		}
+		for _, r := range parseRules(sf.Tag.Get("validate")) {
+			addRule(schema, sf.Type, r)
+		}
+		properties[key] = schema
+		if sf.Tag.Get("required") == "true" {
+			*required = append(*required, key)
+		}
+	}
+}
+
+// addRule adds the JSON Schema equivalent of a validate rule for a field of
+// type t to its schema, if there is one.
+func addRule(schema map[string]interface{}, t reflect.Type, r rule) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch r.name {
+	case "min", "max":
+		if t == durationType || t == byteSizeType {
+			return
+		}
+		bound, err := strconv.ParseFloat(r.param, 64)
+		if err != nil {
+			return
+		}
+		var keyword string
+		switch t.Kind() {
+		case reflect.String:
+			keyword = "Length"
+		case reflect.Slice, reflect.Array:
+			keyword = "Items"
+		case reflect.Map:
+			keyword = "Properties"
+		}
+		if keyword == "" {
+			keyword = map[string]string{"min": "minimum", "max": "maximum"}[r.name]
+		} else {
+			keyword = r.name + keyword
+		}
+		schema[keyword] = bound
+	case "oneof":
+		var enum []interface{}
+		for _, option := range strings.Fields(r.param) {
+			if value, ok := schemaValue(t, option); ok {
+				enum = append(enum, value)
+			}
+		}
+		schema["enum"] = enum
+	case "regexp":
+		if t.Kind() == reflect.String {
+			schema["pattern"] = r.param
+		}
+	}
+}
+
+// schemaValue converts s, as it would be parsed into a field of type t, into a
+// value for a schema.
+func schemaValue(t reflect.Type, s string) (interface{}, bool) {
+	v := reflect.New(t).Elem()
+	if err := setFromString(v, s); err != nil {
+		return nil, false
+	}
+	v = reflect.Indirect(v)
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), true
+	}
+	if isSection(v.Type()) || v.Type() == timeType {
+		return s, true
+	}
+	return v.Interface(), true
+}
@@ -0,0 +1,39 @@
+package goconfig
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+)
+
+// Secret is a string that masks itself when formatted, so that passwords and
+// tokens don't leak when a config is printed with %v or %#v or logged.
+// Config files and environment variables fill it like any string, and Save
+// writes out its actual value; Dump and Diff mask it as they do fields tagged
+// `secret:"true"`.
+type Secret string
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// String returns a mask, or "" if the secret is empty.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString masks the secret for %#v.
+func (s Secret) GoString() string {
+	return fmt.Sprintf("goconfig.Secret(%q)", s.String())
+}
+
+// LogValue masks the secret for log/slog.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// Value returns the actual secret.
+func (s Secret) Value() string {
+	return string(s)
+}
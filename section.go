@@ -0,0 +1,149 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var (
+	sectionsMu sync.RWMutex
+	// sections holds the structs registered with RegisterSection, by key.
+	sections = map[string]interface{}{}
+)
+
+// RegisterSection registers section, a pointer to a struct, to be loaded from
+// the top-level key of the config files, as with
+//
+//	func init() {
+//		goconfig.RegisterSection("cache", &cacheConfig)
+//	}
+//
+// so that a subsystem or plugin can own its part of the config without the
+// application's config struct knowing about it. Loads given WithSections
+// decode each section into its struct, along with its `default` and `env`
+// tags, and validate it as they validate the config itself. The sections and
+// the config are only replaced if all of them are valid. A section embedding
+// Config is locked while it is replaced, so it can be read safely during
+// reloads. Registering a key again replaces its section.
+func RegisterSection(key string, section interface{}) {
+	sectionsMu.Lock()
+	defer sectionsMu.Unlock()
+	sections[key] = section
+}
+
+// WithSections loads the sections registered with RegisterSection along with
+// the config.
+func WithSections() Option {
+	return func(o *options) {
+		o.sections = true
+	}
+}
+
+// sectionLoad is a section being loaded.
+type sectionLoad struct {
+	section interface{}
+	// scratch is the copy of the section the sources are applied to.
+	scratch interface{}
+}
+
+// loadSections takes the registered sections out of doc and applies them,
+// their defaults and environment variables to copies of their structs, which
+// are validated and returned to be swapped in. config points to a copy of the
+// config being loaded.
+func loadSections(config interface{}, doc *document, o *options, prefix string) ([]sectionLoad, error) {
+	sectionsMu.RLock()
+	keys := make([]string, 0, len(sections))
+	registered := make(map[string]interface{}, len(sections))
+	for key, section := range sections {
+		keys = append(keys, key)
+		registered[key] = section
+	}
+	sectionsMu.RUnlock()
+	sort.Strings(keys)
+	target := reflect.ValueOf(config).Elem()
+	var loads []sectionLoad
+	var errs []error
+	for _, key := range keys {
+		section := registered[key]
+		if _, ok := lookupField(target, key); ok {
+			errs = append(errs, fmt.Errorf("section %s: the config already has a %s key", key, key))
+			continue
+		}
+		value := reflect.ValueOf(section)
+		if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+			errs = append(errs, fmt.Errorf("section %s: %w", key, ErrNotPointer))
+			continue
+		}
+		var tree map[string]interface{}
+		if raw, ok := doc.treeValue(key); ok {
+			switch t := raw.(type) {
+			case nil:
+			case map[string]interface{}:
+				tree = t
+			default:
+				errs = append(errs, &SourceError{Source: SourceFile, Err: fmt.Errorf("section %s: expected a map, got %v", key, t)})
+			}
+			delete(doc.tree, key)
+			doc.merged = true
+		}
+		locked, ok := section.(Configterface)
+		if ok {
+			locked.RLock()
+		}
+		scratch := copyConfig(section)
+		if ok {
+			locked.RUnlock()
+		}
+		if err := loadSection(scratch, tree, o, prefix); err != nil {
+			errs = append(errs, fmt.Errorf("section %s: %w", key, err))
+			continue
+		}
+		loads = append(loads, sectionLoad{section: section, scratch: scratch})
+	}
+	return loads, joinErrors(errs)
+}
+
+// treeValue returns the value of the top-level key of d, which may be nil.
+func (d *document) treeValue(key string) (interface{}, bool) {
+	if d == nil {
+		return nil, false
+	}
+	v, ok := d.tree[key]
+	return v, ok
+}
+
+// loadSection applies the defaults of the struct pointed to by scratch, tree
+// and the environment to it, then validates it, reporting all the errors it
+// finds.
+func loadSection(scratch interface{}, tree map[string]interface{}, o *options, prefix string) error {
+	var errs []error
+	if err := applyDefaults(scratch); err != nil {
+		errs = append(errs, err)
+	}
+	if tree != nil {
+		doc := &document{tree: tree, merged: true}
+		if err := doc.decode(scratch, o.strict); err != nil {
+			errs = append(errs, &SourceError{Source: SourceFile, Err: err})
+		}
+	}
+	if !o.skipEnv {
+		if err := parseEnv(scratch, prefix, o.env); err != nil {
+			errs = append(errs, &SourceError{Source: SourceEnv, Err: err})
+		}
+	}
+	if err := validate(scratch, prefix); err != nil {
+		errs = append(errs, err)
+	}
+	return joinErrors(errs)
+}
+
+// swap replaces the section with its loaded copy.
+func (s sectionLoad) swap() {
+	if locked, ok := s.section.(Configterface); ok {
+		locked.Lock()
+		defer locked.Unlock()
+	}
+	assignConfig(reflect.ValueOf(s.section).Elem(), reflect.ValueOf(s.scratch).Elem())
+}
@@ -0,0 +1,33 @@
+package goconfig
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestListenForSignalsWithoutSignals(t *testing.T) {
+	saved := reloadSignals
+	reloadSignals = nil
+	t.Cleanup(func() { reloadSignals = saved })
+
+	var c struct {
+		Port int `yaml:"port"`
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := ListenForSignalsContext(ctx, &c); !errors.Is(err, ErrNoReloadSignal) {
+		t.Fatalf("err = %v, want ErrNoReloadSignal", err)
+	}
+	if StateOf(&c).IsListening() {
+		t.Fatal("config marked listening without a signal handler")
+	}
+	// With a signal to listen for, the failed call mustn't stop it.
+	if err := ListenForSignalsContext(ctx, &c, WithSignals(os.Interrupt)); err != nil {
+		t.Fatal(err)
+	}
+	if !StateOf(&c).IsListening() {
+		t.Fatal("config not marked listening")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package goconfig
+
+import (
+	"os"
+	"syscall"
+)
+
+// reloadSignals are the signals ListenForSignals reloads the config on by
+// default.
+var reloadSignals = []os.Signal{syscall.SIGHUP}
+
+// dumpSignals are the signals DumpOnSignal dumps the config on by default.
+var dumpSignals = []os.Signal{syscall.SIGUSR2}
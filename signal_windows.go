@@ -0,0 +1,15 @@
+//go:build windows
+
+package goconfig
+
+import (
+	"os"
+)
+
+// reloadSignals is empty, as Windows has no signal that can be sent to a
+// process to ask it to reload, so ListenForSignals needs WithSignals.
+var reloadSignals []os.Signal
+
+// dumpSignals is empty for the same reason, so DumpOnSignal needs to be given
+// signals.
+var dumpSignals []os.Signal
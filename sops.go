@@ -0,0 +1,456 @@
+package goconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sopsKey is the top-level key SOPS keeps the metadata of an encrypted file
+// under.
+const sopsKey = "sops"
+
+// sopsMetadata is the part of the metadata of a SOPS-encrypted file needed to
+// decrypt it.
+type sopsMetadata struct {
+	MAC                     string        `yaml:"mac"`
+	LastModified            string        `yaml:"lastmodified"`
+	UnencryptedSuffix       string        `yaml:"unencrypted_suffix"`
+	EncryptedSuffix         string        `yaml:"encrypted_suffix"`
+	UnencryptedRegex        string        `yaml:"unencrypted_regex"`
+	EncryptedRegex          string        `yaml:"encrypted_regex"`
+	UnencryptedCommentRegex string        `yaml:"unencrypted_comment_regex"`
+	EncryptedCommentRegex   string        `yaml:"encrypted_comment_regex"`
+	MACOnlyEncrypted        bool          `yaml:"mac_only_encrypted"`
+	KeyGroups               []interface{} `yaml:"key_groups"`
+	Age                     []struct {
+		Enc string `yaml:"enc"`
+	} `yaml:"age"`
+	KMS []struct {
+		ARN     string            `yaml:"arn"`
+		Context map[string]string `yaml:"context"`
+		Enc     string            `yaml:"enc"`
+	} `yaml:"kms"`
+	GCPKMS []struct {
+		ResourceID string `yaml:"resource_id"`
+		Enc        string `yaml:"enc"`
+	} `yaml:"gcp_kms"`
+	Vault []struct {
+		Address    string `yaml:"vault_address"`
+		EnginePath string `yaml:"engine_path"`
+		KeyName    string `yaml:"key_name"`
+		Enc        string `yaml:"enc"`
+	} `yaml:"hc_vault"`
+	PGP []struct {
+		Enc string `yaml:"enc"`
+	} `yaml:"pgp"`
+}
+
+// isSOPS reports whether a document tree is that of a SOPS-encrypted file.
+func isSOPS(tree map[string]interface{}) bool {
+	meta, ok := tree[sopsKey].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, hasMAC := meta["mac"]
+	_, hasLastModified := meta["lastmodified"]
+	return hasMAC && hasLastModified
+}
+
+// decryptSOPS replaces the tree of doc, a SOPS-encrypted YAML or JSON file,
+// with its decrypted values, having checked the file's MAC. The data key is
+// decrypted with the first of the file's age, AWS KMS, GCP KMS, Vault transit
+// or PGP keys that is available.
+func decryptSOPS(ctx context.Context, doc *document, o *options) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(doc.data, &root); err != nil {
+		return err
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return errors.New("expected a map")
+	}
+	top := root.Content[0]
+	var meta sopsMetadata
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		if top.Content[i].Value == sopsKey {
+			if err := top.Content[i+1].Decode(&meta); err != nil {
+				return fmt.Errorf("metadata: %w", err)
+			}
+		}
+	}
+	if len(meta.KeyGroups) > 0 {
+		return errors.New("files with key groups aren't supported")
+	}
+	key, err := sopsDataKey(ctx, &meta, o)
+	if err != nil {
+		return err
+	}
+	d := &sopsDecrypter{meta: &meta, key: key, hash: sha512.New()}
+	if meta.MACOnlyEncrypted {
+		// SOPS starts the hash with these bytes so that the MAC differs from
+		// that of the same values with mac_only_encrypted unset.
+		d.hash.Write(sopsMACOnlyEncrypted)
+	}
+	if d.unencryptedRegex, err = compileOptional(meta.UnencryptedRegex); err != nil {
+		return err
+	}
+	if d.encryptedRegex, err = compileOptional(meta.EncryptedRegex); err != nil {
+		return err
+	}
+	tree, err := d.node(top, nil)
+	if err != nil {
+		return err
+	}
+	if err := d.checkMAC(); err != nil {
+		return err
+	}
+	doc.tree = tree.(map[string]interface{})
+	doc.merged = true
+	return nil
+}
+
+var sopsMACOnlyEncrypted = []byte{
+	0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0x0b,
+	0x0b, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69,
+}
+
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// sopsDecrypter walks a SOPS-encrypted document in order, decrypting its
+// values and hashing them to check the MAC.
+type sopsDecrypter struct {
+	meta             *sopsMetadata
+	key              []byte
+	hash             hash.Hash
+	unencryptedRegex *regexp.Regexp
+	encryptedRegex   *regexp.Regexp
+}
+
+// node returns the decrypted value of n, found at path. The values of lists
+// share the path of the list.
+func (d *sopsDecrypter) node(n *yaml.Node, path []string) (interface{}, error) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i].Value
+			if len(path) == 0 && key == sopsKey {
+				continue
+			}
+			v, err := d.node(n.Content[i+1], append(path[:len(path):len(path)], key))
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(n.Content))
+		for _, item := range n.Content {
+			v, err := d.node(item, path)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case yaml.AliasNode:
+		return d.node(n.Alias, path)
+	}
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+	encrypted := d.encrypted(path, v)
+	if encrypted {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected an encrypted value", strings.Join(path, "."))
+		}
+		var err error
+		if v, err = sopsDecryptValue(s, d.key, strings.Join(path, ":")+":"); err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.Join(path, "."), err)
+		}
+	}
+	if encrypted || !d.meta.MACOnlyEncrypted {
+		d.hash.Write(sopsBytes(v))
+	}
+	return v, nil
+}
+
+// encrypted reports whether the value v at path should be encrypted according
+// to the rules of the file. Comments aren't kept, so with the comment rules a
+// value is taken to be encrypted when it looks so.
+func (d *sopsDecrypter) encrypted(path []string, v interface{}) bool {
+	m := d.meta
+	anyMatch := func(match func(string) bool) bool {
+		for _, p := range path {
+			if match(p) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case m.EncryptedSuffix != "":
+		return anyMatch(func(p string) bool { return strings.HasSuffix(p, m.EncryptedSuffix) })
+	case d.unencryptedRegex != nil:
+		return !anyMatch(d.unencryptedRegex.MatchString)
+	case d.encryptedRegex != nil:
+		return anyMatch(d.encryptedRegex.MatchString)
+	case m.UnencryptedCommentRegex != "" || m.EncryptedCommentRegex != "":
+		s, ok := v.(string)
+		return ok && sopsValuePattern.MatchString(s)
+	}
+	suffix := m.UnencryptedSuffix
+	if suffix == "" {
+		suffix = "_unencrypted"
+	}
+	return !anyMatch(func(p string) bool { return strings.HasSuffix(p, suffix) })
+}
+
+// checkMAC checks the hash of the values against the MAC of the file, which
+// is encrypted with its last modification time as additional data.
+func (d *sopsDecrypter) checkMAC() error {
+	lastModified, err := time.Parse(time.RFC3339, d.meta.LastModified)
+	if err != nil {
+		return fmt.Errorf("lastmodified: %w", err)
+	}
+	mac, err := sopsDecryptValue(d.meta.MAC, d.key, lastModified.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("mac: %w", err)
+	}
+	if mac != fmt.Sprintf("%X", d.hash.Sum(nil)) {
+		return errors.New("MAC mismatch, the file has been tampered with")
+	}
+	return nil
+}
+
+var sopsValuePattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.+),iv:(.+),tag:(.+),type:(.+)\]`)
+
+// sopsDecryptValue decrypts a value encrypted by SOPS, returning it with its
+// original type.
+func sopsDecryptValue(s string, key []byte, additionalData string) (interface{}, error) {
+	if s == "" {
+		return "", nil
+	}
+	match := sopsValuePattern.FindStringSubmatch(s)
+	if match == nil {
+		return nil, errors.New("not an encrypted value")
+	}
+	var parts [3][]byte
+	for i := range parts {
+		var err error
+		if parts[i], err = base64.StdEncoding.DecodeString(match[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, errors.New("can't decrypt value")
+	}
+	switch match[4] {
+	case "str", "bytes", "comment":
+		return string(plain), nil
+	case "int":
+		return strconv.Atoi(string(plain))
+	case "float":
+		return strconv.ParseFloat(string(plain), 64)
+	case "bool":
+		return strconv.ParseBool(string(plain))
+	case "time":
+		var t time.Time
+		err := t.UnmarshalText(plain)
+		return t, err
+	}
+	return nil, fmt.Errorf("unknown type %q", match[4])
+}
+
+// sopsBytes returns the bytes SOPS hashes for a value.
+func sopsBytes(v interface{}) []byte {
+	switch t := v.(type) {
+	case string:
+		return []byte(t)
+	case int:
+		return []byte(strconv.Itoa(t))
+	case float64:
+		return []byte(strconv.FormatFloat(t, 'f', -1, 64))
+	case bool:
+		if t {
+			return []byte("True")
+		}
+		return []byte("False")
+	case time.Time:
+		data, _ := t.MarshalText()
+		return data
+	}
+	return []byte(fmt.Sprint(v))
+}
+
+// sopsDataKey decrypts the data key of a file with the first of its master
+// keys that works.
+func sopsDataKey(ctx context.Context, meta *sopsMetadata, o *options) ([]byte, error) {
+	var errs []string
+	fail := func(kind string, err error) {
+		errs = append(errs, fmt.Sprintf("%s: %s", kind, err))
+	}
+	if len(meta.Age) > 0 {
+		identities, err := ageIdentities(o)
+		for _, k := range meta.Age {
+			if err != nil {
+				break
+			}
+			var key []byte
+			if key, err = ageDecrypt([]byte(k.Enc), identities); err == nil {
+				return key, nil
+			}
+		}
+		fail("age", err)
+	}
+	for _, k := range meta.KMS {
+		key, err := sopsKMSKey(ctx, k.ARN, k.Context, k.Enc, o)
+		if err == nil {
+			return key, nil
+		}
+		fail("kms "+k.ARN, err)
+	}
+	for _, k := range meta.GCPKMS {
+		key, err := sopsGCPKMSKey(ctx, k.ResourceID, k.Enc, o)
+		if err == nil {
+			return key, nil
+		}
+		fail("gcp_kms "+k.ResourceID, err)
+	}
+	for _, k := range meta.Vault {
+		key, err := sopsVaultKey(ctx, k.Address, k.EnginePath, k.KeyName, k.Enc, o)
+		if err == nil {
+			return key, nil
+		}
+		fail("hc_vault "+k.Address, err)
+	}
+	for _, k := range meta.PGP {
+		key, err := sopsPGPKey(ctx, k.Enc)
+		if err == nil {
+			return key, nil
+		}
+		fail("pgp", err)
+	}
+	if errs == nil {
+		return nil, errors.New("no supported key to decrypt the file with")
+	}
+	return nil, fmt.Errorf("can't decrypt the data key. %s", strings.Join(errs, ". "))
+}
+
+// sopsKMSKey decrypts a data key with AWS KMS, in the region of the key.
+func sopsKMSKey(ctx context.Context, arn string, encryptionContext map[string]string, enc string, o *options) ([]byte, error) {
+	a := awsSettings(o)
+	if parts := strings.Split(arn, ":"); len(parts) > 3 && parts[3] != "" {
+		a.Region = parts[3]
+	}
+	var out struct {
+		Plaintext []byte
+	}
+	body := map[string]interface{}{"CiphertextBlob": enc, "KeyId": arn}
+	if len(encryptionContext) > 0 {
+		body["EncryptionContext"] = encryptionContext
+	}
+	if err := a.callJSON(ctx, "kms", "TrentService.Decrypt", body, &out); err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// sopsGCPKMSKey decrypts a data key with Google Cloud KMS.
+func sopsGCPKMSKey(ctx context.Context, resourceID, enc string, o *options) ([]byte, error) {
+	resp, err := gcpSettings(o).post(ctx, "https://cloudkms.googleapis.com/v1/"+resourceID+":decrypt", map[string]string{"ciphertext": enc})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// sopsVaultKey decrypts a data key with the transit engine of Vault, at the
+// address recorded in the file.
+func sopsVaultKey(ctx context.Context, address, enginePath, keyName, enc string, o *options) ([]byte, error) {
+	v := Vault{}
+	if o.vault != nil {
+		v = *o.vault
+	}
+	v.Address = address
+	v = v.settings()
+	token, err := v.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Data struct {
+			Plaintext []byte `json:"plaintext"`
+		} `json:"data"`
+	}
+	path := "/v1/" + strings.Trim(enginePath, "/") + "/decrypt/" + keyName
+	if err := v.call(ctx, http.MethodPost, path, token, map[string]string{"ciphertext": enc}, &out); err != nil {
+		return nil, err
+	}
+	return out.Data.Plaintext, nil
+}
+
+// sopsPGPKey decrypts a data key with gpg, or the binary named by
+// SOPS_GPG_EXEC.
+func sopsPGPKey(ctx context.Context, enc string) ([]byte, error) {
+	gpg := os.Getenv("SOPS_GPG_EXEC")
+	if gpg == "" {
+		gpg = "gpg"
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, gpg, "--batch", "--quiet", "--no-tty", "--decrypt")
+	cmd.Stdin = strings.NewReader(enc)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,123 @@
+package goconfig
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fields tagged `ssm:"/myapp/db/password"` are read from SSM Parameter Store,
+// decrypting SecureStrings, and fields tagged
+// `secretsmanager:"myapp/db#password"` from Secrets Manager, taking the given
+// key of a JSON secret or, without a key, the whole secret string.
+//
+// Whole documents are read from ssm://myapp/config.yaml, the parameter
+// /myapp/config.yaml, or secretsmanager://myapp/config.yaml, the secret of
+// that name. An ssm URL ending in a slash reads every parameter below that
+// path instead, mapping /myapp/database/host to database.host.
+
+// resolveSSM returns the value of the SSM parameter named ref.
+func resolveSSM(ctx context.Context, ref string, o *options, _ map[string]interface{}) (string, error) {
+	return getParameter(ctx, awsSettings(o), ref)
+}
+
+func getParameter(ctx context.Context, a AWS, name string) (string, error) {
+	var resp struct {
+		Parameter struct {
+			Value string
+		}
+	}
+	body := map[string]interface{}{"Name": name, "WithDecryption": true}
+	if err := a.callJSON(ctx, "ssm", "AmazonSSM.GetParameter", body, &resp); err != nil {
+		return "", err
+	}
+	return resp.Parameter.Value, nil
+}
+
+// resolveSecretsManager returns the Secrets Manager secret ref refers to, as
+// "name" or "name#key".
+func resolveSecretsManager(ctx context.Context, ref string, o *options, fetched map[string]interface{}) (string, error) {
+	name, key, hasKey := strings.Cut(ref, "#")
+	cacheKey := "secretsmanager\x00" + name
+	secret, ok := fetched[cacheKey].(string)
+	if !ok {
+		var err error
+		if secret, err = getSecretValue(ctx, awsSettings(o), name); err != nil {
+			return "", err
+		}
+		fetched[cacheKey] = secret
+	}
+	if !hasKey {
+		return secret, nil
+	}
+	return secretKey(secret, key)
+}
+
+func getSecretValue(ctx context.Context, a AWS, name string) (string, error) {
+	var resp struct {
+		SecretString string
+	}
+	body := map[string]interface{}{"SecretId": name}
+	if err := a.callJSON(ctx, "secretsmanager", "secretsmanager.GetSecretValue", body, &resp); err != nil {
+		return "", err
+	}
+	return resp.SecretString, nil
+}
+
+// fetchSSM fetches the SSM parameter u refers to, or every parameter below its
+// path if it ends in a slash.
+func fetchSSM(ctx context.Context, u *url.URL, o *options) ([]byte, error) {
+	a := awsSettings(o)
+	name := "/" + u.Host + u.Path
+	if !strings.HasSuffix(name, "/") {
+		value, err := getParameter(ctx, a, name)
+		return []byte(value), notFound(err, "ParameterNotFound")
+	}
+	tree := map[string]interface{}{}
+	token := ""
+	for {
+		var resp struct {
+			Parameters []struct {
+				Name  string
+				Value string
+			}
+			NextToken string
+		}
+		body := map[string]interface{}{"Path": name, "Recursive": true, "WithDecryption": true}
+		if token != "" {
+			body["NextToken"] = token
+		}
+		if err := a.callJSON(ctx, "ssm", "AmazonSSM.GetParametersByPath", body, &resp); err != nil {
+			return nil, err
+		}
+		for _, p := range resp.Parameters {
+			setTreePath(tree, strings.Split(strings.TrimPrefix(p.Name, name), "/"), resolveScalar(p.Value))
+		}
+		if token = resp.NextToken; token == "" {
+			break
+		}
+	}
+	if len(tree) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	return yaml.Marshal(tree)
+}
+
+// fetchSecretsManager fetches the Secrets Manager secret u refers to.
+func fetchSecretsManager(ctx context.Context, u *url.URL, o *options) ([]byte, error) {
+	secret, err := getSecretValue(ctx, awsSettings(o), u.Host+u.Path)
+	return []byte(secret), notFound(err, "ResourceNotFoundException")
+}
+
+// notFound converts an AWS error of the given type into fs.ErrNotExist.
+func notFound(err error, errType string) error {
+	var e *awsError
+	if errors.As(err, &e) && e.Type == errType {
+		return fs.ErrNotExist
+	}
+	return err
+}
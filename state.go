@@ -0,0 +1,125 @@
+package goconfig
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// loadState is what is known of the loads of a config.
+type loadState struct {
+	// loaded is set once a load has succeeded.
+	loaded bool
+	// lastSuccess is the time of the last successful load.
+	lastSuccess time.Time
+	// lastAttempt is the time of the last load, and lastErr its error.
+	lastAttempt time.Time
+	lastErr     error
+	// reloads counts the reloads attempted. lastReload is the time of the
+	// last reload, or failed refresh of a remote source, and lastReloadErr
+	// its error.
+	reloads       int
+	lastReload    time.Time
+	lastReloadErr error
+	// fetched holds the times remote sources were last fetched, by URL with
+	// any password redacted.
+	fetched map[string]time.Time
+	// checksum is the checksum of the files of the last successful Load, nil
+	// if the config was last set some other way.
+	checksum []byte
+}
+
+var (
+	// statesMu guards states.
+	statesMu sync.Mutex
+	// states holds the load states of the configs whose state is kept, as
+	// keepConfig keeps it. The loads of other configs aren't recorded.
+	states = map[Configterface]*loadState{}
+)
+
+// recordLoad records a load of c with options o that failed with err, if not
+// nil. Validate doesn't count as a load.
+func recordLoad(c Configterface, o *options, err error) {
+	if o.dryRun {
+		return
+	}
+	statesMu.Lock()
+	defer statesMu.Unlock()
+	s, ok := states[c]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	s.lastAttempt, s.lastErr = now, err
+	if err == nil {
+		s.loaded = true
+		s.lastSuccess = now
+	}
+	if o.reloading {
+		s.reloads++
+		s.lastReload, s.lastReloadErr = now, err
+	}
+}
+
+// recordFetch records a fetch of the remote source of c, which failed with
+// err if it isn't nil.
+func recordFetch(c Configterface, source string, err error) {
+	statesMu.Lock()
+	defer statesMu.Unlock()
+	s, ok := states[c]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if err != nil {
+		s.lastReload, s.lastReloadErr = now, err
+		return
+	}
+	if s.fetched == nil {
+		s.fetched = map[string]time.Time{}
+	}
+	s.fetched[source] = now
+}
+
+// recordChecksum records that c was last set from files with checksum, nil if
+// it was set otherwise.
+func recordChecksum(c Configterface, checksum []byte) {
+	statesMu.Lock()
+	defer statesMu.Unlock()
+	if s, ok := states[c]; ok {
+		s.checksum = checksum
+	}
+}
+
+// unchangedSince reports whether the files Load read for c with options o are
+// those it was last loaded from, so that reloading them can be skipped. The
+// files that fields are read from through _FILE variables and `envFile` tags
+// count among them. Configs whose values may change without their files
+// changing, as with secret stores, templates or dotenv files, are always
+// reloaded.
+func unchangedSince(c Configterface, o *options) bool {
+	if o.template != nil || len(o.dotEnv) > 0 || hasResolverTags(reflect.TypeOf(configTarget(c))) {
+		return false
+	}
+	statesMu.Lock()
+	defer statesMu.Unlock()
+	s, ok := states[c]
+	return ok && s.checksum != nil && bytes.Equal(s.checksum, o.checksum)
+}
+
+// stateOf returns the load state of c.
+func stateOf(c Configterface) loadState {
+	statesMu.Lock()
+	defer statesMu.Unlock()
+	s, ok := states[c]
+	if !ok {
+		return loadState{}
+	}
+	copied := *s
+	copied.fetched = make(map[string]time.Time, len(s.fetched))
+	for source, t := range s.fetched {
+		copied.fetched[source] = t
+	}
+	return copied
+}
@@ -0,0 +1,111 @@
+package goconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// keptState reports whether state is kept for config.
+func keptState(config interface{}) bool {
+	c := configOf(config)
+	plainMu.Lock()
+	_, plain := plainConfigs[config]
+	plainMu.Unlock()
+	statesMu.Lock()
+	_, loaded := states[c]
+	statesMu.Unlock()
+	subscribersMu.Lock()
+	_, subscribed := subscribers[c]
+	subscribersMu.Unlock()
+	return plain || loaded || subscribed
+}
+
+func TestOneShotCallsKeepNoState(t *testing.T) {
+	filename := writeConfigFile(t, "config.yaml", "port: 8080\n")
+	var plain struct {
+		Port int `yaml:"port"`
+	}
+	embedded := &struct {
+		Config
+		Port int `yaml:"port"`
+	}{}
+	for name, config := range map[string]interface{}{"plain": &plain, "embedded": embedded} {
+		t.Run(name, func(t *testing.T) {
+			if err := Load(config, WithFile(filename)); err != nil {
+				t.Fatal(err)
+			}
+			if err := Validate(config, WithFile(filename)); err != nil {
+				t.Fatal(err)
+			}
+			if err := Reload(config, WithFile(filename)); err != nil {
+				t.Fatal(err)
+			}
+			if keptState(config) {
+				t.Error("state kept after one-shot calls")
+			}
+		})
+	}
+}
+
+func TestKeptStateRelease(t *testing.T) {
+	filename := writeConfigFile(t, "config.yaml", "port: 8080\n")
+	var c struct {
+		Port int `yaml:"port"`
+	}
+	state := StateOf(&c)
+	events := Subscribe(&c)
+	if err := Load(&c, WithFile(filename)); err != nil {
+		t.Fatal(err)
+	}
+	if StateOf(&c) != state {
+		t.Error("StateOf returned different state for the same config")
+	}
+	if !Status(&c).Ready() {
+		t.Error("load of a kept config not recorded")
+	}
+	Release(&c)
+	if keptState(&c) {
+		t.Error("state kept after Release")
+	}
+	if _, ok := <-events; ok {
+		t.Error("subscription not closed by Release")
+	}
+	if Status(&c).Ready() {
+		t.Error("status kept after Release")
+	}
+}
+
+func TestReloadRereadsEnvFiles(t *testing.T) {
+	var c struct {
+		Port     int    `yaml:"port"`
+		Password string `yaml:"password" env:"UNCHANGEDTEST_PASSWORD"`
+		Token    string `yaml:"token" envFile:"UNCHANGEDTEST_TOKEN_PATH"`
+	}
+	dir := t.TempDir()
+	password, token := filepath.Join(dir, "password"), filepath.Join(dir, "token")
+	for _, name := range []string{password, token} {
+		if err := os.WriteFile(name, []byte("one\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("UNCHANGEDTEST_PASSWORD_FILE", password)
+	t.Setenv("UNCHANGEDTEST_TOKEN_PATH", token)
+	filename := writeConfigFile(t, "config.yaml", "port: 80\n")
+	StateOf(&c)
+	t.Cleanup(func() { Release(&c) })
+	if err := Load(&c, WithFile(filename)); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{password, token} {
+		if err := os.WriteFile(name, []byte("two\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Reload(&c, WithFile(filename)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Password != "two" || c.Token != "two" {
+		t.Errorf("password, token = %q, %q after rotating their files", c.Password, c.Token)
+	}
+}
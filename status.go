@@ -0,0 +1,81 @@
+package goconfig
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// LoadStatus reports how the loads of a config have gone, for readiness and
+// health checks.
+type LoadStatus struct {
+	// Loaded is set once a load of the config has succeeded, and LoadedAt is
+	// the time of the last one.
+	Loaded   bool
+	LoadedAt time.Time
+	// LastReload is the time of the last reload, or failed refresh of a
+	// remote source, and LastReloadErr its error if it failed.
+	LastReload    time.Time
+	LastReloadErr error
+	// RemoteFetchedAt holds the times the remote sources of the config were
+	// last fetched successfully, by URL with any password redacted.
+	RemoteFetchedAt map[string]time.Time
+	// Staleness is how long ago the least recently fetched remote source was
+	// fetched, zero without remote sources.
+	Staleness time.Duration
+}
+
+// Status returns how the loads and reloads of c, by Load, ListenForSignals,
+// Watch or Reload, have gone since its state was first kept, as by StateOf,
+// ListenForSignals, Watch, Subscribe or ReadinessHandler. The loads of a
+// config whose state isn't kept aren't recorded.
+func Status(c interface{}) LoadStatus {
+	state := stateOf(configOf(c))
+	status := LoadStatus{
+		Loaded:          state.loaded,
+		LoadedAt:        state.lastSuccess,
+		LastReload:      state.lastReload,
+		LastReloadErr:   state.lastReloadErr,
+		RemoteFetchedAt: state.fetched,
+	}
+	for _, fetched := range state.fetched {
+		if staleness := time.Since(fetched); staleness > status.Staleness {
+			status.Staleness = staleness
+		}
+	}
+	return status
+}
+
+// Ready reports whether the config has been loaded.
+func (s LoadStatus) Ready() bool {
+	return s.Loaded
+}
+
+// Degraded reports whether the config is running on old values because the
+// last reload or refresh of a remote source failed.
+func (s LoadStatus) Degraded() bool {
+	return s.LastReloadErr != nil
+}
+
+// ReadinessHandler returns an http.Handler for readiness probes that responds
+// with 200 once c has been loaded, and 503 before that, or when its remote
+// sources haven't been fetched for longer than maxStaleness, if that isn't
+// zero. A failed reload makes the body "degraded" rather than "ok", without
+// failing the probe, since the config keeps its previous values. It keeps
+// the state of c, so it must be created before c is loaded.
+func ReadinessHandler(c interface{}, maxStaleness time.Duration) http.Handler {
+	keepConfig(c)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := Status(c)
+		switch {
+		case !status.Ready():
+			http.Error(w, "config not loaded", http.StatusServiceUnavailable)
+		case maxStaleness > 0 && status.Staleness > maxStaleness:
+			http.Error(w, "remote config stale for "+status.Staleness.Round(time.Second).String(), http.StatusServiceUnavailable)
+		case status.Degraded():
+			fmt.Fprintf(w, "degraded: %s\n", status.LastReloadErr)
+		default:
+			fmt.Fprintln(w, "ok")
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package goconfig
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// Store publishes immutable snapshots of a config, for hot paths that would
+// rather not take the config's lock on every read. Readers call Get, and each
+// successful Load given WithStore publishes a new snapshot atomically.
+type Store[T any] struct {
+	current atomic.Pointer[T]
+}
+
+// NewStore returns a Store holding a snapshot of config. Pass WithStore to
+// Load, ListenForSignals or Watch to keep it up to date.
+func NewStore[T any](config *T) *Store[T] {
+	s := &Store[T]{}
+	c := configOf(config)
+	c.RLock()
+	defer c.RUnlock()
+	s.publish(configTarget(c))
+	return s
+}
+
+// Get returns the current snapshot. It must not be modified, as it is shared
+// with every other reader.
+func (s *Store[T]) Get() *T {
+	return s.current.Load()
+}
+
+// publish stores a deep copy of v, which must be a *T whose config is locked.
+func (s *Store[T]) publish(v interface{}) {
+	s.current.Store(deepCopy(reflect.ValueOf(v)).Interface().(*T))
+}
+
+// WithStore makes each successful Load publish a snapshot of the config to s.
+func WithStore[T any](s *Store[T]) Option {
+	return func(o *options) {
+		o.stores = append(o.stores, s.publish)
+	}
+}
@@ -0,0 +1,41 @@
+package goconfig
+
+import "context"
+
+// Tracer starts the spans goconfig traces its loads with: "goconfig.Load"
+// around each Load or reload, with a "goconfig.read" span for every file or
+// URL read, then "goconfig.validate" and "goconfig.swap". Attributes are
+// alternating keys and values, as for Logger: the source and size of what was
+// read, and whether a load is a reload. A few lines adapt an OpenTelemetry
+// trace.Tracer; see the README.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...interface{}) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	SetAttributes(attrs ...interface{})
+	// End ends the span, which failed with err if it isn't nil.
+	End(err error)
+}
+
+// WithTracer traces loads and reloads with t.
+func WithTracer(t Tracer) Option {
+	return func(o *options) {
+		o.tracer = t
+	}
+}
+
+// startSpan starts a span with the tracer, if there is one.
+func (o *options) startSpan(ctx context.Context, name string, attrs ...interface{}) (context.Context, Span) {
+	if o.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return o.tracer.Start(ctx, name, attrs...)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...interface{}) {}
+
+func (noopSpan) End(err error) {}
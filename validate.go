@@ -0,0 +1,223 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validator is implemented by config structs, or structs nested in them, that
+// check rules too involved for `validate` tags. Load calls Validate after the
+// file and environment have been parsed.
+type Validator interface {
+	Validate() error
+}
+
+// Violation describes a field that broke one of its `validate` tag rules.
+type Violation struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+// InvalidStructFields is returned when fields fail their `validate` tag rules.
+type InvalidStructFields struct {
+	Violations []Violation
+}
+
+func (e InvalidStructFields) Error() string {
+	var parts []string
+	for _, v := range e.Violations {
+		parts = append(parts, fmt.Sprintf("%s (%s)", v.Field, v.Message))
+	}
+	return fmt.Sprintf("The following struct fields are invalid: %s", strings.Join(parts, ", "))
+}
+
+// callValidators calls Validate on val and on every nested struct implementing
+// Validator, returning the errors prefixed with the path of the failing struct.
+func callValidators(val interface{}) []error {
+	var errs []error
+	if v, ok := val.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	value := reflect.Indirect(reflect.ValueOf(val))
+	if value.Kind() == reflect.Struct {
+		callNestedValidators(value, fieldPath{}, &errs)
+	}
+	return errs
+}
+
+func callNestedValidators(value reflect.Value, path fieldPath, errs *[]error) {
+	fields := fieldsOf(value.Type())
+	for i := range fields {
+		f := &fields[i]
+		field := value.Field(f.Index[0])
+		for _, nested := range nestedStructs(field, path.childField(f)) {
+			// The Validate method of an embedded struct is promoted to its
+			// parent, which has already been called.
+			if !f.Anonymous || field.Kind() != reflect.Struct {
+				if v, ok := nested.value.Addr().Interface().(Validator); ok {
+					if err := v.Validate(); err != nil {
+						*errs = append(*errs, fmt.Errorf("%s: %w", nested.path.field, err))
+					}
+				}
+			}
+			callNestedValidators(nested.value, nested.path, errs)
+		}
+	}
+}
+
+// checkValidateTags checks every field of the struct pointed to by val against
+// the rules in its `validate` tag, recursing into nested structs and
+// collections of structs. Rules are comma separated; a regexp rule takes the
+// rest of the tag, commas included.
+func checkValidateTags(val interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(val))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	var violations []Violation
+	validateStruct(value, fieldPath{}, &violations)
+	if violations != nil {
+		return InvalidStructFields{violations}
+	}
+	return nil
+}
+
+func validateStruct(value reflect.Value, path fieldPath, violations *[]Violation) {
+	fields := fieldsOf(value.Type())
+	for i := range fields {
+		f := &fields[i]
+		field := value.Field(f.Index[0])
+		name := path.childField(f)
+		for _, rule := range f.rules {
+			if rule.name == "omitempty" {
+				if isZero(field) {
+					break
+				}
+				continue
+			}
+			if msg := rule.check(field); msg != "" {
+				*violations = append(*violations, Violation{Field: name.field, Rule: rule.name, Message: msg})
+			}
+		}
+		for _, nested := range nestedStructs(field, name) {
+			validateStruct(nested.value, nested.path, violations)
+		}
+	}
+}
+
+type rule struct {
+	name  string
+	param string
+	// re is the compiled param of a regexp rule, or reErr the error compiling
+	// it.
+	re    *regexp.Regexp
+	reErr error
+}
+
+func parseRules(tag string) []rule {
+	var rules []rule
+	for tag != "" {
+		var r string
+		if strings.HasPrefix(tag, "regexp=") {
+			r, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			r, tag = tag[:i], tag[i+1:]
+		} else {
+			r, tag = tag, ""
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(r), "=")
+		if name == "regexp" {
+			re, err := regexp.Compile(param)
+			rules = append(rules, rule{name: name, param: param, re: re, reErr: err})
+		} else if name != "" {
+			rules = append(rules, rule{name: name, param: param})
+		}
+	}
+	return rules
+}
+
+// check returns a description of how v breaks the rule, or "" if it doesn't.
+func (r rule) check(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch r.name {
+	case "min", "max":
+		actual, bound, err := compareOperands(v, r.param)
+		if err != nil {
+			return err.Error()
+		}
+		if r.name == "min" && actual < bound {
+			return fmt.Sprintf("must be at least %s", r.param)
+		}
+		if r.name == "max" && actual > bound {
+			return fmt.Sprintf("must be at most %s", r.param)
+		}
+	case "oneof":
+		actual := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(r.param) {
+			if actual == option {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %s", r.param)
+	case "regexp":
+		if r.reErr != nil {
+			return fmt.Sprintf("invalid regexp %q: %s", r.param, r.reErr)
+		}
+		if v.Kind() != reflect.String {
+			return fmt.Sprintf("regexp does not apply to %s", v.Type())
+		}
+		if !r.re.MatchString(v.String()) {
+			return fmt.Sprintf("must match %s", r.param)
+		}
+	default:
+		return fmt.Sprintf("unknown validation rule %q", r.name)
+	}
+	return ""
+}
+
+// compareOperands returns the quantity of v that min and max rules apply to
+// (the value of numbers, the length of strings and collections) along with the
+// parsed bound.
+func compareOperands(v reflect.Value, param string) (float64, float64, error) {
+	if v.Type() == durationType {
+		bound, err := time.ParseDuration(param)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid duration bound %q", param)
+		}
+		return float64(v.Int()), float64(bound), nil
+	}
+	if v.Type() == byteSizeType {
+		bound, err := ParseByteSize(param)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid byte size bound %q", param)
+		}
+		return float64(v.Uint()), float64(bound), nil
+	}
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid bound %q", param)
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), bound, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), bound, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), bound, nil
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), bound, nil
+	}
+	return 0, 0, fmt.Errorf("min and max do not apply to %s", v.Type())
+}
@@ -0,0 +1,102 @@
+package goconfig
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRules(t *testing.T) {
+	port := 80
+	tests := []struct {
+		tag   string
+		value interface{}
+		want  string
+	}{
+		{"min=1", 1, ""},
+		{"min=1", 0, "must be at least 1"},
+		{"max=10", 10, ""},
+		{"max=10", 11, "must be at most 10"},
+		{"min=1", uint8(0), "must be at least 1"},
+		{"max=0.5", 0.75, "must be at most 0.5"},
+		{"min=3", "abc", ""},
+		{"min=3", "ab", "must be at least 3"},
+		{"max=2", []int{1, 2, 3}, "must be at most 2"},
+		{"max=1", map[string]int{"a": 1}, ""},
+		{"min=1s", time.Second, ""},
+		{"min=1s", 999 * time.Millisecond, "must be at least 1s"},
+		{"max=1KiB", ByteSize(1025), "must be at most 1KiB"},
+		{"min=1", &port, ""},
+		{"min=100", &port, "must be at least 100"},
+		{"min=100", (*int)(nil), ""},
+		{"min=one", 1, `invalid bound "one"`},
+		{"min=soon", time.Second, `invalid duration bound "soon"`},
+		{"max=lots", ByteSize(1), `invalid byte size bound "lots"`},
+		{"min=1", true, "min and max do not apply to bool"},
+		{"oneof=debug info warn", "info", ""},
+		{"oneof=debug info warn", "trace", "must be one of debug info warn"},
+		{"oneof=1 2", 2, ""},
+		{"oneof=1 2", 3, "must be one of 1 2"},
+		{"regexp=^[a-z]+$", "abc", ""},
+		{"regexp=^[a-z]+$", "abc1", "must match ^[a-z]+$"},
+		{"regexp=^a{1,2}$", "aa", ""},
+		{"regexp=^a{1,2}$", "aaa", "must match ^a{1,2}$"},
+		{"regexp=[", "a", "invalid regexp \"[\": error parsing regexp: missing closing ]: `[`"},
+		{"regexp=^a$", 1, "regexp does not apply to int"},
+		{"nonzero", 1, `unknown validation rule "nonzero"`},
+	}
+	for _, test := range tests {
+		rules := parseRules(test.tag)
+		if len(rules) != 1 {
+			t.Fatalf("%s: %d rules", test.tag, len(rules))
+		}
+		if got := rules[0].check(reflect.ValueOf(test.value)); got != test.want {
+			t.Errorf("%s on %v = %q, want %q", test.tag, test.value, got, test.want)
+		}
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	type upstream struct {
+		Weight int `validate:"min=1,max=100"`
+	}
+	c := struct {
+		Level     string     `validate:"oneof=debug info"`
+		Name      string     `validate:"omitempty,min=3"`
+		Host      string     `validate:"min=1,regexp=^[a-z]+(,[a-z]+)*$"`
+		Upstreams []upstream `yaml:"upstreams"`
+	}{
+		Level:     "trace",
+		Host:      "a,b",
+		Upstreams: []upstream{{Weight: 1}, {Weight: 101}},
+	}
+	err := checkValidateTags(&c)
+	var invalid InvalidStructFields
+	if !errors.As(err, &invalid) {
+		t.Fatalf("err = %v", err)
+	}
+	want := []Violation{
+		{Field: "Level", Rule: "oneof", Message: "must be one of debug info"},
+		{Field: "Upstreams[1].Weight", Rule: "max", Message: "must be at most 100"},
+	}
+	if len(invalid.Violations) != len(want) {
+		t.Fatalf("violations = %+v", invalid.Violations)
+	}
+	for i := range want {
+		if invalid.Violations[i] != want[i] {
+			t.Errorf("violation %d = %+v, want %+v", i, invalid.Violations[i], want[i])
+		}
+	}
+	const msg = "The following struct fields are invalid: Level (must be one of debug info), Upstreams[1].Weight (must be at most 100)"
+	if err.Error() != msg {
+		t.Errorf("error = %q", err)
+	}
+
+	c.Level, c.Upstreams[1].Weight, c.Host = "info", 100, "a,b,"
+	err = checkValidateTags(&c)
+	if err == nil || !strings.Contains(err.Error(), "Host (must match") {
+		t.Errorf("err = %v", err)
+	}
+}
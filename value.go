@@ -0,0 +1,297 @@
+package goconfig
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	urlType             = reflect.TypeOf(url.URL{})
+	ipNetType           = reflect.TypeOf(net.IPNet{})
+	ipType              = reflect.TypeOf(net.IP{})
+	addrType            = reflect.TypeOf(netip.Addr{})
+	prefixType          = reflect.TypeOf(netip.Prefix{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// textCodec parses and formats the values of a type that is written as a
+// string in config but can't do so itself, such as url.URL.
+type textCodec struct {
+	parse  func(s string) (interface{}, error)
+	format func(v interface{}) string
+}
+
+// textCodecs are the textCodecs by type.
+var textCodecs = map[reflect.Type]textCodec{
+	urlType: {
+		parse: func(s string) (interface{}, error) {
+			u, err := url.Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid URL %q: %s", s, errors.Unwrap(err))
+			}
+			if u.Scheme == "" {
+				return nil, fmt.Errorf("invalid URL %q, expected an absolute URL such as https://example.com", s)
+			}
+			return *u, nil
+		},
+		format: func(v interface{}) string {
+			u := v.(url.URL)
+			return u.String()
+		},
+	},
+	ipNetType: {
+		parse: func(s string) (interface{}, error) {
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q, expected an address and prefix length such as 10.0.0.0/8", s)
+			}
+			return *n, nil
+		},
+		format: func(v interface{}) string {
+			n := v.(net.IPNet)
+			return n.String()
+		},
+	},
+}
+
+// parsesItself reports whether values of type t are parsed from strings, by
+// a decode hook, UnmarshalText or a textCodec, rather than being sections of
+// their own.
+func parsesItself(t reflect.Type) bool {
+	if _, ok := decodeHookFor(t); ok {
+		return true
+	}
+	_, ok := textCodecs[t]
+	return ok || reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// setFromString parses s according to the type of v and stores the result in
+// v. Slices are parsed as comma separated lists, and maps as comma separated
+// key:value pairs.
+func setFromString(v reflect.Value, s string) error {
+	return setFromStringWith(v, s, stringFormat{})
+}
+
+// stringFormat says how setFromStringWith parses a string.
+type stringFormat struct {
+	// separator splits slices and maps, a comma if empty.
+	separator string
+	// keyValueSeparator splits the keys of map entries from their values, a
+	// colon if empty.
+	keyValueSeparator string
+	// layout parses times, as given to time.Parse, if set, as by the `layout`
+	// tag of a field.
+	layout string
+	// base is the base integers are parsed in, as given to strconv.ParseInt,
+	// if hasBase is set, as by the `base` tag of a field, and 10 otherwise.
+	base    int
+	hasBase bool
+}
+
+// tagBase returns the base in the `base` tag of a field, if it has one. A base
+// that isn't a number is returned as -1, which strconv rejects.
+func tagBase(tag reflect.StructTag) (base int, ok bool) {
+	s, ok := tag.Lookup("base")
+	if !ok {
+		return 0, false
+	}
+	base, err := strconv.Atoi(s)
+	if err != nil {
+		return -1, true
+	}
+	return base, true
+}
+
+// setFromStringWith is setFromString with the separators and layout of f.
+func setFromStringWith(v reflect.Value, s string, f stringFormat) error {
+	if f.separator == "" {
+		f.separator = ","
+	}
+	base := 10
+	if f.hasBase {
+		base = f.base
+	}
+	if f.keyValueSeparator == "" {
+		f.keyValueSeparator = ":"
+	}
+	layout := f.layout
+	if hook, ok := decodeHookFor(v.Type()); ok {
+		converted, err := runDecodeHook(hook, v.Type(), s)
+		if err != nil {
+			return err
+		}
+		v.Set(converted)
+		return nil
+	}
+	if layout != "" && v.Type() == timeType {
+		t, err := parseTime(s, layout)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if codec, ok := textCodecs[v.Type()]; ok {
+		parsed, err := codec.parse(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+	if parse, ok := valueParsers[v.Type()]; ok {
+		if err := parse(s); err != nil {
+			return err
+		}
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := parseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, base, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, base, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var parts []string
+		if s != "" {
+			parts = strings.Split(s, f.separator)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setFromStringWith(slice.Index(i), strings.TrimSpace(part), f); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		if s != "" {
+			for _, part := range strings.Split(s, f.separator) {
+				k, value, ok := strings.Cut(part, f.keyValueSeparator)
+				if !ok {
+					return fmt.Errorf("invalid map entry %q, expected key%svalue", strings.TrimSpace(part), f.keyValueSeparator)
+				}
+				key := reflect.New(v.Type().Key()).Elem()
+				if err := setFromStringWith(key, strings.TrimSpace(k), f); err != nil {
+					return err
+				}
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := setFromStringWith(elem, strings.TrimSpace(value), f); err != nil {
+					return fmt.Errorf("%s: %s", strings.TrimSpace(k), err)
+				}
+				m.SetMapIndex(key, elem)
+			}
+		}
+		v.Set(m)
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setFromStringWith(elem.Elem(), s, f); err != nil {
+			return err
+		}
+		v.Set(elem)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// parseDuration parses a duration such as "500ms" or "2h45m", with an error
+// that says what is expected.
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, expected a number with a unit such as 500ms or 2h45m", s)
+	}
+	return d, nil
+}
+
+// parseTime parses s with layout, as given to time.Parse.
+func parseTime(s, layout string) (time.Time, error) {
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected the layout %s", s, layout)
+	}
+	return t, nil
+}
+
+// valueParsers parse the strings given for fields of types whose decoding
+// errors wouldn't name the field.
+var valueParsers = map[reflect.Type]func(string) error{
+	durationType: func(s string) error {
+		_, err := parseDuration(s)
+		return err
+	},
+	byteSizeType: func(s string) error {
+		_, err := ParseByteSize(s)
+		return err
+	},
+	levelType: func(s string) error {
+		_, err := ParseLevel(s)
+		return err
+	},
+	ipType: func(s string) error {
+		if net.ParseIP(s) == nil {
+			return fmt.Errorf("invalid IP address %q", s)
+		}
+		return nil
+	},
+	addrType: func(s string) error {
+		if _, err := netip.ParseAddr(s); err != nil {
+			return fmt.Errorf("invalid IP address %q", s)
+		}
+		return nil
+	},
+	prefixType: func(s string) error {
+		if _, err := netip.ParsePrefix(s); err != nil {
+			return fmt.Errorf("invalid CIDR %q, expected an address and prefix length such as 10.0.0.0/8", s)
+		}
+		return nil
+	},
+	reflect.TypeOf(netip.AddrPort{}): func(s string) error {
+		if _, err := netip.ParseAddrPort(s); err != nil {
+			return fmt.Errorf("invalid address %q, expected an IP address and port such as 10.0.0.1:80", s)
+		}
+		return nil
+	},
+}
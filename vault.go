@@ -0,0 +1,214 @@
+package goconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Vault configures how fields tagged `vault:"path#key"` are read from
+// HashiCorp Vault. Empty fields fall back to the standard VAULT_ADDR,
+// VAULT_TOKEN and VAULT_NAMESPACE environment variables, and to AppRole login
+// with VAULT_ROLE_ID and VAULT_SECRET_ID when there is no token.
+type Vault struct {
+	// Address is the URL of the Vault server.
+	Address string
+	// Token authenticates requests.
+	Token string
+	// RoleID and SecretID log in with AppRole to get a token.
+	RoleID   string
+	SecretID string
+	// Namespace is the Vault Enterprise namespace.
+	Namespace string
+	// Client makes the requests, a client with a 30 second timeout if nil.
+	Client *http.Client
+	// OnLease, if set, is called for each secret read that comes with a
+	// lease, such as dynamic database credentials, e.g. to renew it or to
+	// schedule a Reload before it expires.
+	OnLease func(VaultLease)
+}
+
+// VaultLease describes the lease of a secret read from Vault.
+type VaultLease struct {
+	// Path is the path the secret was read from.
+	Path string
+	// ID is the lease ID to renew or revoke the lease with.
+	ID string
+	// Duration is how long the lease lasts.
+	Duration time.Duration
+	// Renewable tells whether the lease can be renewed.
+	Renewable bool
+}
+
+// WithVault configures how fields tagged `vault:"path#key"` are read from
+// Vault, rather than from the environment alone.
+func WithVault(v Vault) Option {
+	return func(o *options) {
+		o.vault = &v
+	}
+}
+
+var (
+	// vaultTokensMu guards vaultTokens.
+	vaultTokensMu sync.Mutex
+	// vaultTokens caches the tokens got by AppRole logins until they expire,
+	// by address and role ID.
+	vaultTokens = map[string]cachedToken{}
+)
+
+// settings returns v with its empty fields filled in from the environment.
+func (v Vault) settings() Vault {
+	fill := func(s *string, env string) {
+		if *s == "" {
+			*s = os.Getenv(env)
+		}
+	}
+	fill(&v.Address, "VAULT_ADDR")
+	fill(&v.Token, "VAULT_TOKEN")
+	fill(&v.Namespace, "VAULT_NAMESPACE")
+	fill(&v.RoleID, "VAULT_ROLE_ID")
+	fill(&v.SecretID, "VAULT_SECRET_ID")
+	if v.Address == "" {
+		v.Address = "https://127.0.0.1:8200"
+	}
+	v.Address = strings.TrimSuffix(v.Address, "/")
+	if v.Client == nil {
+		v.Client = defaultHTTPClient
+	}
+	return v
+}
+
+// resolveVault reads the secret at the path of ref, "path#key", and returns
+// the value of its key. Both KV version 1 and 2 secrets are understood; for
+// version 2, the path includes the "data" segment, as in
+// "secret/data/myapp#db_password".
+func resolveVault(ctx context.Context, ref string, o *options, fetched map[string]interface{}) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || key == "" {
+		return "", errors.New(`expected "path#key"`)
+	}
+	data, err := readVault(ctx, path, o, fetched)
+	if err != nil {
+		return "", err
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("no key %q in secret", key)
+	}
+	return secretString(value)
+}
+
+// readVault reads the data of the secret at path, once per Load, so that the
+// keys of dynamic secrets such as database credentials come from the same
+// lease.
+func readVault(ctx context.Context, path string, o *options, fetched map[string]interface{}) (map[string]interface{}, error) {
+	cacheKey := "vault\x00" + path
+	if data, ok := fetched[cacheKey].(map[string]interface{}); ok {
+		return data, nil
+	}
+	v := Vault{}
+	if o.vault != nil {
+		v = *o.vault
+	}
+	v = v.settings()
+	token, err := v.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var secret struct {
+		LeaseID       string                 `json:"lease_id"`
+		LeaseDuration int                    `json:"lease_duration"`
+		Renewable     bool                   `json:"renewable"`
+		Data          map[string]interface{} `json:"data"`
+	}
+	if err := v.call(ctx, http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"), token, nil, &secret); err != nil {
+		return nil, err
+	}
+	if secret.LeaseID != "" && v.OnLease != nil {
+		v.OnLease(VaultLease{path, secret.LeaseID, time.Duration(secret.LeaseDuration) * time.Second, secret.Renewable})
+	}
+	data := secret.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	fetched[cacheKey] = data
+	return data, nil
+}
+
+// token returns the token to authenticate with, logging in with AppRole if
+// there is no token but a role ID.
+func (v Vault) token(ctx context.Context) (string, error) {
+	if v.Token != "" || v.RoleID == "" {
+		return v.Token, nil
+	}
+	cacheKey := v.Address + "\x00" + v.Namespace + "\x00" + v.RoleID
+	vaultTokensMu.Lock()
+	cached, ok := vaultTokens[cacheKey]
+	vaultTokensMu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.token, nil
+	}
+	var login struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	body := map[string]string{"role_id": v.RoleID, "secret_id": v.SecretID}
+	if err := v.call(ctx, http.MethodPost, "/v1/auth/approle/login", "", body, &login); err != nil {
+		return "", fmt.Errorf("approle login: %w", err)
+	}
+	// Log in again a little before the token expires.
+	ttl := time.Duration(login.Auth.LeaseDuration) * time.Second * 9 / 10
+	vaultTokensMu.Lock()
+	vaultTokens[cacheKey] = cachedToken{login.Auth.ClientToken, time.Now().Add(ttl)}
+	vaultTokensMu.Unlock()
+	return login.Auth.ClientToken, nil
+}
+
+// call makes a request to the Vault API and decodes the response into out.
+func (v Vault) call(ctx context.Context, method, path, token string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.Address+path, reader)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		if data, _ := io.ReadAll(resp.Body); json.Unmarshal(data, &e) == nil && len(e.Errors) > 0 {
+			return fmt.Errorf("vault: %s", strings.Join(e.Errors, "; "))
+		}
+		return fmt.Errorf("vault: unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
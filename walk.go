@@ -0,0 +1,113 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// fieldPath locates a value inside the config both by Go field names, e.g.
+// `Database.Password`, and by yaml keys, e.g. `database.password`.
+type fieldPath struct {
+	field string
+	key   string
+}
+
+// child returns the path of the struct field sf below p.
+func (p fieldPath) child(sf reflect.StructField) fieldPath {
+	return fieldPath{joinPath(p.field, sf.Name), joinPath(p.key, yamlKey(sf))}
+}
+
+// childField is child for a field parsed by fieldsOf, whose key is known.
+func (p fieldPath) childField(f *fieldInfo) fieldPath {
+	return fieldPath{joinPath(p.field, f.Name), joinPath(p.key, f.key)}
+}
+
+// index returns the path of the element of p with the given index or map key.
+func (p fieldPath) index(i interface{}) fieldPath {
+	suffix := fmt.Sprintf("[%v]", i)
+	return fieldPath{p.field + suffix, p.key + suffix}
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" || name == "" {
+		return parent + name
+	}
+	return parent + "." + name
+}
+
+// yamlKey returns the key yaml maps sf to: the name in its yaml tag, or the
+// lowercased field name. Inlined structs, including embedded structs without
+// a name in their yaml tag, have no key of their own.
+func yamlKey(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+	if inlined(sf) || name == "" && embedded(sf) {
+		return ""
+	}
+	if name == "" {
+		return strings.ToLower(sf.Name)
+	}
+	return name
+}
+
+// nestedStruct is an addressable struct value found inside the config, along
+// with its path from the root.
+type nestedStruct struct {
+	value reflect.Value
+	path  fieldPath
+}
+
+// nestedStructs returns the structs held by field, whose path is path: the
+// field itself if it is a struct or a non-nil pointer to one, or the struct
+// elements of a slice, array or map, indexed like `Upstreams[2]` or
+// `Tenants[acme]`.
+func nestedStructs(field reflect.Value, path fieldPath) []nestedStruct {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+	switch field.Kind() {
+	case reflect.Struct:
+		if field.Type() == timeType {
+			return nil
+		}
+		if !field.CanAddr() {
+			copied := reflect.New(field.Type()).Elem()
+			copied.Set(field)
+			field = copied
+		}
+		return []nestedStruct{{field, path}}
+	case reflect.Slice, reflect.Array:
+		var nested []nestedStruct
+		for i := 0; i < field.Len(); i++ {
+			nested = append(nested, elemStructs(field.Index(i), path.index(i))...)
+		}
+		return nested
+	case reflect.Map:
+		keys := field.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		var nested []nestedStruct
+		for _, key := range keys {
+			nested = append(nested, elemStructs(field.MapIndex(key), path.index(key.Interface()))...)
+		}
+		return nested
+	}
+	return nil
+}
+
+// elemStructs is nestedStructs for collection elements, which are only
+// descended into if they are structs themselves.
+func elemStructs(elem reflect.Value, path fieldPath) []nestedStruct {
+	if elem.Kind() == reflect.Interface && !elem.IsNil() {
+		elem = elem.Elem()
+	}
+	if reflect.Indirect(elem).Kind() != reflect.Struct {
+		return nil
+	}
+	return nestedStructs(elem, path)
+}
@@ -0,0 +1,268 @@
+package goconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Reloads the config whenever its file changes, as an alternative to
+// ListenForSignals for platforms and deployments where sending SIGHUP is
+// awkward. It should be called after the first Load, with the same options.
+//
+// The directories holding the config files are watched rather than the files
+// themselves, so that editors and tools replacing a file by renaming a new one
+// over it are noticed too. Removing a file doesn't trigger a reload, and bursts
+// of changes are coalesced into one reload (see WithDebounce). Reload failures
+// are handled as with ListenForSignals.
+//
+// Remote configs are watched through their backend where it supports that,
+// e.g. with an etcd watch, and polled otherwise (see WithPollInterval).
+func Watch(config interface{}, opts ...Option) error {
+	return WatchContext(context.Background(), config, opts...)
+}
+
+// WatchContext is Watch until ctx is done, at which point the watcher is
+// closed.
+func WatchContext(ctx context.Context, config interface{}, opts ...Option) error {
+	c := keepConfig(config)
+	if err := checkPointer(c); err != nil {
+		return err
+	}
+	files := newOptions(opts).files
+	if len(files) == 0 {
+		files = []string{c.GetFilename()}
+	}
+	var local []string
+	remote := map[*url.URL]watchFunc{}
+	polled := map[*url.URL]fetcher{}
+	for _, filename := range files {
+		u, fetch, ok := remoteURL(filename)
+		if !ok {
+			local = append(local, filename)
+			continue
+		}
+		if watch, ok := remoteWatcher(u); ok {
+			remote[u] = watch
+		} else {
+			polled[u] = fetch
+		}
+	}
+	r := newReloader(c, opts, defaultWatchDebounce)
+	if len(local) > 0 {
+		if err := watchFiles(ctx, local, r); err != nil {
+			return err
+		}
+	}
+	for u, watch := range remote {
+		go watchRemote(ctx, u, watch, opts, r)
+	}
+	for u, fetch := range polled {
+		go pollRemote(ctx, u, fetch, opts, r)
+	}
+	go func() {
+		<-ctx.Done()
+		r.stop()
+	}()
+	return nil
+}
+
+// watchFiles triggers r whenever one of the local config files changes, until
+// ctx is done.
+func watchFiles(ctx context.Context, files []string, r *reloader) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	targets, err := watchTargets(files)
+	if err == nil {
+		for dir := range targets.dirs {
+			if err = watcher.Add(dir); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				removed := (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)) && targets.aggregated(event.Name)
+				if !removed && (!event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) || !targets.match(event.Name) && !targets.rotated(event.Name)) {
+					continue
+				}
+				r.trigger(filepath.Clean(event.Name))
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.log().Warn("config watch error", "error", err)
+			case <-ctx.Done():
+				watcher.Close()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Reloads the config whenever the file trigger is created or touched, for
+// platforms such as Windows where a signal can't be sent to the process. The
+// directory holding trigger must exist. It stops when ctx is done.
+func WatchTrigger(ctx context.Context, config interface{}, trigger string, opts ...Option) error {
+	c := keepConfig(config)
+	if err := checkPointer(c); err != nil {
+		return err
+	}
+	trigger = filepath.Clean(trigger)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(trigger)); err != nil {
+		watcher.Close()
+		return err
+	}
+	r := newReloader(c, opts, defaultWatchDebounce)
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == trigger && (event.Has(fsnotify.Create) || event.Has(fsnotify.Write) || event.Has(fsnotify.Chmod)) {
+					r.trigger(trigger)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.log().Warn("config watch error", "error", err)
+			case <-ctx.Done():
+				watcher.Close()
+				r.stop()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// dataLink is the symlink Kubernetes points the files of a mounted ConfigMap
+// or Secret through, and replaces to update them all at once.
+const dataLink = "..data"
+
+// watchSet is the set of paths whose changes trigger a reload.
+type watchSet struct {
+	// dirs are the directories watched. Those mapped to true are config
+	// directories, any config file in which counts.
+	dirs map[string]bool
+	// files maps the config files within the other directories to the files
+	// they resolve to through any symlinks.
+	files map[string]string
+	// patterns are the glob patterns of config files, matching files that
+	// may not exist yet.
+	patterns []string
+}
+
+func watchTargets(files []string) (*watchSet, error) {
+	s := &watchSet{dirs: map[string]bool{}, files: map[string]string{}}
+	for _, filename := range files {
+		if filename == "" {
+			return nil, errors.New("no config file to watch")
+		}
+		filename = filepath.Clean(filename)
+		info, err := os.Stat(filename)
+		if err == nil && info.IsDir() {
+			s.dirs[filename] = true
+			continue
+		}
+		if err != nil && isGlob(filename) {
+			// The directories are only globbed once, so new ones that would
+			// match aren't watched.
+			dirs, err := filepath.Glob(filepath.Dir(filename))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", filename, err)
+			}
+			for _, dir := range dirs {
+				if _, ok := s.dirs[dir]; !ok {
+					s.dirs[dir] = false
+				}
+			}
+			s.patterns = append(s.patterns, filename)
+			continue
+		}
+		dir := filepath.Dir(filename)
+		if _, ok := s.dirs[dir]; !ok {
+			s.dirs[dir] = false
+		}
+		s.files[filename] = realPath(filename)
+	}
+	return s, nil
+}
+
+// realPath returns filename with any symlinks resolved, or "" if it doesn't
+// exist.
+func realPath(filename string) string {
+	real, err := filepath.EvalSymlinks(filename)
+	if err != nil {
+		return ""
+	}
+	return real
+}
+
+// match reports whether a change to name affects the config.
+func (s *watchSet) match(name string) bool {
+	name = filepath.Clean(name)
+	if _, ok := s.files[name]; ok {
+		return true
+	}
+	return s.aggregated(name)
+}
+
+// aggregated reports whether name is one of several config files merged from
+// a directory or by a pattern, whose removal changes the config, whereas a
+// single config file may be removed only to be written again.
+func (s *watchSet) aggregated(name string) bool {
+	name = filepath.Clean(name)
+	for _, pattern := range s.patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return s.dirs[filepath.Dir(name)] && isConfigFile(name)
+}
+
+// rotated reports whether the change to name swapped the target of a symlinked
+// config file, such as when Kubernetes replaces the `..data` symlink of a
+// mounted ConfigMap. The new targets are remembered for next time.
+func (s *watchSet) rotated(name string) bool {
+	name = filepath.Clean(name)
+	dir := filepath.Dir(name)
+	if s.dirs[dir] && filepath.Base(name) == dataLink {
+		return true
+	}
+	changed := false
+	for filename, real := range s.files {
+		if filepath.Dir(filename) != dir {
+			continue
+		}
+		if now := realPath(filename); now != real {
+			s.files[filename] = now
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,130 @@
+package goconfig
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type watchConfig struct {
+	Config
+	Port int `yaml:"port"`
+}
+
+// watchFile loads a watchConfig from a file holding content and watches it
+// until the returned context is cancelled, returning its reload events.
+func watchFile(t *testing.T, content string) (*watchConfig, string, <-chan ChangeEvent, context.CancelFunc) {
+	t.Helper()
+	filename := writeConfigFile(t, "config.yaml", content)
+	c := &watchConfig{}
+	if err := Load(c, WithFile(filename)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	t.Cleanup(func() { Release(c) })
+	events := Subscribe(c)
+	if err := WatchContext(ctx, c, WithFile(filename), WithDebounce(10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	return c, filename, events, cancel
+}
+
+func nextEvent(t *testing.T, events <-chan ChangeEvent) ChangeEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("config not reloaded")
+		return ChangeEvent{}
+	}
+}
+
+func writeFile(t *testing.T, filename, content string) {
+	t.Helper()
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchReloadsOnWrite(t *testing.T) {
+	c, filename, events, _ := watchFile(t, "port: 80\n")
+	writeFile(t, filename, "port: 81\n")
+	event := nextEvent(t, events)
+	if event.Err != nil {
+		t.Fatal(event.Err)
+	}
+	if len(event.Changes) != 1 || event.Changes[0].Path != "Port" {
+		t.Errorf("changes = %v", event.Changes)
+	}
+	if len(event.Triggers) != 1 || event.Triggers[0] != filepath.Clean(filename) {
+		t.Errorf("triggers = %v", event.Triggers)
+	}
+	c.RLock()
+	defer c.RUnlock()
+	if c.Port != 81 {
+		t.Errorf("port = %d", c.Port)
+	}
+}
+
+func TestWatchKeepsConfigOnFailedReload(t *testing.T) {
+	c, filename, events, _ := watchFile(t, "port: 80\n")
+	writeFile(t, filename, "port: nope\n")
+	if event := nextEvent(t, events); event.Err == nil {
+		t.Fatal("expected a reload error")
+	}
+	c.RLock()
+	port := c.Port
+	c.RUnlock()
+	if port != 80 {
+		t.Errorf("port = %d after a failed reload", port)
+	}
+	// Fixing the file reloads it again.
+	writeFile(t, filename, "port: 82\n")
+	if event := nextEvent(t, events); event.Err != nil {
+		t.Fatal(event.Err)
+	}
+	c.RLock()
+	defer c.RUnlock()
+	if c.Port != 82 {
+		t.Errorf("port = %d", c.Port)
+	}
+}
+
+func TestWatchReloadsOnRename(t *testing.T) {
+	c, filename, events, _ := watchFile(t, "port: 80\n")
+	// Editors and tools write a new file and rename it over the old one.
+	tmp := filepath.Join(filepath.Dir(filename), ".config.yaml.tmp")
+	writeFile(t, tmp, "port: 83\n")
+	if err := os.Rename(tmp, filename); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(t, events); event.Err != nil {
+		t.Fatal(event.Err)
+	}
+	c.RLock()
+	defer c.RUnlock()
+	if c.Port != 83 {
+		t.Errorf("port = %d", c.Port)
+	}
+}
+
+func TestWatchStopsWhenDone(t *testing.T) {
+	c, filename, events, cancel := watchFile(t, "port: 80\n")
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, filename, "port: 84\n")
+	select {
+	case event := <-events:
+		t.Fatalf("reloaded after the watch was stopped: %v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+	c.RLock()
+	defer c.RUnlock()
+	if c.Port != 80 {
+		t.Errorf("port = %d", c.Port)
+	}
+}